@@ -0,0 +1,176 @@
+// Package client implements friend alias storage for ratox-go
+package client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// maxAliasLength is the maximum length of a friend alias in bytes
+const maxAliasLength = 64
+
+// aliasStore persists local nicknames for friends, keyed by hex public key
+type aliasStore struct {
+	path    string
+	aliases map[string]string
+	mu      sync.RWMutex
+}
+
+// newAliasStore creates an alias store backed by the given file and loads any
+// aliases already saved there. Aliases no longer valid are reported to logf
+// and dropped on the next save.
+func newAliasStore(path string, logf func(format string, args ...interface{})) (*aliasStore, error) {
+	as := &aliasStore{
+		path:    path,
+		aliases: make(map[string]string),
+	}
+
+	if err := as.load(logf); err != nil {
+		return nil, err
+	}
+
+	return as, nil
+}
+
+// load reads the store from disk, skipping invalid aliases. A missing file is
+// treated as an empty store.
+func (as *aliasStore) load(logf func(format string, args ...interface{})) error {
+	data, err := os.ReadFile(as.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read aliases: %w", err)
+	}
+
+	aliases := make(map[string]string)
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return fmt.Errorf("failed to parse aliases: %w", err)
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	for publicKey, alias := range aliases {
+		if err := validateAlias(alias); err != nil {
+			logf("Ignoring invalid alias for %s: %v", publicKey, err)
+			continue
+		}
+		as.aliases[strings.ToLower(publicKey)] = alias
+	}
+
+	return nil
+}
+
+// saveLocked writes the store to disk. The caller must hold as.mu.
+func (as *aliasStore) saveLocked() error {
+	data, err := json.MarshalIndent(as.aliases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal aliases: %w", err)
+	}
+
+	if err := config.WriteFileAtomic(as.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write aliases: %w", err)
+	}
+
+	return nil
+}
+
+// Set assigns alias to publicKey and returns the alias it replaces, if any.
+// Aliases must be unique across friends.
+func (as *aliasStore) Set(publicKey, alias string) (string, error) {
+	if err := validateAlias(alias); err != nil {
+		return "", err
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	for otherKey, otherAlias := range as.aliases {
+		if otherAlias == alias && otherKey != publicKey {
+			return "", fmt.Errorf("alias %q is already used by %s", alias, otherKey)
+		}
+	}
+
+	old := as.aliases[publicKey]
+	as.aliases[publicKey] = alias
+
+	return old, as.saveLocked()
+}
+
+// Remove deletes the alias for publicKey and returns it, or "" if none was set
+func (as *aliasStore) Remove(publicKey string) (string, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	old, exists := as.aliases[publicKey]
+	if !exists {
+		return "", nil
+	}
+	delete(as.aliases, publicKey)
+
+	return old, as.saveLocked()
+}
+
+// Get returns the alias for publicKey, or "" if none is set
+func (as *aliasStore) Get(publicKey string) string {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+	return as.aliases[publicKey]
+}
+
+// Lookup returns the public key that alias is assigned to
+func (as *aliasStore) Lookup(alias string) (string, bool) {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+
+	for publicKey, a := range as.aliases {
+		if a == alias {
+			return publicKey, true
+		}
+	}
+	return "", false
+}
+
+// All returns a copy of every alias keyed by public key
+func (as *aliasStore) All() map[string]string {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+
+	result := make(map[string]string, len(as.aliases))
+	for publicKey, alias := range as.aliases {
+		result[publicKey] = alias
+	}
+	return result
+}
+
+// validateAlias checks that alias can safely be used as a file name and cannot
+// be mistaken for a public key
+func validateAlias(alias string) error {
+	if alias == "" {
+		return fmt.Errorf("alias cannot be empty")
+	}
+	if len(alias) > maxAliasLength {
+		return fmt.Errorf("alias too long (max %d bytes, got %d)", maxAliasLength, len(alias))
+	}
+	if alias == "." || alias == ".." || strings.ContainsAny(alias, "/\x00") {
+		return fmt.Errorf("alias %q is not a valid file name", alias)
+	}
+	if strings.ContainsAny(alias, " \t\r\n") {
+		return fmt.Errorf("alias cannot contain whitespace")
+	}
+	if alias == AliasClear {
+		return fmt.Errorf("alias %q is reserved", alias)
+	}
+	if len(alias) == 64 {
+		if _, err := hex.DecodeString(alias); err == nil {
+			return fmt.Errorf("alias cannot be a public key")
+		}
+	}
+	return nil
+}
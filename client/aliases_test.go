@@ -0,0 +1,164 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// TestValidateAlias tests alias validation rules
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		name    string
+		alias   string
+		isValid bool
+	}{
+		{"simple alias", "alice", true},
+		{"alias with dash", "alice-smith", true},
+		{"alias with spaces", "alice smith", false},
+		{"reserved clear value", "-", false},
+		{"empty alias", "", false},
+		{"dot", ".", false},
+		{"dot dot", "..", false},
+		{"contains slash", "alice/bob", false},
+		{"leading whitespace", " alice", false},
+		{"too long", strings.Repeat("a", maxAliasLength+1), false},
+		{"looks like public key", strings.Repeat("ab", 32), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlias(tt.alias)
+			if (err == nil) != tt.isValid {
+				t.Errorf("Expected validity %v for %q, got error %v", tt.isValid, tt.alias, err)
+			}
+		})
+	}
+}
+
+// TestAliasStore tests setting, replacing, looking up and persisting aliases
+func TestAliasStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.json")
+	keyA := strings.Repeat("aa", 32)
+	keyB := strings.Repeat("bb", 32)
+
+	as, err := newAliasStore(path, t.Logf)
+	if err != nil {
+		t.Fatalf("newAliasStore failed: %v", err)
+	}
+
+	if _, err := as.Set(keyA, "alice"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := as.Set(keyB, "alice"); err == nil {
+		t.Error("Expected error when reusing an alias for a different friend")
+	}
+
+	old, err := as.Set(keyA, "ally")
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if old != "alice" {
+		t.Errorf("Expected replaced alias 'alice', got %q", old)
+	}
+
+	reloaded, err := newAliasStore(path, t.Logf)
+	if err != nil {
+		t.Fatalf("reloading store failed: %v", err)
+	}
+	if got, ok := reloaded.Lookup("ally"); !ok || got != keyA {
+		t.Errorf("Expected 'ally' to resolve to %s, got %q (found: %v)", keyA, got, ok)
+	}
+	if _, ok := reloaded.Lookup("alice"); ok {
+		t.Error("Expected old alias to be gone")
+	}
+
+	removed, err := reloaded.Remove(keyA)
+	if err != nil || removed != "ally" {
+		t.Errorf("Expected to remove 'ally', got %q (err: %v)", removed, err)
+	}
+	if reloaded.Get(keyA) != "" {
+		t.Error("Expected no alias after removal")
+	}
+}
+
+// TestAliasStoreSkipsInvalid tests that an alias no longer valid is skipped
+// on load and dropped on the next save instead of failing the store
+func TestAliasStoreSkipsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.json")
+	keyA := strings.Repeat("aa", 32)
+	keyB := strings.Repeat("bb", 32)
+	data := `{"` + keyA + `": "my friend", "` + keyB + `": "bob"}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	var logged []string
+	as, err := newAliasStore(path, func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	})
+	if err != nil {
+		t.Fatalf("newAliasStore failed: %v", err)
+	}
+	if as.Get(keyA) != "" || as.Get(keyB) != "bob" {
+		t.Errorf("Aliases = %q, %q; want the invalid one skipped", as.Get(keyA), as.Get(keyB))
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], keyA) {
+		t.Errorf("Logged %q, want one line about %s", logged, keyA)
+	}
+
+	if _, err := as.Set(keyB, "bobby"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if strings.Contains(string(saved), "my friend") {
+		t.Errorf("Saved aliases = %s, want the invalid alias dropped", saved)
+	}
+}
+
+// TestUpdateAliasLink tests by-name symlink maintenance
+func TestUpdateAliasLink(t *testing.T) {
+	cfg := &config.Config{ConfigDir: t.TempDir()}
+	fm := &FIFOManager{config: cfg}
+	friendID := strings.Repeat("cd", 32)
+
+	if err := os.MkdirAll(cfg.FriendDir(friendID), DirPerm); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	if err := fm.updateAliasLink(friendID, "", "carol"); err != nil {
+		t.Fatalf("updateAliasLink failed: %v", err)
+	}
+	target, err := filepath.EvalSymlinks(cfg.AliasLinkPath("carol"))
+	if err != nil {
+		t.Fatalf("EvalSymlinks failed: %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(cfg.FriendDir(friendID))
+	if target != want {
+		t.Errorf("Expected link to resolve to %s, got %s", want, target)
+	}
+
+	if err := fm.updateAliasLink(friendID, "carol", "caz"); err != nil {
+		t.Fatalf("updateAliasLink rename failed: %v", err)
+	}
+	if _, err := os.Lstat(cfg.AliasLinkPath("carol")); !os.IsNotExist(err) {
+		t.Error("Expected old alias link to be removed")
+	}
+	if _, err := os.Lstat(cfg.AliasLinkPath("caz")); err != nil {
+		t.Errorf("Expected new alias link to exist: %v", err)
+	}
+
+	if err := fm.updateAliasLink(friendID, "caz", ""); err != nil {
+		t.Fatalf("updateAliasLink clear failed: %v", err)
+	}
+	if _, err := os.Lstat(cfg.AliasLinkPath("caz")); !os.IsNotExist(err) {
+		t.Error("Expected alias link to be removed")
+	}
+}
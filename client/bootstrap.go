@@ -0,0 +1,384 @@
+// Package client implements bootstrap node health tracking for ratox-go
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/go-ratox/config"
+	"github.com/opd-ai/toxcore"
+)
+
+// The client cannot tell which bootstrap node answered, only whether it came
+// online. So a retry round sends to a single node, which is credited when the
+// client comes online; when a round tried every node, coming online credits
+// none of them and staying offline counts against each.
+const (
+	// bootstrapRetryInterval is how often the client checks its connection
+	// and bootstraps again while offline. A node that was tried counts as
+	// failed if the client is still offline one interval later.
+	bootstrapRetryInterval = time.Minute
+	// maxBootstrapBackoff bounds how long a failing node is skipped
+	maxBootstrapBackoff = time.Hour
+)
+
+// bootstrapNodeStats is the recorded health of one bootstrap node
+type bootstrapNodeStats struct {
+	Attempts            int       `json:"attempts"`
+	Successes           int       `json:"successes"`
+	Failures            int       `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastSuccess         time.Time `json:"last_success"`
+	LastFailure         time.Time `json:"last_failure"`
+	LastError           string    `json:"last_error,omitempty"`
+
+	// pending is set while the outcome of the last try is not known yet
+	pending bool
+}
+
+// bootstrapHealth persists the success and failure statistics of the
+// bootstrap nodes, keyed by "address:port", so a restart keeps preferring
+// the nodes that worked
+type bootstrapHealth struct {
+	path  string
+	nodes map[string]*bootstrapNodeStats
+	mu    sync.Mutex
+}
+
+// newBootstrapHealth creates a bootstrap health store backed by the given
+// file and loads any statistics already saved there
+func newBootstrapHealth(path string) (*bootstrapHealth, error) {
+	bh := &bootstrapHealth{
+		path:  path,
+		nodes: make(map[string]*bootstrapNodeStats),
+	}
+
+	if err := bh.load(); err != nil {
+		return nil, err
+	}
+
+	return bh, nil
+}
+
+// load reads the store from disk. A missing file is treated as an empty store.
+func (bh *bootstrapHealth) load() error {
+	data, err := os.ReadFile(bh.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read bootstrap stats: %w", err)
+	}
+
+	nodes := make(map[string]*bootstrapNodeStats)
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return fmt.Errorf("failed to parse bootstrap stats: %w", err)
+	}
+
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	bh.nodes = nodes
+
+	return nil
+}
+
+// saveLocked writes the store to disk. The caller must hold bh.mu.
+func (bh *bootstrapHealth) saveLocked() error {
+	data, err := json.MarshalIndent(bh.nodes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bootstrap stats: %w", err)
+	}
+
+	if err := config.WriteFileAtomic(bh.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write bootstrap stats: %w", err)
+	}
+
+	return nil
+}
+
+// bootstrapNodeKey identifies a bootstrap node in the store
+func bootstrapNodeKey(node config.BootstrapNode) string {
+	return net.JoinHostPort(node.Address, strconv.Itoa(int(node.Port)))
+}
+
+// statsLocked returns the statistics of a node, creating them if needed.
+// The caller must hold bh.mu.
+func (bh *bootstrapHealth) statsLocked(node config.BootstrapNode) *bootstrapNodeStats {
+	key := bootstrapNodeKey(node)
+	stats, ok := bh.nodes[key]
+	if !ok {
+		stats = &bootstrapNodeStats{}
+		bh.nodes[key] = stats
+	}
+	return stats
+}
+
+// bootstrapBackoff returns how long a node is skipped after the given number
+// of failures in a row, doubling from bootstrapRetryInterval
+func bootstrapBackoff(failures int) time.Duration {
+	backoff := bootstrapRetryInterval
+	for i := 1; i < failures && backoff < maxBootstrapBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBootstrapBackoff)
+}
+
+// nextAttempt returns when a failing node may be tried again, or the zero
+// time if it may be tried now
+func (s *bootstrapNodeStats) nextAttempt() time.Time {
+	if s.ConsecutiveFailures == 0 {
+		return time.Time{}
+	}
+	return s.LastFailure.Add(bootstrapBackoff(s.ConsecutiveFailures))
+}
+
+// Order returns nodes healthiest first: fewest failures in a row, then most
+// recent success, keeping the configured order otherwise
+func (bh *bootstrapHealth) Order(nodes []config.BootstrapNode) []config.BootstrapNode {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+
+	type ranked struct {
+		node  config.BootstrapNode
+		stats bootstrapNodeStats
+	}
+	ranking := make([]ranked, len(nodes))
+	for i, node := range nodes {
+		ranking[i].node = node
+		if s, ok := bh.nodes[bootstrapNodeKey(node)]; ok {
+			ranking[i].stats = *s
+		}
+	}
+
+	sort.SliceStable(ranking, func(a, b int) bool {
+		sa, sb := ranking[a].stats, ranking[b].stats
+		if sa.ConsecutiveFailures != sb.ConsecutiveFailures {
+			return sa.ConsecutiveFailures < sb.ConsecutiveFailures
+		}
+		return sa.LastSuccess.After(sb.LastSuccess)
+	})
+
+	ordered := make([]config.BootstrapNode, len(ranking))
+	for i, r := range ranking {
+		ordered[i] = r.node
+	}
+	return ordered
+}
+
+// Candidates returns the nodes for a retry round, healthiest first: those
+// that waited out their backoff at now. While every node is backing off there
+// are none, and the node whose backoff ends first is offered once it has.
+func (bh *bootstrapHealth) Candidates(nodes []config.BootstrapNode, now time.Time) []config.BootstrapNode {
+	ordered := bh.Order(nodes)
+
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+
+	due := make([]config.BootstrapNode, 0, len(ordered))
+	for _, node := range ordered {
+		if s, ok := bh.nodes[bootstrapNodeKey(node)]; ok && now.Before(s.nextAttempt()) {
+			continue
+		}
+		due = append(due, node)
+	}
+	return due
+}
+
+// Attempted records a try of a node. An error counts as a failure at once;
+// otherwise the outcome is settled once the connection is known.
+func (bh *bootstrapHealth) Attempted(node config.BootstrapNode, err error, now time.Time) {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+
+	s := bh.statsLocked(node)
+	s.Attempts++
+	if err != nil {
+		s.pending = false
+		s.failLocked(err.Error(), now)
+		return
+	}
+	s.pending = true
+}
+
+// failLocked records a failure. The caller must hold bh.mu.
+func (s *bootstrapNodeStats) failLocked(reason string, now time.Time) {
+	s.Failures++
+	s.ConsecutiveFailures++
+	s.LastFailure = now.UTC()
+	s.LastError = reason
+}
+
+// Settle decides the outcome of the pending tries. Offline, each node tried
+// counts as failed. Online, a lone node tried is credited with the success;
+// after a round that tried several, nobody is, since any of them may have
+// answered. It returns whether anything changed.
+func (bh *bootstrapHealth) Settle(online bool, now time.Time) bool {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+
+	var pending []*bootstrapNodeStats
+	for _, s := range bh.nodes {
+		if s.pending {
+			s.pending = false
+			pending = append(pending, s)
+		}
+	}
+
+	switch {
+	case !online:
+		for _, s := range pending {
+			s.failLocked("no connection", now)
+		}
+	case len(pending) == 1:
+		s := pending[0]
+		s.Successes++
+		s.ConsecutiveFailures = 0
+		s.LastSuccess = now.UTC()
+		s.LastError = ""
+	}
+	return len(pending) > 0
+}
+
+// Save forgets the nodes that are no longer configured and writes the store
+func (bh *bootstrapHealth) Save(nodes []config.BootstrapNode) error {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+
+	configured := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		configured[bootstrapNodeKey(node)] = true
+	}
+	for key := range bh.nodes {
+		if !configured[key] {
+			delete(bh.nodes, key)
+		}
+	}
+
+	return bh.saveLocked()
+}
+
+// StatusText lists nodes healthiest first as "<address:port> <state>
+// <successes> <failures> <last_success> <next_attempt> <last_error>", one
+// per line, with "-" for values that do not apply. A node that was only
+// tried together with others is "unknown".
+func (bh *bootstrapHealth) StatusText(nodes []config.BootstrapNode, now time.Time) string {
+	var sb strings.Builder
+	for _, node := range bh.Order(nodes) {
+		bh.mu.Lock()
+		var s bootstrapNodeStats
+		if stats, ok := bh.nodes[bootstrapNodeKey(node)]; ok {
+			s = *stats
+		}
+		bh.mu.Unlock()
+
+		state, next := "ok", "now"
+		switch {
+		case s.pending:
+			state, next = "pending", "-"
+		case s.Attempts == 0:
+			state = "untried"
+		case s.ConsecutiveFailures > 0:
+			state = "failed"
+			if at := s.nextAttempt(); now.Before(at) {
+				state, next = "backoff", at.Format(time.RFC3339)
+			}
+		case s.Successes == 0:
+			state = "unknown"
+		}
+
+		lastSuccess, lastError := "never", "-"
+		if !s.LastSuccess.IsZero() {
+			lastSuccess = s.LastSuccess.Format(time.RFC3339)
+		}
+		if s.LastError != "" {
+			lastError = strings.ReplaceAll(s.LastError, "\n", " ")
+		}
+
+		fmt.Fprintf(&sb, "%s %s %d %d %s %s %s\n", bootstrapNodeKey(node), state,
+			s.Successes, s.Failures, lastSuccess, next, lastError)
+	}
+	return sb.String()
+}
+
+// bootstrap connects to the DHT bootstrap nodes. With all set every node is
+// tried, healthiest first, regardless of backoff; otherwise only the first
+// node due for a retry that a request could be sent to, so that coming online
+// is credited to it.
+func (c *Client) bootstrap(all bool) {
+	nodes := c.liveConfig().BootstrapNodes
+	if all {
+		for _, node := range c.bootstrapHealth.Order(nodes) {
+			c.bootstrapNode(node)
+		}
+	} else {
+		for _, node := range c.bootstrapHealth.Candidates(nodes, time.Now()) {
+			if c.bootstrapNode(node) {
+				break
+			}
+		}
+	}
+
+	c.bootstrapChanged()
+}
+
+// bootstrapNode sends a bootstrap request to node and records the try,
+// returning whether the request was sent
+func (c *Client) bootstrapNode(node config.BootstrapNode) bool {
+	if c.config.Debug {
+		c.logf("Bootstrapping to %s:%d", node.Address, node.Port)
+	}
+
+	err := c.tox.Bootstrap(node.Address, node.Port, node.PublicKey)
+	if err != nil {
+		c.logf("Warning: failed to bootstrap to %s:%d: %v", node.Address, node.Port, err)
+	}
+	c.bootstrapHealth.Attempted(node, err, time.Now())
+	return err == nil
+}
+
+// maintainBootstrap bootstraps at startup, then settles the outcome of each
+// round and bootstraps again for as long as the client stays offline
+func (c *Client) maintainBootstrap() {
+	c.bootstrap(true)
+
+	ticker := time.NewTicker(bootstrapRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			online := c.tox.SelfGetConnectionStatus() != toxcore.ConnectionNone
+			changed := c.bootstrapHealth.Settle(online, time.Now())
+			if !online {
+				c.bootstrap(false)
+			} else if changed {
+				c.bootstrapChanged()
+			}
+		}
+	}
+}
+
+// bootstrapChanged saves the bootstrap statistics and updates
+// client/bootstrap_status
+func (c *Client) bootstrapChanged() {
+	if err := c.bootstrapHealth.Save(c.liveConfig().BootstrapNodes); err != nil {
+		c.logf("Failed to save bootstrap stats: %v", err)
+	}
+	if err := c.frontends.BootstrapChanged(); err != nil {
+		c.logf("Failed to update bootstrap status: %v", err)
+	}
+}
+
+// bootstrapStatusText describes the bootstrap nodes for client/bootstrap_status
+func (c *Client) bootstrapStatusText() string {
+	return c.bootstrapHealth.StatusText(c.liveConfig().BootstrapNodes, time.Now())
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// TestBootstrapBackoff tests the doubling and cap of the per-node backoff
+func TestBootstrapBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, bootstrapRetryInterval},
+		{2, 2 * bootstrapRetryInterval},
+		{3, 4 * bootstrapRetryInterval},
+		{20, maxBootstrapBackoff},
+		{1000, maxBootstrapBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := bootstrapBackoff(tt.failures); got != tt.want {
+			t.Errorf("bootstrapBackoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// TestBootstrapHealth tests settling tries, retry candidates, ordering and
+// persistence
+func TestBootstrapHealth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bootstrap_stats.json")
+	now := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	a := config.BootstrapNode{Address: "a.example", Port: 33445}
+	b := config.BootstrapNode{Address: "b.example", Port: 33445}
+	c := config.BootstrapNode{Address: "2001:db8::1", Port: 443}
+	nodes := []config.BootstrapNode{a, b, c}
+
+	bh, err := newBootstrapHealth(path)
+	if err != nil {
+		t.Fatalf("newBootstrapHealth failed: %v", err)
+	}
+
+	// a is sent a request but the client stays offline, b fails to resolve
+	bh.Attempted(a, nil, now)
+	bh.Attempted(b, errors.New("lookup b.example: no such host"), now)
+	if !bh.Settle(false, now.Add(bootstrapRetryInterval)) {
+		t.Error("Expected the pending try to be settled")
+	}
+	if bh.Settle(false, now.Add(bootstrapRetryInterval)) {
+		t.Error("Expected nothing left to settle")
+	}
+
+	// Nodes backing off are skipped until their backoff is over, so b, which
+	// failed first, is due before a
+	mid := now.Add(bootstrapRetryInterval + bootstrapRetryInterval/2)
+	candidates := bh.Candidates(nodes, mid)
+	if len(candidates) != 2 || candidates[0] != c || candidates[1] != b {
+		t.Errorf("Expected candidates c, b, got %v", candidates)
+	}
+	if candidates := bh.Candidates(nodes[:1], mid); len(candidates) != 0 {
+		t.Errorf("Expected no candidates while every node is backing off, got %v", candidates)
+	}
+	if candidates := bh.Candidates(nodes[:2], now.Add(2*bootstrapRetryInterval)); len(candidates) != 2 {
+		t.Errorf("Expected a and b once their backoff is over, got %v", candidates)
+	}
+
+	// Coming online after a round of several nodes credits none of them
+	later := now.Add(2 * bootstrapRetryInterval)
+	bh.Attempted(a, nil, later)
+	bh.Attempted(c, nil, later)
+	if !bh.Settle(true, later.Add(bootstrapRetryInterval)) {
+		t.Error("Expected the pending tries to be settled")
+	}
+
+	// Coming online after a lone try credits that node
+	later = later.Add(bootstrapRetryInterval)
+	bh.Attempted(c, nil, later)
+	bh.Settle(true, later.Add(bootstrapRetryInterval))
+
+	if err := bh.Save([]config.BootstrapNode{a, b, c}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded, err := newBootstrapHealth(path)
+	if err != nil {
+		t.Fatalf("reloading store failed: %v", err)
+	}
+
+	status := reloaded.StatusText(nodes, later.Add(2*bootstrapRetryInterval))
+	lines := strings.Split(strings.TrimSuffix(status, "\n"), "\n")
+	want := []string{
+		"[2001:db8::1]:443 ok 1 0 2024-02-29T12:04:00Z now -",
+		"a.example:33445 failed 0 1 never now no connection",
+		"b.example:33445 failed 0 1 never now lookup b.example: no such host",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Expected %d lines, got %q", len(want), status)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("Line %d: expected %q, got %q", i, want[i], lines[i])
+		}
+	}
+
+	// A node only ever tried alongside others is unknown, an untried one untried
+	bh, err = newBootstrapHealth(filepath.Join(t.TempDir(), "bootstrap_stats.json"))
+	if err != nil {
+		t.Fatalf("newBootstrapHealth failed: %v", err)
+	}
+	bh.Attempted(a, nil, now)
+	bh.Attempted(b, nil, now)
+	bh.Settle(true, now)
+	status = bh.StatusText(nodes, now)
+	for _, want := range []string{"a.example:33445 unknown 0 0 never now -\n", "[2001:db8::1]:443 untried 0 0 never now -\n"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("Status = %q, want it to contain %q", status, want)
+		}
+	}
+}
@@ -4,9 +4,12 @@ package client
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,13 +17,23 @@ import (
 	"github.com/opd-ai/toxcore"
 	"github.com/opd-ai/toxcore/async"
 	"github.com/opd-ai/toxcore/bootstrap"
+	"github.com/opd-ai/toxcore/crypto"
+)
+
+// maxFriendRequestMessageLength is the Tox limit for friend request messages in bytes
+const maxFriendRequestMessageLength = 1016
+
+// Limits on the client's own profile
+const (
+	maxNameLength          = config.MaxNameLength
+	maxStatusMessageLength = config.MaxStatusMessageLength
 )
 
 // Client represents the main Tox client with FIFO interface
 type Client struct {
 	tox          *toxcore.Tox
 	config       *config.Config
-	fifoManager  *FIFOManager
+	frontends    frontendSet
 	ctx          context.Context
 	cancel       context.CancelFunc
 	wg           sync.WaitGroup
@@ -28,13 +41,53 @@ type Client struct {
 	mu           sync.RWMutex
 	shutdownOnce sync.Once
 
+	// configMu guards the settings of config that can change while running
+	configMu sync.RWMutex
+
+	// Config file watching and reload results
+	reloader *configReloader
+
+	// saveKey encrypts the save file; nil stores it in plaintext
+	saveKey *passKey
+
+	// lock keeps other processes off the profile directory
+	lock *ProfileLock
+
+	// logger prefixes log lines with the profile name; nil uses the
+	// standard logger
+	logger *log.Logger
+
 	// Bootstrap server (optional)
 	bootstrapServer *bootstrap.Server
 
+	// JSON-RPC control socket (optional)
+	controlServer *ControlServer
+
+	// HTTP/REST and WebSocket gateway (optional)
+	httpServer *HTTPServer
+
+	// Event bus for control socket subscribers
+	events *EventBus
+
 	// Friend management
 	friends   map[uint32]*Friend
 	friendsMu sync.RWMutex
 
+	// Pending incoming friend requests
+	pendingRequests *requestStore
+
+	// Friend requests added while ratox-go was not running
+	outgoingRequests *outgoingStore
+
+	// Local friend nicknames
+	aliases *aliasStore
+
+	// Persistent friend last-seen times
+	lastSeen *lastSeenStore
+
+	// Bootstrap node success and failure statistics
+	bootstrapHealth *bootstrapHealth
+
 	// Conference management
 	conferences   map[uint32]*Conference
 	conferencesMu sync.RWMutex
@@ -76,6 +129,7 @@ type Friend struct {
 	Status        int // User status (0=none, 1=away, 2=busy)
 	StatusMessage string
 	Online        bool
+	Connection    toxcore.ConnectionStatus
 	LastSeen      time.Time
 }
 
@@ -85,8 +139,25 @@ type Conference struct {
 	Created time.Time
 }
 
-// New creates a new Tox client instance
+// New creates a new Tox client instance. It fails with a
+// *ProfileLockedError if another process is using the profile.
 func New(cfg *config.Config) (*Client, error) {
+	lock, err := LockProfile(cfg.ConfigDir)
+	if err != nil {
+		return nil, err
+	}
+	initialized := false
+	defer func() {
+		if !initialized {
+			lock.Release()
+		}
+	}()
+
+	// Write a new or migrated config.json only now that the profile is ours
+	if err := cfg.Persist(); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	client := &Client{
@@ -97,7 +168,11 @@ func New(cfg *config.Config) (*Client, error) {
 		conferences:       make(map[uint32]*Conference),
 		incomingTransfers: make(map[string]*incomingTransfer),
 		outgoingTransfers: make(map[string]*outgoingTransfer),
+		events:            NewEventBus(),
 		shutdown:          make(chan struct{}),
+		reloader:          newConfigReloader(cfg),
+		lock:              lock,
+		logger:            newProfileLogger(cfg),
 	}
 
 	// Initialize Tox
@@ -106,9 +181,59 @@ func New(cfg *config.Config) (*Client, error) {
 		return nil, fmt.Errorf("failed to initialize Tox: %w", err)
 	}
 
-	// Initialize FIFO manager
-	fifoManager := NewFIFOManager(client)
-	client.fifoManager = fifoManager
+	// Load pending friend requests
+	pendingRequests, err := newRequestStore(cfg.PendingRequestsPath())
+	if err != nil {
+		client.tox.Kill()
+		cancel()
+		return nil, fmt.Errorf("failed to load pending friend requests: %w", err)
+	}
+	client.pendingRequests = pendingRequests
+
+	// Load friend requests queued while ratox-go was not running
+	outgoingRequests, err := newOutgoingStore(cfg.OutgoingRequestsPath())
+	if err != nil {
+		client.tox.Kill()
+		cancel()
+		return nil, fmt.Errorf("failed to load outgoing friend requests: %w", err)
+	}
+	client.outgoingRequests = outgoingRequests
+
+	// Load friend aliases
+	aliases, err := newAliasStore(cfg.AliasesPath(), client.logf)
+	if err != nil {
+		client.tox.Kill()
+		cancel()
+		return nil, fmt.Errorf("failed to load friend aliases: %w", err)
+	}
+	client.aliases = aliases
+
+	// Load friend last-seen times
+	lastSeen, err := newLastSeenStore(cfg.LastSeenPath())
+	if err != nil {
+		client.tox.Kill()
+		cancel()
+		return nil, fmt.Errorf("failed to load friend last seen times: %w", err)
+	}
+	client.lastSeen = lastSeen
+
+	// Load bootstrap node health
+	bootstrapHealth, err := newBootstrapHealth(cfg.BootstrapStatsPath())
+	if err != nil {
+		client.tox.Kill()
+		cancel()
+		return nil, fmt.Errorf("failed to load bootstrap stats: %w", err)
+	}
+	client.bootstrapHealth = bootstrapHealth
+
+	// Initialize the frontends exposing the profile tree
+	frontends, err := newFrontends(client, cfg)
+	if err != nil {
+		client.tox.Kill()
+		cancel()
+		return nil, err
+	}
+	client.frontends = frontends
 
 	// Initialize bootstrap server if configured
 	if cfg.BootstrapServer.Enabled {
@@ -119,6 +244,10 @@ func New(cfg *config.Config) (*Client, error) {
 		}
 	}
 
+	// Send the requests queued offline before the friends are listed, as
+	// resending one renumbers the friend
+	client.sendOutgoingRequests()
+
 	// Load existing friends
 	if err := client.loadFriends(); err != nil {
 		client.tox.Kill()
@@ -130,12 +259,31 @@ func New(cfg *config.Config) (*Client, error) {
 	client.setupCallbacks()
 
 	if cfg.Debug {
-		log.Printf("Tox client initialized. Tox ID: %s", client.GetToxID())
+		client.logf("Tox client initialized. Tox ID: %s", client.GetToxID())
 	}
 
+	initialized = true
 	return client, nil
 }
 
+// newProfileLogger returns a logger prefixing lines with the profile name,
+// or nil to use the standard logger
+func newProfileLogger(cfg *config.Config) *log.Logger {
+	if cfg.ProfileName == "" {
+		return nil
+	}
+	return log.New(log.Writer(), "["+cfg.ProfileName+"] ", log.Flags()|log.Lmsgprefix)
+}
+
+// logf logs through the profile's logger. It is safe on a nil client.
+func (c *Client) logf(format string, args ...interface{}) {
+	if c == nil || c.logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	c.logger.Printf(format, args...)
+}
+
 // initTox initializes the Tox instance
 func (c *Client) initTox() error {
 	if err := c.config.ValidateTransport(); err != nil {
@@ -154,9 +302,9 @@ func (c *Client) configureTransportOptions() *toxcore.Options {
 		options.UDPEnabled = false
 		if c.config.Debug {
 			if c.config.Transport.TorEnabled && c.config.Transport.I2PEnabled {
-				log.Printf("Tor and I2P simultaneously enabled: disabling UDP (DHT packets will route through I2P)")
+				c.logf("Tor and I2P simultaneously enabled: disabling UDP (DHT packets will route through I2P)")
 			} else {
-				log.Printf("Anonymizing overlay enabled, disabling UDP")
+				c.logf("Anonymizing overlay enabled, disabling UDP")
 			}
 		}
 	} else {
@@ -170,11 +318,21 @@ func (c *Client) configureTransportOptions() *toxcore.Options {
 	return options
 }
 
-// createToxInstance creates or restores a Tox instance
+// createToxInstance creates or restores a Tox instance, falling back to the
+// newest valid backup if the save file is damaged
 func (c *Client) createToxInstance(options *toxcore.Options) error {
 	saveData, readErr := os.ReadFile(c.config.SaveFile)
 	if readErr == nil {
-		return c.restoreToxFromSavedata(options, saveData)
+		err := c.restoreToxFromSavedata(options, saveData)
+		if err == nil || errors.Is(err, ErrPassphraseRequired) || errors.Is(err, ErrSaveDataNotEncrypted) {
+			return err
+		}
+		// A wrong passphrase cannot be told from damage, so try the backups:
+		// they only load if the passphrase is right
+		return c.restoreToxFromBackup(options, err)
+	}
+	if !os.IsNotExist(readErr) {
+		return c.restoreToxFromBackup(options, fmt.Errorf("failed to read savedata: %w", readErr))
 	}
 	return c.createNewToxInstance(options)
 }
@@ -182,18 +340,31 @@ func (c *Client) createToxInstance(options *toxcore.Options) error {
 // restoreToxFromSavedata restores Tox from existing save data
 func (c *Client) restoreToxFromSavedata(options *toxcore.Options, saveData []byte) error {
 	if c.config.Debug {
-		log.Printf("Loading existing save data from %s", c.config.SaveFile)
+		c.logf("Loading existing save data from %s", c.config.SaveFile)
+	}
+	plain, key, err := c.openSaveData(saveData)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", c.config.SaveFile, err)
 	}
-	tox, err := toxcore.NewFromSavedata(options, saveData)
+	tox, err := toxcore.NewFromSavedata(options, plain)
 	if err != nil {
 		return fmt.Errorf("failed to restore Tox from savedata: %w", err)
 	}
 	c.tox = tox
+	c.saveKey = key
 	return nil
 }
 
 // createNewToxInstance creates a new Tox instance
 func (c *Client) createNewToxInstance(options *toxcore.Options) error {
+	if c.config.Passphrase != "" {
+		key, err := newPassKey(c.config.Passphrase)
+		if err != nil {
+			return err
+		}
+		c.saveKey = key
+	}
+
 	tox, err := toxcore.New(options)
 	if err != nil {
 		return fmt.Errorf("failed to create Tox instance: %w", err)
@@ -201,13 +372,13 @@ func (c *Client) createNewToxInstance(options *toxcore.Options) error {
 
 	if err := tox.SelfSetName(c.config.Name); err != nil {
 		if c.config.Debug {
-			log.Printf("Warning: failed to set name: %v", err)
+			c.logf("Warning: failed to set name: %v", err)
 		}
 	}
 
 	if err := tox.SelfSetStatusMessage(c.config.StatusMessage); err != nil {
 		if c.config.Debug {
-			log.Printf("Warning: failed to set status message: %v", err)
+			c.logf("Warning: failed to set status message: %v", err)
 		}
 	}
 
@@ -289,7 +460,7 @@ func (c *Client) loadFriends() error {
 			Name:      toxFriend.Name,
 			Status:    0, // Default status
 			Online:    false,
-			LastSeen:  time.Now(),
+			LastSeen:  c.lastSeen.Get(hex.EncodeToString(toxFriend.PublicKey[:])),
 		}
 
 		c.friendsMu.Lock()
@@ -298,12 +469,13 @@ func (c *Client) loadFriends() error {
 
 		// Create friend directory and FIFOs
 		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-		if err := c.fifoManager.CreateFriendFIFOs(friendIDStr); err != nil {
-			log.Printf("Warning: failed to create FIFOs for friend %s: %v", friendIDStr, err)
+		if err := c.frontends.FriendAdded(friendIDStr); err != nil {
+			c.logf("Warning: failed to create FIFOs for friend %s: %v", friendIDStr, err)
 		}
+		c.refreshFriendInfo(friendID)
 
 		if c.config.Debug {
-			log.Printf("Loaded friend: %s (%s)", friend.Name, friendIDStr)
+			c.logf("Loaded friend: %s (%s)", friend.Name, friendIDStr)
 		}
 	}
 
@@ -323,33 +495,70 @@ func (c *Client) startBootstrapServer() error {
 	if c.config.Debug {
 		bsCfg := c.config.BootstrapServer
 		if bsCfg.ClearnetEnabled {
-			log.Printf("Bootstrap server clearnet: %s", c.bootstrapServer.GetClearnetAddr())
+			c.logf("Bootstrap server clearnet: %s", c.bootstrapServer.GetClearnetAddr())
 		}
 		if bsCfg.OnionEnabled {
-			log.Printf("Bootstrap server onion: %s", c.bootstrapServer.GetOnionAddr())
+			c.logf("Bootstrap server onion: %s", c.bootstrapServer.GetOnionAddr())
 		}
 		if bsCfg.I2PEnabled {
-			log.Printf("Bootstrap server i2p: %s", c.bootstrapServer.GetI2PAddr())
+			c.logf("Bootstrap server i2p: %s", c.bootstrapServer.GetI2PAddr())
 		}
-		log.Printf("Bootstrap server public key: %s", c.bootstrapServer.GetPublicKeyHex())
+		c.logf("Bootstrap server public key: %s", c.bootstrapServer.GetPublicKeyHex())
+	}
+	return nil
+}
+
+// startControlServer opens the JSON-RPC control socket if configured
+func (c *Client) startControlServer() error {
+	if !c.config.ControlSocket {
+		return nil
+	}
+
+	server := NewControlServer(c, c.config.ControlSocketPath())
+	if err := server.Listen(); err != nil {
+		return fmt.Errorf("failed to start control socket: %w", err)
+	}
+	c.controlServer = server
+
+	if c.config.Debug {
+		c.logf("Control socket listening on %s", c.config.ControlSocketPath())
 	}
 	return nil
 }
 
+// startHTTPServer opens the HTTP gateway if configured
+func (c *Client) startHTTPServer() error {
+	if !c.config.HTTP.Enabled {
+		return nil
+	}
+
+	server, err := NewHTTPServer(c)
+	if err != nil {
+		return fmt.Errorf("failed to start HTTP gateway: %w", err)
+	}
+	if err := server.Listen(); err != nil {
+		return fmt.Errorf("failed to start HTTP gateway: %w", err)
+	}
+	c.httpServer = server
+
+	c.logf("HTTP gateway listening on http://%s (token in %s)", server.Addr(), c.config.HTTPTokenPath())
+	return nil
+}
+
 // startBackgroundWorkers launches all background goroutines
 func (c *Client) startBackgroundWorkers() {
-	// Start FIFO manager
+	// Serve the frontends
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
-		c.fifoManager.Run(c.ctx)
+		c.frontends.Serve(c.ctx)
 	}()
 
-	// Bootstrap to DHT
+	// Bootstrap to DHT, and again while offline
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
-		c.bootstrap()
+		c.maintainBootstrap()
 	}()
 
 	// Auto-save periodically
@@ -372,6 +581,40 @@ func (c *Client) startBackgroundWorkers() {
 		defer c.wg.Done()
 		c.monitorStalledTransfers()
 	}()
+
+	// Reload the configuration file when it changes
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.watchConfig()
+	}()
+
+	// Rotate nospam on a schedule if configured
+	if c.config.NospamRotationHours > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.rotateNospamPeriodically()
+		}()
+	}
+
+	// Serve the control socket if it was opened
+	if c.controlServer != nil {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.controlServer.Serve(c.ctx)
+		}()
+	}
+
+	// Serve the HTTP gateway if it was opened
+	if c.httpServer != nil {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.httpServer.Serve(c.ctx)
+		}()
+	}
 }
 
 // Run starts the Tox client main loop
@@ -394,6 +637,18 @@ func (c *Client) Run() error {
 		return err
 	}
 
+	if err := c.startControlServer(); err != nil {
+		return err
+	}
+
+	if err := c.startHTTPServer(); err != nil {
+		return err
+	}
+
+	if err := c.frontends.Start(); err != nil {
+		return err
+	}
+
 	c.startBackgroundWorkers()
 
 	// Main Tox iteration loop with dynamic interval
@@ -412,6 +667,15 @@ func (c *Client) Run() error {
 
 // initBootstrapServer initialises the bootstrap.Server from config.
 func (c *Client) initBootstrapServer() error {
+	// The onion endpoint only reads its control port from the environment,
+	// which every client in the process shares; a manifest refuses profiles
+	// that disagree on it
+	if addr := c.config.BootstrapServer.TorControlAddr; addr != "" {
+		if err := os.Setenv("TOR_CONTROL_ADDR", addr); err != nil {
+			return fmt.Errorf("failed to set Tor control address: %w", err)
+		}
+	}
+
 	bsCfg := &bootstrap.Config{
 		ClearnetEnabled:   c.config.BootstrapServer.ClearnetEnabled,
 		ClearnetPort:      c.config.BootstrapServer.ClearnetPort,
@@ -429,19 +693,6 @@ func (c *Client) initBootstrapServer() error {
 	return nil
 }
 
-// bootstrap connects to DHT bootstrap nodes
-func (c *Client) bootstrap() {
-	for _, node := range c.config.BootstrapNodes {
-		if c.config.Debug {
-			log.Printf("Bootstrapping to %s:%d", node.Address, node.Port)
-		}
-
-		if err := c.tox.Bootstrap(node.Address, node.Port, node.PublicKey); err != nil {
-			log.Printf("Warning: failed to bootstrap to %s:%d: %v", node.Address, node.Port, err)
-		}
-	}
-}
-
 // autoSave periodically saves Tox state to disk
 func (c *Client) autoSave() {
 	ticker := time.NewTicker(5 * time.Minute)
@@ -469,15 +720,33 @@ func (c *Client) updateConnectionStatus() {
 		case <-c.ctx.Done():
 			return
 		case <-ticker.C:
-			if err := c.fifoManager.createConnectionStatusFile(); err != nil {
+			if err := c.frontends.ConnectionChanged(); err != nil {
 				if c.config.Debug {
-					log.Printf("Failed to update connection status: %v", err)
+					c.logf("Failed to update connection status: %v", err)
 				}
 			}
 		}
 	}
 }
 
+// rotateNospamPeriodically replaces the nospam with a random value every
+// NospamRotationHours
+func (c *Client) rotateNospamPeriodically() {
+	ticker := time.NewTicker(time.Duration(c.config.NospamRotationHours) * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.RotateNospam(); err != nil {
+				c.logf("Scheduled nospam rotation failed: %v", err)
+			}
+		}
+	}
+}
+
 // monitorStalledTransfers checks for and cancels stalled file transfers
 func (c *Client) checkIncomingTransfers(now time.Time, timeout time.Duration) {
 	for key, transfer := range c.incomingTransfers {
@@ -485,7 +754,7 @@ func (c *Client) checkIncomingTransfers(now time.Time, timeout time.Duration) {
 			continue
 		}
 
-		log.Printf("Incoming transfer stalled: %s (last activity: %v ago)",
+		c.logf("Incoming transfer stalled: %s (last activity: %v ago)",
 			transfer.Filename, now.Sub(transfer.LastActivity))
 
 		var friendID, fileNumber uint32
@@ -505,7 +774,7 @@ func (c *Client) checkOutgoingTransfers(now time.Time, timeout time.Duration) {
 			continue
 		}
 
-		log.Printf("Outgoing transfer stalled: %s (last activity: %v ago)",
+		c.logf("Outgoing transfer stalled: %s (last activity: %v ago)",
 			transfer.Filename, now.Sub(transfer.LastActivity))
 
 		var friendID, fileNumber uint32
@@ -543,26 +812,22 @@ func (c *Client) monitorStalledTransfers() {
 // saveToxData saves Tox state to disk
 func (c *Client) saveToxData() {
 	saveData := c.tox.GetSavedata()
-	if err := os.WriteFile(c.config.SaveFile, saveData, 0o600); err != nil {
-		log.Printf("Error saving Tox data: %v", err)
+	if err := c.writeSaveData(saveData); err != nil {
+		c.logf("Error saving Tox data: %v", err)
 	} else if c.config.Debug {
-		log.Printf("Tox data saved to %s", c.config.SaveFile)
+		c.logf("Tox data saved to %s", c.config.SaveFile)
 	}
 }
 
-// Shutdown gracefully shuts down the client. It is safe to call multiple times.
+// Shutdown gracefully shuts down the client, also after Run returned an
+// error, and releases the profile. It is safe to call multiple times.
 func (c *Client) Shutdown() {
 	c.shutdownOnce.Do(func() {
-		c.mu.Lock()
-		running := c.running
-		c.mu.Unlock()
-
-		if !running {
-			return
-		}
+		// Released last, once the final save is written
+		defer c.lock.Release()
 
 		if c.config.Debug {
-			log.Println("Shutting down client...")
+			c.logf("Shutting down client...")
 		}
 
 		// Signal shutdown
@@ -574,6 +839,9 @@ func (c *Client) Shutdown() {
 		// Wait for all goroutines to finish
 		c.wg.Wait()
 
+		// Friends still online were last seen now
+		c.recordOnlineLastSeen()
+
 		// Save final state
 		c.saveToxData()
 
@@ -585,12 +853,12 @@ func (c *Client) Shutdown() {
 		// Stop bootstrap server if running
 		if c.bootstrapServer != nil {
 			if err := c.bootstrapServer.Stop(); err != nil && c.config.Debug {
-				log.Printf("Warning: bootstrap server stop error: %v", err)
+				c.logf("Warning: bootstrap server stop error: %v", err)
 			}
 		}
 
 		if c.config.Debug {
-			log.Println("Client shutdown complete")
+			c.logf("Client shutdown complete")
 		}
 	})
 }
@@ -600,6 +868,128 @@ func (c *Client) GetToxID() string {
 	return c.tox.SelfGetAddress()
 }
 
+// SelfInfo summarises the local Tox identity and client state
+type SelfInfo struct {
+	ToxID           string `json:"tox_id"`
+	Name            string `json:"name"`
+	StatusMessage   string `json:"status_message"`
+	Connection      string `json:"connection"`
+	Friends         int    `json:"friends"`
+	FriendsOnline   int    `json:"friends_online"`
+	PendingRequests int    `json:"pending_requests"`
+	Transfers       int    `json:"transfers"`
+}
+
+// Status returns a summary of the local Tox identity and client state
+func (c *Client) Status() SelfInfo {
+	info := SelfInfo{
+		ToxID:           c.GetToxID(),
+		Name:            c.tox.SelfGetName(),
+		StatusMessage:   c.tox.SelfGetStatusMessage(),
+		Connection:      connectionTypeString(c.tox.SelfGetConnectionStatus()),
+		PendingRequests: len(c.PendingRequests()),
+	}
+
+	c.friendsMu.RLock()
+	info.Friends = len(c.friends)
+	for _, friend := range c.friends {
+		if friend.Online {
+			info.FriendsOnline++
+		}
+	}
+	c.friendsMu.RUnlock()
+
+	c.transfersMu.RLock()
+	info.Transfers = len(c.incomingTransfers) + len(c.outgoingTransfers)
+	c.transfersMu.RUnlock()
+
+	return info
+}
+
+// Friends returns a snapshot of all friends ordered by friend number
+func (c *Client) Friends() []Friend {
+	c.friendsMu.RLock()
+	friends := make([]Friend, 0, len(c.friends))
+	for _, friend := range c.friends {
+		friends = append(friends, *friend)
+	}
+	c.friendsMu.RUnlock()
+
+	sort.Slice(friends, func(i, j int) bool { return friends[i].ID < friends[j].ID })
+	return friends
+}
+
+// FriendByKey returns a snapshot of the friend with the given hex public key
+func (c *Client) FriendByKey(friendIDStr string) (Friend, bool) {
+	c.friendsMu.RLock()
+	defer c.friendsMu.RUnlock()
+
+	for _, friend := range c.friends {
+		if hex.EncodeToString(friend.PublicKey[:]) == friendIDStr {
+			return *friend, true
+		}
+	}
+	return Friend{}, false
+}
+
+// Conferences returns the IDs of the conferences created in this session in
+// ascending order
+func (c *Client) Conferences() []uint32 {
+	c.conferencesMu.RLock()
+	ids := make([]uint32, 0, len(c.conferences))
+	for id := range c.conferences {
+		ids = append(ids, id)
+	}
+	c.conferencesMu.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// recordOnlineLastSeen stamps every online friend as seen now and persists
+// the times, so a restart does not lose how recently they were around
+func (c *Client) recordOnlineLastSeen() {
+	now := time.Now()
+	times := make(map[string]time.Time)
+
+	c.friendsMu.Lock()
+	for _, friend := range c.friends {
+		if friend.Online {
+			friend.LastSeen = now
+			times[hex.EncodeToString(friend.PublicKey[:])] = now
+		}
+	}
+	c.friendsMu.Unlock()
+
+	if len(times) == 0 {
+		return
+	}
+	if err := c.lastSeen.SetMany(times); err != nil {
+		c.logf("Failed to save last seen times: %v", err)
+	}
+}
+
+// refreshFriendInfo rewrites the metadata files in a friend's directory from
+// the current in-memory state
+func (c *Client) refreshFriendInfo(friendID uint32) {
+	c.friendsMu.RLock()
+	friend, exists := c.friends[friendID]
+	var snapshot Friend
+	if exists {
+		snapshot = *friend
+	}
+	c.friendsMu.RUnlock()
+
+	if !exists || snapshot.PublicKey == ([32]byte{}) {
+		return
+	}
+
+	friendIDStr := hex.EncodeToString(snapshot.PublicKey[:])
+	if err := c.frontends.FriendChanged(friendIDStr, &snapshot); err != nil {
+		c.logf("Failed to write info files for friend %s: %v", friendIDStr, err)
+	}
+}
+
 // GetFriend returns friend information by ID
 func (c *Client) GetFriend(friendID uint32) (*Friend, bool) {
 	c.friendsMu.RLock()
@@ -623,8 +1013,18 @@ func (c *Client) SendMessage(friendID uint32, message string, messageType toxcor
 	return c.tox.SendFriendMessage(friendID, message, messageType)
 }
 
-// AddFriend adds a friend by Tox ID
+// AddFriend sends a friend request carrying message to a full 76-character
+// Tox ID and creates the friend's directory and FIFOs immediately
 func (c *Client) AddFriend(toxID, message string) (uint32, error) {
+	id, err := crypto.ToxIDFromString(toxID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Tox ID: %w", err)
+	}
+
+	if len(message) > maxFriendRequestMessageLength {
+		return 0, fmt.Errorf("friend request message too long (max %d bytes, got %d)", maxFriendRequestMessageLength, len(message))
+	}
+
 	friendID, err := c.tox.AddFriend(toxID, message)
 	if err != nil {
 		return 0, err
@@ -633,26 +1033,38 @@ func (c *Client) AddFriend(toxID, message string) (uint32, error) {
 	// Create a basic friend entry - we'll get more info via callbacks
 	friend := &Friend{
 		ID:        friendID,
-		PublicKey: [32]byte{}, // Will be filled by callback
-		Name:      "Unknown",  // Will be updated by callback
-		Status:    0,          // Default status
+		PublicKey: id.PublicKey,
+		Name:      "Unknown", // Will be updated by callback
+		Status:    0,         // Default status
 		Online:    false,
-		LastSeen:  time.Now(),
 	}
 
 	c.friendsMu.Lock()
 	c.friends[friendID] = friend
 	c.friendsMu.Unlock()
 
+	// Create friend directory and FIFOs
+	friendIDStr := hex.EncodeToString(friend.PublicKey[:])
+	if err := c.frontends.FriendAdded(friendIDStr); err != nil {
+		c.logf("Warning: failed to create FIFOs for friend %s: %v", friendIDStr, err)
+	}
+	c.refreshFriendInfo(friendID)
+	c.refreshFriendListings()
+
+	// A request they sent us earlier is answered by this one
+	c.removePendingRequest(friendIDStr)
+
 	// Save state
 	c.saveToxData()
 
 	if c.config.Debug {
-		log.Printf("Added friend with ID: %d", friendID)
+		c.logf("Sent friend request to %s (friend ID: %d)", friendIDStr, friendID)
 	}
 
 	return friendID, nil
-} // AcceptFriendRequest accepts a friend request by public key
+}
+
+// AcceptFriendRequest accepts a friend request by public key
 func (c *Client) AcceptFriendRequest(publicKey [32]byte) (uint32, error) {
 	friendID, err := c.tox.AddFriendByPublicKey(publicKey)
 	if err != nil {
@@ -666,7 +1078,6 @@ func (c *Client) AcceptFriendRequest(publicKey [32]byte) (uint32, error) {
 		Name:      "Unknown", // Will be updated by callback
 		Status:    0,         // Default status
 		Online:    false,
-		LastSeen:  time.Now(),
 	}
 
 	c.friendsMu.Lock()
@@ -675,23 +1086,169 @@ func (c *Client) AcceptFriendRequest(publicKey [32]byte) (uint32, error) {
 
 	// Create friend directory and FIFOs
 	friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-	if err := c.fifoManager.CreateFriendFIFOs(friendIDStr); err != nil {
-		log.Printf("Warning: failed to create FIFOs for friend %s: %v", friendIDStr, err)
+	if err := c.frontends.FriendAdded(friendIDStr); err != nil {
+		c.logf("Warning: failed to create FIFOs for friend %s: %v", friendIDStr, err)
 	}
+	c.refreshFriendInfo(friendID)
+	c.refreshFriendListings()
+
+	// The request is no longer pending once accepted
+	c.removePendingRequest(friendIDStr)
 
 	// Save state
 	c.saveToxData()
 
 	if c.config.Debug {
-		log.Printf("Accepted friend request: %s", friendIDStr)
+		c.logf("Accepted friend request: %s", friendIDStr)
 	}
 
 	return friendID, nil
 }
 
+// RejectFriendRequest discards a pending friend request without adding the sender
+func (c *Client) RejectFriendRequest(publicKey [32]byte) error {
+	friendIDStr := hex.EncodeToString(publicKey[:])
+
+	removed, err := c.pendingRequests.Remove(friendIDStr)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("no pending friend request from %s", friendIDStr)
+	}
+
+	if err := c.frontends.RequestsChanged(); err != nil {
+		c.logf("Failed to update pending requests file: %v", err)
+	}
+
+	if c.config.Debug {
+		c.logf("Rejected friend request: %s", friendIDStr)
+	}
+
+	return nil
+}
+
+// HasPendingRequest reports whether a friend request from publicKey is pending
+func (c *Client) HasPendingRequest(publicKey [32]byte) bool {
+	return c.pendingRequests.Has(hex.EncodeToString(publicKey[:]))
+}
+
+// PendingRequests returns all pending friend requests, oldest first
+func (c *Client) PendingRequests() []PendingRequest {
+	return c.pendingRequests.List()
+}
+
+// removePendingRequest drops a request from the pending store, if present,
+// and refreshes the requests_pending file
+func (c *Client) removePendingRequest(friendIDStr string) {
+	removed, err := c.pendingRequests.Remove(friendIDStr)
+	if err != nil {
+		c.logf("Failed to update pending friend requests: %v", err)
+	}
+	if !removed {
+		return
+	}
+
+	if err := c.frontends.RequestsChanged(); err != nil {
+		c.logf("Failed to update pending requests file: %v", err)
+	}
+}
+
+// SetFriendAlias assigns a local nickname to a friend and points the
+// by-name symlink at the friend's directory
+func (c *Client) SetFriendAlias(friendIDStr, alias string) error {
+	old, err := c.aliases.Set(friendIDStr, alias)
+	if err != nil {
+		return err
+	}
+
+	if err := c.frontends.AliasChanged(friendIDStr, old, alias); err != nil {
+		return err
+	}
+	c.refreshFriendListings()
+
+	if c.config.Debug {
+		c.logf("Friend %s aliased as %q", friendIDStr, alias)
+	}
+
+	return nil
+}
+
+// ClearFriendAlias removes a friend's nickname and its by-name symlink
+func (c *Client) ClearFriendAlias(friendIDStr string) error {
+	old, err := c.aliases.Remove(friendIDStr)
+	if err != nil {
+		return err
+	}
+
+	if err := c.frontends.AliasChanged(friendIDStr, old, ""); err != nil {
+		return err
+	}
+	c.refreshFriendListings()
+
+	return nil
+}
+
+// RemoveFriend deletes a friend along with their alias, last seen time and
+// directory
+func (c *Client) RemoveFriend(friendNum uint32, friendIDStr string) error {
+	if err := c.tox.DeleteFriend(friendNum); err != nil {
+		return fmt.Errorf("failed to delete friend %d: %w", friendNum, err)
+	}
+
+	c.friendsMu.Lock()
+	delete(c.friends, friendNum)
+	c.friendsMu.Unlock()
+
+	if err := c.ClearFriendAlias(friendIDStr); err != nil {
+		c.logf("Failed to remove alias for friend %s: %v", friendIDStr, err)
+	}
+
+	if err := c.lastSeen.Remove(friendIDStr); err != nil {
+		c.logf("Failed to remove last seen time for friend %s: %v", friendIDStr, err)
+	}
+	c.refreshFriendListings()
+	if err := c.frontends.FriendRemoved(friendIDStr); err != nil {
+		c.logf("Failed to remove friend %s from frontends: %v", friendIDStr, err)
+	}
+
+	c.saveToxData()
+
+	c.logf("Friend %s removed successfully", friendIDStr)
+	return nil
+}
+
+// FriendAlias returns the nickname for a friend, or "" if none is set
+func (c *Client) FriendAlias(friendIDStr string) string {
+	return c.aliases.Get(friendIDStr)
+}
+
+// ResolveFriend looks up a friend by alias or hex public key and returns the
+// friend number and hex public key
+func (c *Client) ResolveFriend(nameOrKey string) (uint32, string, error) {
+	friendIDStr, isAlias := c.aliases.Lookup(nameOrKey)
+	if !isAlias {
+		friendIDStr = strings.ToLower(nameOrKey)
+	}
+
+	publicKeyBytes, err := hex.DecodeString(friendIDStr)
+	if err != nil || len(publicKeyBytes) != 32 {
+		return 0, "", fmt.Errorf("unknown alias or invalid public key: %s", nameOrKey)
+	}
+
+	var publicKey [32]byte
+	copy(publicKey[:], publicKeyBytes)
+
+	friendNum, err := c.tox.FriendByPublicKey(publicKey)
+	if err != nil {
+		return 0, "", fmt.Errorf("friend not found: %s (%w)", nameOrKey, err)
+	}
+
+	return friendNum, friendIDStr, nil
+}
+
 // UpdateSelfName updates the client's display name
 func (c *Client) UpdateSelfName(name string) error {
-	const maxNameLength = 128
 	if len(name) > maxNameLength {
 		return fmt.Errorf("name exceeds maximum length of %d characters", maxNameLength)
 	}
@@ -700,13 +1257,11 @@ func (c *Client) UpdateSelfName(name string) error {
 		return err
 	}
 
-	c.config.Name = name
-	return c.config.Save()
+	return c.saveConfigSetting(func(cfg *config.Config) { cfg.Name = name })
 }
 
 // UpdateSelfStatusMessage updates the client's status message
 func (c *Client) UpdateSelfStatusMessage(message string) error {
-	const maxStatusMessageLength = 1007
 	if len(message) > maxStatusMessageLength {
 		return fmt.Errorf("status message exceeds maximum length of %d characters", maxStatusMessageLength)
 	}
@@ -715,8 +1270,35 @@ func (c *Client) UpdateSelfStatusMessage(message string) error {
 		return err
 	}
 
-	c.config.StatusMessage = message
-	return c.config.Save()
+	return c.saveConfigSetting(func(cfg *config.Config) { cfg.StatusMessage = message })
+}
+
+// SetNospam changes the nospam part of the Tox ID, keeping the key pair and
+// all existing friends. The id file and save data are updated immediately.
+func (c *Client) SetNospam(nospam [4]byte) error {
+	c.tox.SelfSetNospam(nospam)
+
+	if err := c.frontends.IDChanged(); err != nil {
+		return err
+	}
+
+	c.saveToxData()
+
+	if c.config.Debug {
+		c.logf("Nospam changed, new Tox ID: %s", c.GetToxID())
+	}
+
+	return nil
+}
+
+// RotateNospam sets a new random nospam and returns it
+func (c *Client) RotateNospam() ([4]byte, error) {
+	nospam, err := crypto.GenerateNospam()
+	if err != nil {
+		return nospam, fmt.Errorf("failed to generate nospam: %w", err)
+	}
+
+	return nospam, c.SetNospam(nospam)
 }
 
 // CreateConference creates a new conference and returns its ID
@@ -735,7 +1317,13 @@ func (c *Client) CreateConference() (uint32, error) {
 	c.conferences[conferenceID] = conference
 	c.conferencesMu.Unlock()
 
+	// Create conference directory and FIFOs
+	if err := c.frontends.ConferenceAdded(conferenceID); err != nil {
+		c.logf("Warning: failed to create FIFOs for conference %d: %v", conferenceID, err)
+	}
+
 	c.saveToxData()
+	c.publishConferenceEvent(EventConferenceCreated, conferenceID, "")
 
 	return conferenceID, nil
 }
@@ -751,5 +1339,10 @@ func (c *Client) SendConferenceMessage(conferenceID uint32, message string) erro
 
 // InviteToConference invites a friend to a conference
 func (c *Client) InviteToConference(friendID, conferenceID uint32) error {
-	return c.tox.ConferenceInvite(friendID, conferenceID)
+	if err := c.tox.ConferenceInvite(friendID, conferenceID); err != nil {
+		return err
+	}
+
+	c.publishConferenceEvent(EventConferenceInvite, conferenceID, c.friendKey(friendID))
+	return nil
 }
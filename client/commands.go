@@ -0,0 +1,315 @@
+// Package client implements the commands behind ratox-go input files
+package client
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/opd-ai/toxcore"
+)
+
+// command runs one line written to an input file. A non-nil error means the
+// command was rejected; frontends report it to the user.
+type command func(input string) error
+
+// globalCommands returns the commands behind the input files in client/
+func (c *Client) globalCommands() map[string]command {
+	return map[string]command{
+		RequestIn:     c.requestInCommand,
+		RequestReject: c.requestRejectCommand,
+		RequestSend:   c.requestSendCommand,
+		Name:          c.nameCommand,
+		StatusMessage: c.statusMessageCommand,
+		ConferenceIn:  c.conferenceInCommand,
+		NospamIn:      c.nospamInCommand,
+	}
+}
+
+// friendCommands returns the commands behind the input files in a friend's
+// directory
+func (c *Client) friendCommands(friendID string) map[string]command {
+	return map[string]command{
+		TextIn:   func(input string) error { return c.friendTextInCommand(friendID, input) },
+		FileIn:   func(input string) error { return c.friendFileInCommand(friendID, input) },
+		RemoveIn: func(input string) error { return c.friendRemoveInCommand(friendID, input) },
+		AliasIn:  func(input string) error { return c.friendAliasInCommand(friendID, input) },
+	}
+}
+
+// conferenceCommands returns the commands behind the input files in a
+// conference directory
+func (c *Client) conferenceCommands(conferenceID uint32) map[string]command {
+	return map[string]command{
+		ConferenceTextIn:   func(input string) error { return c.conferenceTextInCommand(conferenceID, input) },
+		ConferenceInviteIn: func(input string) error { return c.conferenceInviteInCommand(conferenceID, input) },
+	}
+}
+
+// requestInCommand accepts a friend request. Only keys with a pending request
+// are accepted unless the key is followed by RequestForce.
+func (c *Client) requestInCommand(input string) error {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	publicKey, err := parseRequestKey(fields[0])
+	if err != nil {
+		return fmt.Errorf("invalid friend request key: %w", err)
+	}
+
+	force := len(fields) > 1 && fields[1] == RequestForce
+	if !force && !c.HasPendingRequest(publicKey) {
+		return fmt.Errorf("no pending friend request from %s (append %q to accept anyway)",
+			hex.EncodeToString(publicKey[:]), RequestForce)
+	}
+
+	_, err = c.AcceptFriendRequest(publicKey)
+	return err
+}
+
+// requestRejectCommand rejects a pending friend request
+func (c *Client) requestRejectCommand(input string) error {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil
+	}
+
+	publicKey, err := parseRequestKey(input)
+	if err != nil {
+		return fmt.Errorf("invalid friend request key: %w", err)
+	}
+
+	return c.RejectFriendRequest(publicKey)
+}
+
+// requestSendCommand sends a friend request written as "<toxid> <message>"
+func (c *Client) requestSendCommand(input string) error {
+	toxID, message := parseRequestSend(input)
+	if toxID == "" {
+		return nil
+	}
+
+	_, err := c.AddFriend(toxID, message)
+	return err
+}
+
+// parseRequestSend splits a request_send line into the Tox ID and the request
+// message, falling back to DefaultFriendRequestMessage when none is given
+func parseRequestSend(input string) (toxID, message string) {
+	toxID, message, _ = strings.Cut(strings.TrimSpace(input), " ")
+	message = strings.TrimSpace(message)
+	if message == "" {
+		message = DefaultFriendRequestMessage
+	}
+	return toxID, message
+}
+
+// parseRequestKey decodes a 64-character public key or a 76-character Tox ID
+// (public key + nospam + checksum) into a public key
+func parseRequestKey(toxID string) ([32]byte, error) {
+	var publicKey [32]byte
+
+	// Accept both 64-character public key and 76-character full Tox ID
+	var publicKeyHex string
+	switch len(toxID) {
+	case 64:
+		publicKeyHex = toxID
+	case 76:
+		publicKeyHex = toxID[:64]
+	default:
+		return publicKey, fmt.Errorf("expected 64 or 76 characters, got %d", len(toxID))
+	}
+
+	publicKeyBytes, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return publicKey, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	copy(publicKey[:], publicKeyBytes)
+	return publicKey, nil
+}
+
+// nameCommand changes the display name
+func (c *Client) nameCommand(name string) error {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return fmt.Errorf("name cannot be empty")
+	}
+
+	return c.UpdateSelfName(name)
+}
+
+// statusMessageCommand changes the status message
+func (c *Client) statusMessageCommand(message string) error {
+	return c.UpdateSelfStatusMessage(strings.TrimSpace(message))
+}
+
+// nospamInCommand changes the nospam. The input is either NospamRandom or an
+// 8-character hex nospam value.
+func (c *Client) nospamInCommand(input string) error {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil
+	}
+
+	if input == NospamRandom {
+		_, err := c.RotateNospam()
+		return err
+	}
+
+	nospam, err := parseNospam(input)
+	if err != nil {
+		return fmt.Errorf("invalid nospam: %w", err)
+	}
+
+	return c.SetNospam(nospam)
+}
+
+// parseNospam decodes an 8-character hex nospam value
+func parseNospam(input string) ([4]byte, error) {
+	var nospam [4]byte
+
+	if len(input) != 8 {
+		return nospam, fmt.Errorf("expected 8 hex characters or %q, got %d characters", NospamRandom, len(input))
+	}
+
+	nospamBytes, err := hex.DecodeString(input)
+	if err != nil {
+		return nospam, fmt.Errorf("invalid hex: %w", err)
+	}
+
+	copy(nospam[:], nospamBytes)
+	return nospam, nil
+}
+
+// conferenceInCommand creates a new conference
+func (c *Client) conferenceInCommand(input string) error {
+	if strings.TrimSpace(input) == "" {
+		return nil
+	}
+
+	conferenceID, err := c.CreateConference()
+	if err != nil {
+		return err
+	}
+
+	if c.config.Debug {
+		c.logf("Created conference %d", conferenceID)
+	}
+	return nil
+}
+
+// friendTextInCommand sends a message to a friend. Messages starting with
+// "/me " are sent as actions.
+func (c *Client) friendTextInCommand(friendID, message string) error {
+	message = strings.TrimSpace(message)
+	if len(message) == 0 {
+		return nil
+	}
+
+	friendNum, _, err := c.ResolveFriend(friendID)
+	if err != nil {
+		return err
+	}
+
+	messageType := toxcore.MessageTypeNormal
+	text := message
+	if strings.HasPrefix(text, "/me ") {
+		messageType = toxcore.MessageTypeAction
+		text = strings.TrimPrefix(text, "/me ")
+	}
+
+	return c.SendMessage(friendNum, text, messageType)
+}
+
+// friendFileInCommand offers a local file to a friend
+func (c *Client) friendFileInCommand(friendID, filePath string) error {
+	filePath = strings.TrimSpace(filePath)
+	if len(filePath) == 0 {
+		return nil
+	}
+
+	c.logf("File transfer request for %s: %s", friendID, filePath)
+
+	friendNum, _, err := c.ResolveFriend(friendID)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.SendFile(friendNum, filePath)
+	return err
+}
+
+// friendRemoveInCommand removes a friend once the removal is confirmed
+func (c *Client) friendRemoveInCommand(friendID, data string) error {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil
+	}
+
+	if c.config.Debug {
+		c.logf("Friend removal requested for %s with confirmation: %s", friendID, data)
+	}
+
+	if err := validateRemovalConfirmation(friendID, data); err != nil {
+		return err
+	}
+
+	friendNum, _, err := c.ResolveFriend(friendID)
+	if err != nil {
+		return err
+	}
+
+	return c.RemoveFriend(friendNum, friendID)
+}
+
+// validateRemovalConfirmation checks that a removal is confirmed with
+// "confirm" or the friend's own ID
+func validateRemovalConfirmation(friendID, data string) error {
+	if data != "confirm" && data != friendID {
+		return fmt.Errorf("invalid removal confirmation, expected 'confirm' or the friend ID")
+	}
+	return nil
+}
+
+// friendAliasInCommand sets or, given AliasClear, removes a friend's nickname
+func (c *Client) friendAliasInCommand(friendID, alias string) error {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return nil
+	}
+
+	if alias == AliasClear {
+		return c.ClearFriendAlias(friendID)
+	}
+
+	return c.SetFriendAlias(friendID, alias)
+}
+
+// conferenceTextInCommand sends a message to a conference
+func (c *Client) conferenceTextInCommand(conferenceID uint32, message string) error {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return nil
+	}
+
+	return c.SendConferenceMessage(conferenceID, message)
+}
+
+// conferenceInviteInCommand invites a friend, given by alias or public key, to
+// a conference
+func (c *Client) conferenceInviteInCommand(conferenceID uint32, friendID string) error {
+	friendID = strings.TrimSpace(friendID)
+	if friendID == "" {
+		return nil
+	}
+
+	friendNum, _, err := c.ResolveFriend(friendID)
+	if err != nil {
+		return err
+	}
+
+	return c.InviteToConference(friendNum, conferenceID)
+}
@@ -0,0 +1,697 @@
+// Package client implements the JSON-RPC control socket for ratox-go
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opd-ai/toxcore"
+)
+
+// SocketPerm restricts control sockets to their owner, like the FIFOs
+const SocketPerm = 0o600
+
+// maxRPCRequestSize is the longest request line the control socket accepts
+const maxRPCRequestSize = 1024 * 1024
+
+// JSON-RPC 2.0 error codes. Codes from -32000 down are specific to ratox-go.
+const (
+	RPCParseError        = -32700
+	RPCInvalidRequest    = -32600
+	RPCMethodNotFound    = -32601
+	RPCInvalidParams     = -32602
+	RPCOperationFailed   = -32000
+	RPCFriendNotFound    = -32001
+	RPCTransferNotFound  = -32002
+	RPCRequestNotPending = -32003
+)
+
+// RPCError is a structured JSON-RPC error returned to control socket clients
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	if e.Data != "" {
+		return fmt.Sprintf("%s: %s", e.Message, e.Data)
+	}
+	return e.Message
+}
+
+// rpcRequest is a single JSON-RPC 2.0 request. A request without an id is a
+// notification and receives no response.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a single JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// rpcMethod handles the params of one call and returns its result
+type rpcMethod func(params json.RawMessage) (interface{}, error)
+
+// ControlServer serves a newline-delimited JSON-RPC 2.0 API over a Unix socket
+type ControlServer struct {
+	client   *Client
+	path     string
+	listener net.Listener
+	methods  map[string]rpcMethod
+	conns    map[net.Conn]struct{}
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// NewControlServer creates a control server for client listening on path
+func NewControlServer(client *Client, path string) *ControlServer {
+	cs := &ControlServer{
+		client: client,
+		path:   path,
+		conns:  make(map[net.Conn]struct{}),
+	}
+	cs.registerMethods()
+	return cs
+}
+
+// registerMethods builds the method table
+func (cs *ControlServer) registerMethods() {
+	cs.methods = map[string]rpcMethod{
+		"status":                  cs.rpcStatus,
+		"set_name":                cs.rpcSetName,
+		"set_status_message":      cs.rpcSetStatusMessage,
+		"list_friends":            cs.rpcListFriends,
+		"get_friend":              cs.rpcGetFriend,
+		"add_friend":              cs.rpcAddFriend,
+		"list_requests":           cs.rpcListRequests,
+		"accept_request":          cs.rpcAcceptRequest,
+		"reject_request":          cs.rpcRejectRequest,
+		"send_message":            cs.rpcSendMessage,
+		"create_conference":       cs.rpcCreateConference,
+		"invite_to_conference":    cs.rpcInviteToConference,
+		"send_conference_message": cs.rpcSendConferenceMessage,
+		"list_transfers":          cs.rpcListTransfers,
+		"send_file":               cs.rpcSendFile,
+		"cancel_transfer":         cs.rpcCancelTransfer,
+		"subscribe":               cs.rpcSubscribe,
+	}
+}
+
+// Methods returns the names of all supported methods in sorted order
+func (cs *ControlServer) Methods() []string {
+	names := make([]string, 0, len(cs.methods))
+	for name := range cs.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Listen creates the socket, replacing a stale one left by an earlier run,
+// and restricts it to the owner
+func (cs *ControlServer) Listen() error {
+	if err := removeStaleSocket(cs.path); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", cs.path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+
+	if err := os.Chmod(cs.path, SocketPerm); err != nil {
+		listener.Close()
+		os.Remove(cs.path)
+		return fmt.Errorf("failed to set control socket permissions: %w", err)
+	}
+
+	cs.listener = listener
+	return nil
+}
+
+// removeStaleSocket removes a leftover socket at path. Anything else at that
+// path is left alone and reported as an error.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to check socket path: %w", err)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is in use by another process", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	return nil
+}
+
+// Serve accepts connections until ctx is cancelled, then closes every open
+// connection and removes the socket
+func (cs *ControlServer) Serve(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		cs.listener.Close()
+		cs.mu.Lock()
+		for conn := range cs.conns {
+			conn.Close()
+		}
+		cs.mu.Unlock()
+	}()
+
+	for {
+		conn, err := cs.listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				cs.client.logf("Control socket accept error: %v", err)
+			}
+			break
+		}
+
+		cs.mu.Lock()
+		if ctx.Err() != nil {
+			cs.mu.Unlock()
+			conn.Close()
+			break
+		}
+		cs.conns[conn] = struct{}{}
+		cs.mu.Unlock()
+
+		cs.wg.Add(1)
+		go func() {
+			defer cs.wg.Done()
+			cs.handleConn(conn)
+		}()
+	}
+
+	cs.wg.Wait()
+	if err := os.Remove(cs.path); err != nil && !os.IsNotExist(err) {
+		cs.client.logf("Failed to remove control socket: %v", err)
+	}
+}
+
+// handleConn answers requests from one connection, one per line
+func (cs *ControlServer) handleConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		cs.mu.Lock()
+		delete(cs.conns, conn)
+		cs.mu.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxRPCRequestSize)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		resp := cs.handleRequest([]byte(line))
+		if resp == nil {
+			continue
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+
+		// A successful subscribe turns the connection into an event stream
+		if result, ok := resp.Result.(*subscribeResult); ok {
+			cs.streamEvents(scanner, encoder, result.filter)
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil && cs.client.config.Debug {
+		cs.client.logf("Control socket read error: %v", err)
+	}
+}
+
+// handleRequest decodes and dispatches one request line. It returns nil for
+// notifications.
+func (cs *ControlServer) handleRequest(line []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &rpcResponse{
+			JSONRPC: "2.0",
+			ID:      json.RawMessage("null"),
+			Error:   &RPCError{Code: RPCParseError, Message: "parse error", Data: err.Error()},
+		}
+	}
+
+	id := req.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return &rpcResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   &RPCError{Code: RPCInvalidRequest, Message: "invalid request", Data: `expected "jsonrpc": "2.0" and a method`},
+		}
+	}
+
+	result, err := cs.call(req.Method, req.Params)
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	resp := &rpcResponse{JSONRPC: "2.0", ID: id}
+	if err != nil {
+		resp.Error = toRPCError(err)
+	} else {
+		resp.Result = result
+	}
+	return resp
+}
+
+// call invokes a method by name
+func (cs *ControlServer) call(method string, params json.RawMessage) (interface{}, error) {
+	handler, exists := cs.methods[method]
+	if !exists {
+		return nil, &RPCError{Code: RPCMethodNotFound, Message: "method not found", Data: method}
+	}
+
+	if cs.client.config.Debug {
+		cs.client.logf("Control socket call: %s", method)
+	}
+
+	result, err := handler(params)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		// Successful calls always carry a result
+		return true, nil
+	}
+	return result, nil
+}
+
+// toRPCError converts a method error into a structured RPC error
+func toRPCError(err error) *RPCError {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	return &RPCError{Code: RPCOperationFailed, Message: "operation failed", Data: err.Error()}
+}
+
+// decodeParams unmarshals params into v, rejecting unknown fields
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(params)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &RPCError{Code: RPCInvalidParams, Message: "invalid params", Data: err.Error()}
+	}
+	return nil
+}
+
+// invalidParams reports a missing or malformed parameter
+func invalidParams(format string, args ...interface{}) *RPCError {
+	return &RPCError{Code: RPCInvalidParams, Message: "invalid params", Data: fmt.Sprintf(format, args...)}
+}
+
+// resolveFriend resolves an alias or hex public key to a friend number
+func (cs *ControlServer) resolveFriend(nameOrKey string) (uint32, string, error) {
+	if nameOrKey == "" {
+		return 0, "", invalidParams("friend is required")
+	}
+
+	friendNum, friendIDStr, err := cs.client.ResolveFriend(nameOrKey)
+	if err != nil {
+		return 0, "", &RPCError{Code: RPCFriendNotFound, Message: "friend not found", Data: err.Error()}
+	}
+	return friendNum, friendIDStr, nil
+}
+
+// parsePublicKeyParam decodes a request public key parameter
+func parsePublicKeyParam(publicKeyHex string) ([32]byte, error) {
+	publicKey, err := parseRequestKey(publicKeyHex)
+	if err != nil {
+		return [32]byte{}, invalidParams("%v", err)
+	}
+	return publicKey, nil
+}
+
+func (cs *ControlServer) rpcStatus(params json.RawMessage) (interface{}, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return cs.client.Status(), nil
+}
+
+func (cs *ControlServer) rpcSetName(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name string `json:"name"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	return nil, cs.client.UpdateSelfName(p.Name)
+}
+
+func (cs *ControlServer) rpcSetStatusMessage(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Message string `json:"message"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	return nil, cs.client.UpdateSelfStatusMessage(p.Message)
+}
+
+func (cs *ControlServer) rpcListFriends(params json.RawMessage) (interface{}, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return cs.client.FriendList(), nil
+}
+
+func (cs *ControlServer) rpcGetFriend(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Friend string `json:"friend"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	friendNum, _, err := cs.resolveFriend(p.Friend)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range cs.client.FriendList() {
+		if info.FriendNumber == friendNum {
+			return info, nil
+		}
+	}
+	return nil, &RPCError{Code: RPCFriendNotFound, Message: "friend not found", Data: p.Friend}
+}
+
+func (cs *ControlServer) rpcAddFriend(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ToxID   string `json:"tox_id"`
+		Message string `json:"message"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ToxID == "" {
+		return nil, invalidParams("tox_id is required")
+	}
+	if p.Message == "" {
+		p.Message = DefaultFriendRequestMessage
+	}
+
+	friendNum, err := cs.client.AddFriend(p.ToxID, p.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{"friend_number": friendNum}
+	if friend, exists := cs.client.GetFriend(friendNum); exists {
+		result["public_key"] = hex.EncodeToString(friend.PublicKey[:])
+	}
+	return result, nil
+}
+
+func (cs *ControlServer) rpcListRequests(params json.RawMessage) (interface{}, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return cs.client.PendingRequests(), nil
+}
+
+func (cs *ControlServer) rpcAcceptRequest(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		PublicKey string `json:"public_key"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := parsePublicKeyParam(p.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !cs.client.HasPendingRequest(publicKey) {
+		return nil, &RPCError{Code: RPCRequestNotPending, Message: "no pending request", Data: hex.EncodeToString(publicKey[:])}
+	}
+
+	friendNum, err := cs.client.AcceptFriendRequest(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"friend_number": friendNum}, nil
+}
+
+func (cs *ControlServer) rpcRejectRequest(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		PublicKey string `json:"public_key"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := parsePublicKeyParam(p.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !cs.client.HasPendingRequest(publicKey) {
+		return nil, &RPCError{Code: RPCRequestNotPending, Message: "no pending request", Data: hex.EncodeToString(publicKey[:])}
+	}
+
+	return nil, cs.client.RejectFriendRequest(publicKey)
+}
+
+func (cs *ControlServer) rpcSendMessage(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Friend  string `json:"friend"`
+		Message string `json:"message"`
+		Action  bool   `json:"action"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	friendNum, _, err := cs.resolveFriend(p.Friend)
+	if err != nil {
+		return nil, err
+	}
+
+	messageType := toxcore.MessageTypeNormal
+	if p.Action {
+		messageType = toxcore.MessageTypeAction
+	}
+	return nil, cs.client.SendMessage(friendNum, p.Message, messageType)
+}
+
+func (cs *ControlServer) rpcCreateConference(params json.RawMessage) (interface{}, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+
+	conferenceID, err := cs.client.CreateConference()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"conference_id": conferenceID}, nil
+}
+
+func (cs *ControlServer) rpcInviteToConference(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ConferenceID *uint32 `json:"conference_id"`
+		Friend       string  `json:"friend"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ConferenceID == nil {
+		return nil, invalidParams("conference_id is required")
+	}
+
+	friendNum, _, err := cs.resolveFriend(p.Friend)
+	if err != nil {
+		return nil, err
+	}
+	return nil, cs.client.InviteToConference(friendNum, *p.ConferenceID)
+}
+
+func (cs *ControlServer) rpcSendConferenceMessage(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ConferenceID *uint32 `json:"conference_id"`
+		Message      string  `json:"message"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ConferenceID == nil {
+		return nil, invalidParams("conference_id is required")
+	}
+	return nil, cs.client.SendConferenceMessage(*p.ConferenceID, p.Message)
+}
+
+func (cs *ControlServer) rpcListTransfers(params json.RawMessage) (interface{}, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return cs.client.Transfers(), nil
+}
+
+func (cs *ControlServer) rpcSendFile(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Friend string `json:"friend"`
+		Path   string `json:"path"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Path == "" {
+		return nil, invalidParams("path is required")
+	}
+
+	friendNum, _, err := cs.resolveFriend(p.Friend)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNumber, err := cs.client.SendFile(friendNum, p.Path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"friend_number": friendNum, "file_number": fileNumber}, nil
+}
+
+func (cs *ControlServer) rpcCancelTransfer(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Friend     string  `json:"friend"`
+		FileNumber *uint32 `json:"file_number"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.FileNumber == nil {
+		return nil, invalidParams("file_number is required")
+	}
+
+	friendNum, _, err := cs.resolveFriend(p.Friend)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cs.client.CancelTransfer(friendNum, *p.FileNumber); err != nil {
+		return nil, &RPCError{Code: RPCTransferNotFound, Message: "transfer not found", Data: err.Error()}
+	}
+	return nil, nil
+}
+
+// subscribeResult acknowledges a subscription; the filter is applied once
+// the response has been sent
+type subscribeResult struct {
+	Friends []string    `json:"friends"`
+	Events  []string    `json:"events"`
+	filter  EventFilter `json:"-"`
+}
+
+func (cs *ControlServer) rpcSubscribe(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Friends []string `json:"friends"`
+		Events  []string `json:"events"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	friendKeys := make([]string, 0, len(p.Friends))
+	for _, nameOrKey := range p.Friends {
+		key, err := cs.friendFilterKey(nameOrKey)
+		if err != nil {
+			return nil, err
+		}
+		friendKeys = append(friendKeys, key)
+	}
+
+	filter, err := newEventFilter(friendKeys, p.Events)
+	if err != nil {
+		return nil, invalidParams("%v", err)
+	}
+
+	events := p.Events
+	if len(events) == 0 {
+		events = EventTypes
+	}
+	return &subscribeResult{Friends: friendKeys, Events: events, filter: filter}, nil
+}
+
+// friendFilterKey resolves an alias or hex public key for an event filter.
+// Keys need not belong to a friend, so requests from strangers can be watched.
+func (cs *ControlServer) friendFilterKey(nameOrKey string) (string, error) {
+	if key, isAlias := cs.client.aliases.Lookup(nameOrKey); isAlias {
+		return key, nil
+	}
+
+	key := strings.ToLower(nameOrKey)
+	if publicKey, err := hex.DecodeString(key); err != nil || len(publicKey) != 32 {
+		return "", &RPCError{Code: RPCFriendNotFound, Message: "friend not found", Data: "unknown alias or invalid public key: " + nameOrKey}
+	}
+	return key, nil
+}
+
+// streamEvents writes matching events as JSON lines until the peer hangs up
+// or the server shuts down. Anything the peer sends afterwards is ignored.
+func (cs *ControlServer) streamEvents(scanner *bufio.Scanner, encoder *json.Encoder, filter EventFilter) {
+	sub := cs.client.events.Subscribe(filter)
+	defer cs.client.events.Unsubscribe(sub)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for scanner.Scan() {
+			// Requests are not accepted on an event stream
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event := <-sub.C:
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// newControlTestServer builds a control server over a client with no Tox
+// instance, which is enough for methods that only read local state
+func newControlTestServer(t *testing.T) *ControlServer {
+	t.Helper()
+
+	cfg := &config.Config{ConfigDir: t.TempDir()}
+	requests, err := newRequestStore(cfg.PendingRequestsPath())
+	if err != nil {
+		t.Fatalf("newRequestStore failed: %v", err)
+	}
+
+	aliases, err := newAliasStore(cfg.AliasesPath(), t.Logf)
+	if err != nil {
+		t.Fatalf("newAliasStore failed: %v", err)
+	}
+
+	c := &Client{
+		config:            cfg,
+		pendingRequests:   requests,
+		aliases:           aliases,
+		events:            NewEventBus(),
+		friends:           make(map[uint32]*Friend),
+		incomingTransfers: make(map[string]*incomingTransfer),
+		outgoingTransfers: make(map[string]*outgoingTransfer),
+	}
+
+	return NewControlServer(c, cfg.ControlSocketPath())
+}
+
+// TestControlHandleRequest tests request validation and structured errors
+func TestControlHandleRequest(t *testing.T) {
+	cs := newControlTestServer(t)
+
+	tests := []struct {
+		name      string
+		request   string
+		wantNil   bool
+		wantCode  int
+		wantError bool
+	}{
+		{"parse error", `{not json`, false, RPCParseError, true},
+		{"missing version", `{"id":1,"method":"list_requests"}`, false, RPCInvalidRequest, true},
+		{"missing method", `{"jsonrpc":"2.0","id":1}`, false, RPCInvalidRequest, true},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"nope"}`, false, RPCMethodNotFound, true},
+		{"unknown param", `{"jsonrpc":"2.0","id":1,"method":"list_requests","params":{"x":1}}`, false, RPCInvalidParams, true},
+		{"missing friend", `{"jsonrpc":"2.0","id":1,"method":"send_message","params":{"message":"hi"}}`, false, RPCInvalidParams, true},
+		{"bad public key", `{"jsonrpc":"2.0","id":1,"method":"accept_request","params":{"public_key":"zz"}}`, false, RPCInvalidParams, true},
+		{"missing file number", `{"jsonrpc":"2.0","id":1,"method":"cancel_transfer","params":{"friend":"alice"}}`, false, RPCInvalidParams, true},
+		{"not pending", `{"jsonrpc":"2.0","id":1,"method":"reject_request","params":{"public_key":"` + strings.Repeat("ab", 32) + `"}}`, false, RPCRequestNotPending, true},
+		{"success", `{"jsonrpc":"2.0","id":1,"method":"list_requests"}`, false, 0, false},
+		{"notification", `{"jsonrpc":"2.0","method":"list_requests"}`, true, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := cs.handleRequest([]byte(tt.request))
+			if tt.wantNil {
+				if resp != nil {
+					t.Errorf("Expected no response, got %+v", resp)
+				}
+				return
+			}
+			if resp == nil {
+				t.Fatal("Expected a response")
+			}
+			if tt.wantError {
+				if resp.Error == nil || resp.Error.Code != tt.wantCode {
+					t.Errorf("Expected error code %d, got %+v", tt.wantCode, resp.Error)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Errorf("Unexpected error: %+v", resp.Error)
+			}
+			if resp.Result == nil {
+				t.Error("Expected a result")
+			}
+		})
+	}
+}
+
+// TestControlSocket tests a round trip over the socket and its permissions
+func TestControlSocket(t *testing.T) {
+	cs := newControlTestServer(t)
+	if err := cs.client.pendingRequests.Add(strings.Repeat("cd", 32), "hello", time.Now()); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	if err := cs.Listen(); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	info, err := os.Stat(cs.path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != SocketPerm {
+		t.Errorf("Expected socket permissions %o, got %o", SocketPerm, perm)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		cs.Serve(ctx)
+		close(done)
+	}()
+
+	conn, err := net.Dial("unix", cs.path)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","id":"a","method":"list_requests"}` + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var resp struct {
+		ID     string           `json:"id"`
+		Result []PendingRequest `json:"result"`
+		Error  *RPCError        `json:"error"`
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("ReadBytes failed: %v", err)
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if resp.ID != "a" || resp.Error != nil || len(resp.Result) != 1 || resp.Result[0].Message != "hello" {
+		t.Errorf("Unexpected response: %s", line)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+	if _, err := os.Lstat(cs.path); !os.IsNotExist(err) {
+		t.Error("Expected socket to be removed on shutdown")
+	}
+}
+
+// TestControlSubscribe tests that a subscribed connection receives only
+// matching events
+func TestControlSubscribe(t *testing.T) {
+	cs := newControlTestServer(t)
+	keyA, keyB := strings.Repeat("aa", 32), strings.Repeat("bb", 32)
+	if _, err := cs.client.aliases.Set(keyA, "alice"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if err := cs.Listen(); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cs.Serve(ctx)
+
+	conn, err := net.Dial("unix", cs.path)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"subscribe","params":{"friends":["alice"],"events":["message"]}}` + "\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if line, err := reader.ReadBytes('\n'); err != nil || !strings.Contains(string(line), keyA) {
+		t.Fatalf("Unexpected subscribe response %q (err: %v)", line, err)
+	}
+
+	// The subscription is registered after the response is written
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cs.client.events.mu.RLock()
+		n := len(cs.client.events.subs)
+		cs.client.events.mu.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Subscription was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cs.client.publishEvent(EventTyping, keyA, map[string]interface{}{"typing": true})
+	cs.client.publishEvent(EventMessage, keyB, map[string]interface{}{"message": "not for you"})
+	cs.client.publishEvent(EventMessage, keyA, map[string]interface{}{"message": "hello"})
+
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("ReadBytes failed: %v", err)
+	}
+	var event Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if event.Type != EventMessage || event.Friend != keyA || event.Data["message"] != "hello" {
+		t.Errorf("Unexpected event: %s", line)
+	}
+}
+
+// TestRemoveStaleSocket tests that only leftover sockets are replaced
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	regular := filepath.Join(dir, "file")
+	if err := os.WriteFile(regular, nil, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := removeStaleSocket(regular); err == nil {
+		t.Error("Expected error for a regular file")
+	}
+
+	stale := filepath.Join(dir, "stale.sock")
+	listener, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	if err := removeStaleSocket(stale); err == nil {
+		t.Error("Expected error for a socket still in use")
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+
+	if err := removeStaleSocket(stale); err != nil {
+		t.Errorf("Expected stale socket to be removed: %v", err)
+	}
+	if _, err := os.Lstat(stale); !os.IsNotExist(err) {
+		t.Error("Expected stale socket to be gone")
+	}
+}
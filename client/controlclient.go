@@ -0,0 +1,82 @@
+// Package client implements a client for the JSON-RPC control socket of ratox-go
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// controlDialTimeout bounds connecting to the control socket
+const controlDialTimeout = 2 * time.Second
+
+// ControlClient calls the JSON-RPC API of a running ratox-go
+type ControlClient struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+// DialControl connects to the control socket at path
+func DialControl(path string) (*ControlClient, error) {
+	conn, err := net.DialTimeout("unix", path, controlDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to control socket: %w", err)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxRPCRequestSize)
+	return &ControlClient{conn: conn, scanner: scanner}, nil
+}
+
+// Call invokes method with params and decodes its result into result, which
+// may be nil. An error response is returned as an *RPCError.
+func (cc *ControlClient) Call(method string, params, result interface{}) error {
+	cc.nextID++
+	request := struct {
+		JSONRPC string      `json:"jsonrpc"`
+		ID      int         `json:"id"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+	}{"2.0", cc.nextID, method, params}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	if _, err := cc.conn.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if !cc.scanner.Scan() {
+		if err := cc.scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		return fmt.Errorf("control socket closed the connection")
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *RPCError       `json:"error"`
+	}
+	if err := json.Unmarshal(cc.scanner.Bytes(), &response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// Close closes the connection
+func (cc *ControlClient) Close() error {
+	return cc.conn.Close()
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestControlClientCall tests results and error responses through the socket
+func TestControlClientCall(t *testing.T) {
+	cs := newControlTestServer(t)
+	if err := cs.client.pendingRequests.Add(strings.Repeat("cd", 32), "hello", time.Now()); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := cs.Listen(); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cs.Serve(ctx)
+
+	cc, err := DialControl(cs.path)
+	if err != nil {
+		t.Fatalf("DialControl failed: %v", err)
+	}
+	defer cc.Close()
+
+	var requests []PendingRequest
+	if err := cc.Call("list_requests", nil, &requests); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if len(requests) != 1 || requests[0].Message != "hello" {
+		t.Errorf("Unexpected requests: %+v", requests)
+	}
+
+	err = cc.Call("nope", nil, nil)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != RPCMethodNotFound {
+		t.Errorf("Expected method not found error, got %v", err)
+	}
+
+	// The connection stays usable after an error response
+	if err := cc.Call("list_requests", nil, nil); err != nil {
+		t.Errorf("Call after error failed: %v", err)
+	}
+}
+
+// TestDialControlWithoutServer tests that a missing socket fails to dial
+func TestDialControlWithoutServer(t *testing.T) {
+	if _, err := DialControl(t.TempDir() + "/control.sock"); err == nil {
+		t.Error("Expected DialControl to fail without a server")
+	}
+}
@@ -0,0 +1,210 @@
+// Package client implements passphrase encryption of the Tox save file for ratox-go
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/opd-ai/go-ratox/config"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted savedata uses the toxencryptsave layout of c-toxcore, so profiles
+// can be opened by other Tox clients: the magic, the key derivation salt, the
+// nonce and the secretbox of the plain savedata
+const (
+	encryptionMagic  = "toxEsave"
+	saltLength       = 32
+	nonceLength      = 24
+	encryptionHeader = len(encryptionMagic) + saltLength + nonceLength
+
+	// The scrypt parameters libsodium picks for twice the interactive
+	// opslimit, as used by toxencryptsave
+	scryptN = 16384
+	scryptR = 8
+	scryptP = 2
+)
+
+var (
+	// ErrPassphraseRequired is returned when encrypted savedata is loaded
+	// without a passphrase
+	ErrPassphraseRequired = errors.New("savedata is encrypted: set RATOX_PASSPHRASE or passphrase_file, or start ratox-go on a terminal to be prompted")
+	// ErrWrongPassphrase is returned when encrypted savedata does not decrypt
+	ErrWrongPassphrase = errors.New("wrong passphrase or damaged savedata")
+	// ErrSaveDataNotEncrypted is returned when a passphrase is given for
+	// savedata that is stored in plaintext
+	ErrSaveDataNotEncrypted = errors.New("savedata is not encrypted: encrypt it with -change-passphrase or unset the passphrase")
+)
+
+// IsEncryptedSaveData reports whether data is encrypted savedata
+func IsEncryptedSaveData(data []byte) bool {
+	return len(data) >= encryptionHeader+secretbox.Overhead && bytes.HasPrefix(data, []byte(encryptionMagic))
+}
+
+// passKey is a key derived from a passphrase and salt. Deriving is slow, so
+// the key is kept and reused for every save.
+type passKey struct {
+	salt [saltLength]byte
+	key  [32]byte
+}
+
+// derivePassKey derives the key for passphrase and salt as toxencryptsave
+// does, running scrypt over the SHA-256 of the passphrase
+func derivePassKey(passphrase string, salt []byte) (*passKey, error) {
+	hash := sha256.Sum256([]byte(passphrase))
+	key, err := scrypt.Key(hash[:], salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	k := &passKey{}
+	copy(k.salt[:], salt)
+	copy(k.key[:], key)
+	return k, nil
+}
+
+// newPassKey derives a key for passphrase with a random salt
+func newPassKey(passphrase string) (*passKey, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return derivePassKey(passphrase, salt)
+}
+
+// saltOf returns the salt of encrypted savedata
+func saltOf(data []byte) []byte {
+	return data[len(encryptionMagic) : len(encryptionMagic)+saltLength]
+}
+
+// encrypt seals data with a fresh nonce
+func (k *passKey) encrypt(data []byte) ([]byte, error) {
+	var nonce [nonceLength]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, encryptionHeader+len(data)+secretbox.Overhead)
+	out = append(out, encryptionMagic...)
+	out = append(out, k.salt[:]...)
+	out = append(out, nonce[:]...)
+	return secretbox.Seal(out, data, &nonce, &k.key), nil
+}
+
+// decrypt opens encrypted savedata sealed with k
+func (k *passKey) decrypt(data []byte) ([]byte, error) {
+	if !IsEncryptedSaveData(data) || !bytes.Equal(saltOf(data), k.salt[:]) {
+		return nil, ErrWrongPassphrase
+	}
+
+	var nonce [nonceLength]byte
+	copy(nonce[:], data[len(encryptionMagic)+saltLength:encryptionHeader])
+	plain, ok := secretbox.Open(nil, data[encryptionHeader:], &nonce, &k.key)
+	if !ok {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+// decryptSaveData decrypts data with passphrase, returning the key it was
+// sealed with
+func decryptSaveData(data []byte, passphrase string) ([]byte, *passKey, error) {
+	key, err := derivePassKey(passphrase, saltOf(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := key.decrypt(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plain, key, nil
+}
+
+// openSaveData returns the plain savedata of data read from disk and, if it
+// is encrypted, the key that opened it
+func (c *Client) openSaveData(data []byte) ([]byte, *passKey, error) {
+	passphrase := c.config.Passphrase
+	if !IsEncryptedSaveData(data) {
+		if passphrase != "" {
+			return nil, nil, ErrSaveDataNotEncrypted
+		}
+		return data, nil, nil
+	}
+	if passphrase == "" {
+		return nil, nil, ErrPassphraseRequired
+	}
+	return decryptSaveData(data, passphrase)
+}
+
+// sealSaveData returns data as it is written to disk: encrypted if the
+// profile has a passphrase
+func (c *Client) sealSaveData(data []byte) ([]byte, error) {
+	if c.saveKey == nil {
+		return data, nil
+	}
+	return c.saveKey.encrypt(data)
+}
+
+// ChangePassphrase re-encrypts the save file of cfg and its backups with
+// newPassphrase, or stores them in plaintext if it is empty. Encrypted files
+// are opened with oldPassphrase. Every file is checked before any is
+// rewritten. The client must not be running.
+func ChangePassphrase(cfg *config.Config, oldPassphrase, newPassphrase string) error {
+	paths := []string{cfg.SaveFile}
+	for i := 1; i <= cfg.SaveDataBackups; i++ {
+		paths = append(paths, cfg.SaveDataBackupPath(i))
+	}
+
+	plain := make(map[string][]byte)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if IsEncryptedSaveData(data) {
+			if oldPassphrase == "" {
+				return fmt.Errorf("%s: %w", path, ErrPassphraseRequired)
+			}
+			if data, _, err = decryptSaveData(data, oldPassphrase); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		plain[path] = data
+	}
+	if _, ok := plain[cfg.SaveFile]; !ok {
+		return fmt.Errorf("no save file at %s", cfg.SaveFile)
+	}
+
+	var key *passKey
+	if newPassphrase != "" {
+		var err error
+		if key, err = newPassKey(newPassphrase); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range paths {
+		data, ok := plain[path]
+		if !ok {
+			continue
+		}
+		if key != nil {
+			var err error
+			if data, err = key.encrypt(data); err != nil {
+				return err
+			}
+		}
+		if err := config.WriteFileAtomic(path, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/opd-ai/toxcore"
+)
+
+func TestSaveDataEncryption(t *testing.T) {
+	plain := []byte("tox savedata")
+
+	key, err := newPassKey("correct horse")
+	if err != nil {
+		t.Fatalf("newPassKey failed: %v", err)
+	}
+	sealed, err := key.encrypt(plain)
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	if !IsEncryptedSaveData(sealed) {
+		t.Error("IsEncryptedSaveData(sealed) = false")
+	}
+	if IsEncryptedSaveData(plain) {
+		t.Error("IsEncryptedSaveData(plain) = true")
+	}
+	if got, want := len(sealed), len(plain)+80; got != want {
+		t.Errorf("Encrypted length = %d, want %d as in toxencryptsave", got, want)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		data       []byte
+		wantErr    error
+	}{
+		{"right passphrase", "correct horse", sealed, nil},
+		{"wrong passphrase", "battery staple", sealed, ErrWrongPassphrase},
+		{"damaged", "correct horse", append(append([]byte(nil), sealed[:len(sealed)-1]...), sealed[len(sealed)-1]^1), ErrWrongPassphrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := decryptSaveData(tt.data, tt.passphrase)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decryptSaveData error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, plain) {
+				t.Errorf("decryptSaveData = %q, want %q", got, plain)
+			}
+		})
+	}
+}
+
+func TestCreateToxInstanceEncrypted(t *testing.T) {
+	c := newSaveDataTestClient(t, 1)
+	c.config.Passphrase = "correct horse"
+
+	if err := c.createToxInstance(toxcore.NewOptionsForTesting()); err != nil {
+		t.Fatalf("createToxInstance failed: %v", err)
+	}
+	wantID := c.tox.SelfGetAddress()
+	if err := c.writeSaveData(c.tox.GetSavedata()); err != nil {
+		t.Fatalf("writeSaveData failed: %v", err)
+	}
+	c.tox.Kill()
+
+	data, err := os.ReadFile(c.config.SaveFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !IsEncryptedSaveData(data) {
+		t.Fatal("Save file was written in plaintext")
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		wantErr    error
+	}{
+		{"no passphrase", "", ErrPassphraseRequired},
+		{"wrong passphrase", "battery staple", ErrWrongPassphrase},
+		{"right passphrase", "correct horse", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := *c.config
+			cfg.Passphrase = tt.passphrase
+			restored := &Client{config: &cfg}
+
+			err := restored.createToxInstance(toxcore.NewOptionsForTesting())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("createToxInstance error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer restored.tox.Kill()
+			if got := restored.tox.SelfGetAddress(); got != wantID {
+				t.Errorf("Restored Tox ID = %s, want %s", got, wantID)
+			}
+		})
+	}
+
+	if got := readFileOrEmpty(t, c.config.SaveFile); got != string(data) {
+		t.Error("Failed loads changed the save file")
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	c := newSaveDataTestClient(t, 1)
+	plain := []byte("tox savedata")
+	for _, path := range []string{c.config.SaveFile, c.config.SaveDataBackupPath(1)} {
+		if err := os.WriteFile(path, plain, 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	// checkFiles asserts both files decrypt to plain with passphrase
+	checkFiles := func(passphrase string) {
+		t.Helper()
+		for _, path := range []string{c.config.SaveFile, c.config.SaveDataBackupPath(1)} {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile failed: %v", err)
+			}
+			if passphrase == "" {
+				if !bytes.Equal(data, plain) {
+					t.Errorf("%s is not the plain savedata", path)
+				}
+				continue
+			}
+			got, _, err := decryptSaveData(data, passphrase)
+			if err != nil || !bytes.Equal(got, plain) {
+				t.Errorf("%s does not decrypt with %q: %v", path, passphrase, err)
+			}
+		}
+	}
+
+	if err := ChangePassphrase(c.config, "", "first"); err != nil {
+		t.Fatalf("Encrypting failed: %v", err)
+	}
+	checkFiles("first")
+
+	if err := ChangePassphrase(c.config, "wrong", "second"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Change with the wrong passphrase error = %v, want %v", err, ErrWrongPassphrase)
+	}
+	checkFiles("first")
+
+	if err := ChangePassphrase(c.config, "first", "second"); err != nil {
+		t.Fatalf("Changing failed: %v", err)
+	}
+	checkFiles("second")
+
+	if err := ChangePassphrase(c.config, "second", ""); err != nil {
+		t.Fatalf("Removing failed: %v", err)
+	}
+	checkFiles("")
+}
@@ -0,0 +1,221 @@
+// Package client implements the event bus for ratox-go
+package client
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types published on the event bus
+const (
+	EventMessage           = "message"
+	EventTyping            = "typing"
+	EventName              = "name"
+	EventStatus            = "status"
+	EventStatusMessage     = "status_message"
+	EventConnection        = "connection"
+	EventSelfConnection    = "self_connection"
+	EventFileOffer         = "file_offer"
+	EventFileProgress      = "file_progress"
+	EventFileComplete      = "file_complete"
+	EventFileAborted       = "file_aborted"
+	EventFriendRequest     = "friend_request"
+	EventConferenceCreated = "conference_created"
+	EventConferenceInvite  = "conference_invite"
+)
+
+// EventTypes lists every event type in a stable order
+var EventTypes = []string{
+	EventMessage,
+	EventTyping,
+	EventName,
+	EventStatus,
+	EventStatusMessage,
+	EventConnection,
+	EventSelfConnection,
+	EventFileOffer,
+	EventFileProgress,
+	EventFileComplete,
+	EventFileAborted,
+	EventFriendRequest,
+	EventConferenceCreated,
+	EventConferenceInvite,
+}
+
+// eventBufferSize is the number of events a subscriber may fall behind by
+// before further events are dropped for it
+const eventBufferSize = 256
+
+// Event is something that happened on the Tox network or in the client.
+// Friend is the hex public key of the friend involved, if any.
+type Event struct {
+	Type         string                 `json:"type"`
+	Time         time.Time              `json:"time"`
+	Friend       string                 `json:"friend,omitempty"`
+	ConferenceID *uint32                `json:"conference_id,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventFilter selects events by friend public key and event type. An empty
+// set matches everything.
+type EventFilter struct {
+	Friends map[string]bool
+	Types   map[string]bool
+}
+
+// Matches reports whether the filter selects event
+func (f EventFilter) Matches(event Event) bool {
+	if len(f.Types) > 0 && !f.Types[event.Type] {
+		return false
+	}
+	if len(f.Friends) > 0 && !f.Friends[event.Friend] {
+		return false
+	}
+	return true
+}
+
+// Subscription receives matching events on C until it is cancelled
+type Subscription struct {
+	C       <-chan Event
+	ch      chan Event
+	filter  EventFilter
+	dropped atomic.Uint64
+}
+
+// Dropped returns how many events were discarded because the subscriber was
+// not keeping up
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// EventBus fans out published events to subscribers without blocking the
+// publisher. A nil bus discards everything.
+type EventBus struct {
+	subs map[*Subscription]struct{}
+	mu   sync.RWMutex
+}
+
+// NewEventBus creates an event bus with no subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber for events matching filter
+func (b *EventBus) Subscribe(filter EventFilter) *Subscription {
+	ch := make(chan Event, eventBufferSize)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Unsubscribe removes a subscriber and closes its channel
+func (b *EventBus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.subs[sub]; exists {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish delivers event to every matching subscriber
+func (b *EventBus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// publishEvent stamps and publishes an event on the client's bus
+func (c *Client) publishEvent(eventType, friendIDStr string, data map[string]interface{}) {
+	c.events.Publish(Event{
+		Type:   eventType,
+		Time:   time.Now().UTC(),
+		Friend: friendIDStr,
+		Data:   data,
+	})
+}
+
+// publishConferenceEvent publishes an event about a conference
+func (c *Client) publishConferenceEvent(eventType string, conferenceID uint32, friendIDStr string) {
+	c.events.Publish(Event{
+		Type:         eventType,
+		Time:         time.Now().UTC(),
+		Friend:       friendIDStr,
+		ConferenceID: &conferenceID,
+	})
+}
+
+// publishFileEvent publishes a file transfer event for a friend number
+func (c *Client) publishFileEvent(eventType string, friendID, fileNumber uint32, direction, filename string, transferred, size uint64) {
+	c.publishEvent(eventType, c.friendKey(friendID), map[string]interface{}{
+		"file_number": fileNumber,
+		"direction":   direction,
+		"filename":    filename,
+		"transferred": transferred,
+		"size":        size,
+	})
+}
+
+// friendKey returns the hex public key of a friend number, or "" if unknown
+func (c *Client) friendKey(friendID uint32) string {
+	c.friendsMu.RLock()
+	defer c.friendsMu.RUnlock()
+
+	friend, exists := c.friends[friendID]
+	if !exists || friend.PublicKey == ([32]byte{}) {
+		return ""
+	}
+	return hex.EncodeToString(friend.PublicKey[:])
+}
+
+// newEventFilter builds a filter from friend public keys and event type
+// names, rejecting unknown types
+func newEventFilter(friendKeys, types []string) (EventFilter, error) {
+	filter := EventFilter{}
+
+	if len(types) > 0 {
+		known := make(map[string]bool, len(EventTypes))
+		for _, t := range EventTypes {
+			known[t] = true
+		}
+
+		filter.Types = make(map[string]bool, len(types))
+		for _, t := range types {
+			if !known[t] {
+				return EventFilter{}, fmt.Errorf("unknown event type %q", t)
+			}
+			filter.Types[t] = true
+		}
+	}
+
+	if len(friendKeys) > 0 {
+		filter.Friends = make(map[string]bool, len(friendKeys))
+		for _, key := range friendKeys {
+			filter.Friends[key] = true
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"testing"
+)
+
+// TestEventFilter tests filtering by friend and event type
+func TestEventFilter(t *testing.T) {
+	filter, err := newEventFilter([]string{"aa"}, []string{EventMessage, EventTyping})
+	if err != nil {
+		t.Fatalf("newEventFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		event Event
+		want  bool
+	}{
+		{"matching friend and type", Event{Type: EventMessage, Friend: "aa"}, true},
+		{"other friend", Event{Type: EventMessage, Friend: "bb"}, false},
+		{"other type", Event{Type: EventConnection, Friend: "aa"}, false},
+		{"no friend", Event{Type: EventTyping}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.Matches(tt.event); got != tt.want {
+				t.Errorf("Matches(%+v) = %v, want %v", tt.event, got, tt.want)
+			}
+		})
+	}
+
+	if !(EventFilter{}).Matches(Event{Type: EventSelfConnection}) {
+		t.Error("Expected empty filter to match everything")
+	}
+	if _, err := newEventFilter(nil, []string{"bogus"}); err == nil {
+		t.Error("Expected error for unknown event type")
+	}
+}
+
+// TestEventBus tests delivery, dropping for slow subscribers and unsubscribing
+func TestEventBus(t *testing.T) {
+	var nilBus *EventBus
+	nilBus.Publish(Event{Type: EventMessage}) // must not panic
+
+	bus := NewEventBus()
+	all := bus.Subscribe(EventFilter{})
+	typing := bus.Subscribe(EventFilter{Types: map[string]bool{EventTyping: true}})
+
+	for i := 0; i < eventBufferSize+5; i++ {
+		bus.Publish(Event{Type: EventMessage})
+	}
+	bus.Publish(Event{Type: EventTyping})
+
+	if got := len(all.C); got != eventBufferSize {
+		t.Errorf("Expected %d buffered events, got %d", eventBufferSize, got)
+	}
+	if got := all.Dropped(); got != 6 {
+		t.Errorf("Expected 6 dropped events, got %d", got)
+	}
+	if got := len(typing.C); got != 1 {
+		t.Errorf("Expected 1 typing event, got %d", got)
+	}
+
+	bus.Unsubscribe(typing)
+	bus.Unsubscribe(typing) // second call is a no-op
+	if _, open := <-typing.C; !open {
+		t.Fatal("Expected buffered event before close")
+	}
+	if _, open := <-typing.C; open {
+		t.Error("Expected channel to be closed after unsubscribe")
+	}
+}
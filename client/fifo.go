@@ -4,23 +4,23 @@ package client
 import (
 	"bufio"
 	"context"
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/opd-ai/go-ratox/config"
-	"github.com/opd-ai/toxcore"
 )
 
-// FIFOManager handles all FIFO operations for the client
+// FIFOManager is the default Frontend. It exposes the client as named pipes
+// and regular files in the profile directory.
 type FIFOManager struct {
 	config  *config.Config
 	client  *Client
@@ -49,12 +49,24 @@ const (
 	// Global FIFOs
 	RequestIn        = "request_in"        // Write-only - accept friend requests
 	RequestOut       = "request_out"       // Read-only - incoming friend requests
+	RequestReject    = "request_reject"    // Write-only - reject pending friend requests
+	RequestSend      = "request_send"      // Write-only - send outgoing friend requests
+	RequestsPending  = "requests_pending"  // Read-only - pending friend requests file
 	Name             = "name"              // Write-only - set display name
 	StatusMessage    = "status_message"    // Write-only - set status message
 	ID               = "id"                // Read-only - Tox ID file
 	ConnectionStatus = "connection_status" // Read-only - connection status info
 	TransportStatus  = "transport_status"  // Read-only - transport status info
+	ConfigStatus     = "config_status"     // Read-only - result of the last config reload
+	BootstrapStatus  = "bootstrap_status"  // Read-only - bootstrap node health
 	ConferenceIn     = "conference_in"     // Write-only - create/join conferences
+	NospamIn         = "nospam_in"         // Write-only - change nospam / Tox ID
+	LastSeenList     = "last_seen"         // Read-only - friends by last seen time
+	FriendsList      = "friends"           // Read-only - friends roster
+	FriendsJSON      = "friends.json"      // Read-only - friends roster as JSON
+
+	// ErrorsOut exists in the global, friend and conference directories
+	ErrorsOut = "errors" // Read-only - rejected commands
 
 	// Friend-specific FIFOs
 	TextIn              = "text_in"        // Write-only - send messages
@@ -65,11 +77,33 @@ const (
 	FriendStatusMessage = "status_message" // Read-only - friend status message
 	RemoveIn            = "remove_in"      // Write-only - remove friend
 	Typing              = "typing"         // Read-only - typing indicator
+	AliasIn             = "alias_in"       // Write-only - set local nickname
+
+	// Friend-specific regular files, always holding the current value
+	FriendName       = "name"        // Friend display name
+	FriendPublicKey  = "pubkey"      // Friend public key
+	FriendLastSeen   = "last_seen"   // Last time the friend was seen online
+	FriendConnection = "connection"  // Connection type (offline, tcp, udp)
+	FriendUserStatus = "user_status" // User status (online, away, busy)
 
 	// Conference-specific FIFOs (per-conference directory)
 	ConferenceTextIn   = "text_in"   // Write-only - send conference messages
 	ConferenceInviteIn = "invite_in" // Write-only - invite friends to conference
 
+	// RequestForce may follow a key written to request_in to accept it even
+	// when no pending request from that key is known
+	RequestForce = "force"
+
+	// DefaultFriendRequestMessage is sent when request_send is given a Tox ID
+	// without a message
+	DefaultFriendRequestMessage = "Hi, I'd like to add you as a friend"
+
+	// NospamRandom written to nospam_in picks a random nospam
+	NospamRandom = "random"
+
+	// AliasClear written to alias_in removes the friend's nickname
+	AliasClear = "-"
+
 	// FIFO permissions
 	FIFOPermInput  = 0o600 // Read/write for owner
 	FIFOPermOutput = 0o600 // Read/write for owner
@@ -89,16 +123,23 @@ func NewFIFOManager(client *Client) *FIFOManager {
 	}
 }
 
-// Run starts the FIFO manager
-func (fm *FIFOManager) Run(ctx context.Context) {
-	defer fm.wg.Wait()
-
-	// Create global FIFOs
+// Start creates the global FIFOs
+func (fm *FIFOManager) Start() error {
 	if err := fm.createGlobalFIFOs(); err != nil {
-		log.Printf("Failed to create global FIFOs: %v", err)
-		return
+		return fmt.Errorf("failed to create global FIFOs: %w", err)
 	}
 
+	// Recreate by-name symlinks for friend aliases
+	if err := fm.syncAliasLinks(); err != nil {
+		fm.client.logf("Failed to sync friend alias links: %v", err)
+	}
+	return nil
+}
+
+// Serve monitors the input FIFOs until ctx is cancelled
+func (fm *FIFOManager) Serve(ctx context.Context) {
+	defer fm.wg.Wait()
+
 	// Start monitoring global FIFOs
 	fm.wg.Add(1)
 	go func() {
@@ -114,6 +155,8 @@ func (fm *FIFOManager) Run(ctx context.Context) {
 	}()
 
 	<-ctx.Done()
+	// Stop the friend and conference monitors too
+	fm.cancel()
 }
 
 // createGlobalFIFOs creates the global FIFO files
@@ -131,9 +174,13 @@ func (fm *FIFOManager) createGlobalFIFOs() error {
 	}{
 		{RequestIn, true, false},
 		{RequestOut, false, true},
+		{RequestReject, true, false},
+		{RequestSend, true, false},
 		{Name, true, false},
 		{StatusMessage, true, false},
 		{ConferenceIn, true, false},
+		{NospamIn, true, false},
+		{ErrorsOut, false, true},
 	}
 
 	for _, fifo := range globalFIFOs {
@@ -153,16 +200,39 @@ func (fm *FIFOManager) createGlobalFIFOs() error {
 		return fmt.Errorf("failed to create connection status file: %w", err)
 	}
 
+	// Create pending requests file
+	if err := fm.writeRequestsPendingFile(); err != nil {
+		return fmt.Errorf("failed to create pending requests file: %w", err)
+	}
+
+	// Create friends roster and last seen listing
+	if err := fm.writeFriendsRoster(); err != nil {
+		return fmt.Errorf("failed to create friends roster: %w", err)
+	}
+	if err := fm.writeLastSeenFile(); err != nil {
+		return fmt.Errorf("failed to create last seen file: %w", err)
+	}
+
 	// Create transport status file
 	if err := fm.createTransportStatusFile(); err != nil {
 		return fmt.Errorf("failed to create transport status file: %w", err)
 	}
 
+	// Create config status file
+	if err := fm.writeConfigStatusFile(); err != nil {
+		return fmt.Errorf("failed to create config status file: %w", err)
+	}
+
+	// Create bootstrap status file
+	if err := fm.writeBootstrapStatusFile(); err != nil {
+		return fmt.Errorf("failed to create bootstrap status file: %w", err)
+	}
+
 	return nil
 }
 
-// CreateFriendFIFOs creates FIFO files for a specific friend
-func (fm *FIFOManager) CreateFriendFIFOs(friendID string) error {
+// FriendAdded creates the FIFOs for a friend and starts monitoring them
+func (fm *FIFOManager) FriendAdded(friendID string) error {
 	friendDir := fm.config.FriendDir(friendID)
 	if err := os.MkdirAll(friendDir, DirPerm); err != nil {
 		return fmt.Errorf("failed to create friend directory: %w", err)
@@ -181,6 +251,8 @@ func (fm *FIFOManager) CreateFriendFIFOs(friendID string) error {
 		{FriendStatusMessage, false, true},
 		{RemoveIn, true, false},
 		{Typing, false, true},
+		{AliasIn, true, false},
+		{ErrorsOut, false, true},
 	}
 
 	for _, fifo := range friendFIFOs {
@@ -200,8 +272,9 @@ func (fm *FIFOManager) CreateFriendFIFOs(friendID string) error {
 	return nil
 }
 
-// createConferenceFIFOs creates FIFO files for a conference
-func (fm *FIFOManager) createConferenceFIFOs(conferenceID uint32) error {
+// ConferenceAdded creates the FIFOs for a conference and starts monitoring
+// them
+func (fm *FIFOManager) ConferenceAdded(conferenceID uint32) error {
 	conferenceIDStr := fmt.Sprintf("%d", conferenceID)
 	conferenceDir := fm.config.ConferenceDir(conferenceIDStr)
 	if err := os.MkdirAll(conferenceDir, DirPerm); err != nil {
@@ -215,6 +288,7 @@ func (fm *FIFOManager) createConferenceFIFOs(conferenceID uint32) error {
 	}{
 		{ConferenceTextIn, true, false},
 		{ConferenceInviteIn, true, false},
+		{ErrorsOut, false, true},
 	}
 
 	for _, fifo := range conferenceFIFOs {
@@ -224,6 +298,13 @@ func (fm *FIFOManager) createConferenceFIFOs(conferenceID uint32) error {
 		}
 	}
 
+	// Start monitoring conference FIFOs
+	fm.wg.Add(1)
+	go func() {
+		defer fm.wg.Done()
+		fm.monitorConferenceFIFOs(fm.ctx, conferenceID)
+	}()
+
 	return nil
 }
 
@@ -275,7 +356,7 @@ func (fm *FIFOManager) createFIFO(path string, isInput, isOutput bool) error {
 	fm.fifosMu.Unlock()
 
 	if fm.config.Debug {
-		log.Printf("Created FIFO: %s (input: %v, output: %v)", path, isInput, isOutput)
+		fm.client.logf("Created FIFO: %s (input: %v, output: %v)", path, isInput, isOutput)
 	}
 
 	return nil
@@ -291,7 +372,7 @@ func (fm *FIFOManager) createIDFile() error {
 	}
 
 	if fm.config.Debug {
-		log.Printf("Created ID file: %s", idPath)
+		fm.client.logf("Created ID file: %s", idPath)
 	}
 
 	return nil
@@ -301,65 +382,111 @@ func (fm *FIFOManager) createIDFile() error {
 func (fm *FIFOManager) createConnectionStatusFile() error {
 	statusPath := fm.config.GlobalFIFOPath(ConnectionStatus)
 
-	connectionStatus := fm.client.tox.SelfGetConnectionStatus()
-	friends := fm.client.tox.GetFriends()
+	if err := os.WriteFile(statusPath, []byte(fm.client.connectionStatusText()), 0o600); err != nil {
+		return fmt.Errorf("failed to write connection status file: %w", err)
+	}
+
+	if fm.config.Debug {
+		fm.client.logf("Updated connection status file: %s", statusPath)
+	}
+
+	return nil
+}
+
+// connectionStatusText describes the self connection and friend counts
+func (c *Client) connectionStatusText() string {
+	connectionStatus := c.tox.SelfGetConnectionStatus()
+	friends := c.tox.GetFriends()
 	friendsCount := len(friends)
 
 	// Count online friends
 	onlineFriends := 0
-	fm.client.friendsMu.RLock()
-	for _, friend := range fm.client.friends {
+	c.friendsMu.RLock()
+	for _, friend := range c.friends {
 		if friend.Online {
 			onlineFriends++
 		}
 	}
-	fm.client.friendsMu.RUnlock()
+	c.friendsMu.RUnlock()
 
-	var statusStr string
-	switch connectionStatus {
-	case 0: // ConnectionNone
-		statusStr = "offline"
-	case 1: // ConnectionTCP
-		statusStr = "tcp"
-	case 2: // ConnectionUDP
-		statusStr = "udp"
-	default:
-		statusStr = "unknown"
-	}
+	return fmt.Sprintf("connection: %s\nfriends: %d total, %d online\n", connectionTypeString(connectionStatus), friendsCount, onlineFriends)
+}
 
-	statusInfo := fmt.Sprintf("connection: %s\nfriends: %d total, %d online\n", statusStr, friendsCount, onlineFriends)
+// writeRequestsPendingFile writes the pending friend requests, one per line,
+// as "<public_key> <received RFC3339> <quoted message>"
+func (fm *FIFOManager) writeRequestsPendingFile() error {
+	pendingPath := fm.config.GlobalFIFOPath(RequestsPending)
 
-	if err := os.WriteFile(statusPath, []byte(statusInfo), 0o600); err != nil {
-		return fmt.Errorf("failed to write connection status file: %w", err)
+	if err := os.WriteFile(pendingPath, []byte(fm.client.requestsPendingText()), 0o600); err != nil {
+		return fmt.Errorf("failed to write pending requests file: %w", err)
 	}
 
 	if fm.config.Debug {
-		log.Printf("Updated connection status file: %s", statusPath)
+		fm.client.logf("Updated pending requests file: %s", pendingPath)
 	}
 
 	return nil
 }
 
+// requestsPendingText lists the pending friend requests for requests_pending
+func (c *Client) requestsPendingText() string {
+	var sb strings.Builder
+	for _, req := range c.PendingRequests() {
+		// Quoted so a newline in the message cannot forge another request
+		fmt.Fprintf(&sb, "%s %s %s\n", req.PublicKey, req.Received.Format(time.RFC3339), strconv.Quote(req.Message))
+	}
+	return sb.String()
+}
+
 // createTransportStatusFile creates a file containing transport status information
 func (fm *FIFOManager) createTransportStatusFile() error {
 	statusPath := fm.config.GlobalFIFOPath(TransportStatus)
 
-	transportInfo := fm.getTransportInfo()
+	transportInfo := transportStatusText(fm.config.Transport)
 
 	if err := os.WriteFile(statusPath, []byte(transportInfo), 0o600); err != nil {
 		return fmt.Errorf("failed to write transport status file: %w", err)
 	}
 
 	if fm.config.Debug {
-		log.Printf("Created transport status file: %s", statusPath)
+		fm.client.logf("Created transport status file: %s", statusPath)
 	}
 
 	return nil
 }
 
-// getTransportInfo returns formatted transport information string
-func (fm *FIFOManager) getTransportInfo() string {
-	cfg := fm.config.Transport
+// writeConfigStatusFile writes the result of the last config reload
+func (fm *FIFOManager) writeConfigStatusFile() error {
+	statusPath := fm.config.GlobalFIFOPath(ConfigStatus)
+
+	if err := os.WriteFile(statusPath, []byte(fm.client.configStatusText()), 0o600); err != nil {
+		return fmt.Errorf("failed to write config status file: %w", err)
+	}
+
+	if fm.config.Debug {
+		fm.client.logf("Updated config status file: %s", statusPath)
+	}
+
+	return nil
+}
+
+// writeBootstrapStatusFile writes the health of the bootstrap nodes
+func (fm *FIFOManager) writeBootstrapStatusFile() error {
+	statusPath := fm.config.GlobalFIFOPath(BootstrapStatus)
+
+	if err := os.WriteFile(statusPath, []byte(fm.client.bootstrapStatusText()), 0o600); err != nil {
+		return fmt.Errorf("failed to write bootstrap status file: %w", err)
+	}
+
+	if fm.config.Debug {
+		fm.client.logf("Updated bootstrap status file: %s", statusPath)
+	}
+
+	return nil
+}
+
+// transportStatusText returns formatted transport information string
+func transportStatusText(cfg config.TransportConfig) string {
 	var transportType string
 
 	if cfg.TorEnabled && cfg.I2PEnabled {
@@ -379,36 +506,26 @@ func (fm *FIFOManager) getTransportInfo() string {
 
 // monitorGlobalFIFOs monitors global FIFO files for input
 func (fm *FIFOManager) monitorGlobalFIFOs(ctx context.Context) {
-	// Monitor each FIFO in a separate goroutine to avoid busy polling
-	var wg sync.WaitGroup
-
-	// Monitor request_in
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		fm.monitorSingleFIFO(ctx, fm.config.GlobalFIFOPath(RequestIn), fm.handleRequestIn)
-	}()
-
-	// Monitor name
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		fm.monitorSingleFIFO(ctx, fm.config.GlobalFIFOPath(Name), fm.handleNameChange)
-	}()
+	fm.monitorCommands(ctx, fm.client.globalCommands(), fm.config.GlobalFIFOPath)
+}
 
-	// Monitor status_message
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		fm.monitorSingleFIFO(ctx, fm.config.GlobalFIFOPath(StatusMessage), fm.handleStatusMessageChange)
-	}()
+// monitorCommands monitors the input FIFO of every command, each in a
+// separate goroutine to avoid busy polling, and reports rejected commands
+func (fm *FIFOManager) monitorCommands(ctx context.Context, commands map[string]command, path func(string) string) {
+	var wg sync.WaitGroup
 
-	// Monitor conference_in
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		fm.monitorSingleFIFO(ctx, fm.config.GlobalFIFOPath(ConferenceIn), fm.handleConferenceIn)
-	}()
+	for name, cmd := range commands {
+		fifoPath, cmd := path(name), cmd
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fm.monitorSingleFIFO(ctx, fifoPath, func(input string) {
+				if err := cmd(input); err != nil {
+					fm.reportError(fifoPath, input, err)
+				}
+			})
+		}()
+	}
 
 	// Wait for all monitoring goroutines to finish
 	wg.Wait()
@@ -425,8 +542,11 @@ func (fm *FIFOManager) monitorSingleFIFO(ctx context.Context, path string, handl
 
 		// Use blocking read to avoid busy polling
 		if err := fm.readFIFOBlocking(ctx, path, handler); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			if fm.config.Debug {
-				log.Printf("Error reading FIFO %s: %v", path, err)
+				fm.client.logf("Error reading FIFO %s: %v", path, err)
 			}
 			// Brief sleep before retrying to avoid rapid error loops
 			time.Sleep(1 * time.Second)
@@ -434,14 +554,18 @@ func (fm *FIFOManager) monitorSingleFIFO(ctx context.Context, path string, handl
 	}
 }
 
-// readFIFOBlocking reads from a FIFO with blocking I/O
+// readFIFOBlocking reads from a FIFO with blocking I/O. Cancelling ctx ends
+// both the open, which waits for a writer, and a read waiting for input.
 func (fm *FIFOManager) readFIFOBlocking(ctx context.Context, path string, handler func(string)) error {
 	// Open FIFO for reading (blocking mode)
+	stop := context.AfterFunc(ctx, func() { wakeFIFOReader(path) })
 	file, err := os.OpenFile(path, os.O_RDONLY, 0)
+	stop()
 	if err != nil {
 		return err
 	}
 	defer file.Close()
+	defer context.AfterFunc(ctx, func() { file.Close() })()
 
 	reader := bufio.NewReader(file)
 	for {
@@ -468,66 +592,27 @@ func (fm *FIFOManager) readFIFOBlocking(ctx context.Context, path string, handle
 	}
 }
 
+// wakeFIFOReader briefly opens path as a writer, which lets an open for
+// reading that is waiting for one return
+func wakeFIFOReader(path string) {
+	if file, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+		file.Close()
+	}
+}
+
 // monitorFriendFIFOs monitors FIFO files for a specific friend
 func (fm *FIFOManager) monitorFriendFIFOs(ctx context.Context, friendID string) {
-	// Monitor each friend FIFO in a separate goroutine to avoid busy polling
-	var wg sync.WaitGroup
-
-	// Monitor text_in
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		textInPath := fm.config.FriendFIFOPath(friendID, TextIn)
-		fm.monitorSingleFIFO(ctx, textInPath, func(data string) { fm.handleFriendTextIn(friendID, data) })
-	}()
-
-	// Monitor file_in
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		fileInPath := fm.config.FriendFIFOPath(friendID, FileIn)
-		fm.monitorSingleFIFO(ctx, fileInPath, func(data string) { fm.handleFriendFileIn(friendID, data) })
-	}()
-
-	// Monitor remove_in
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		removeInPath := fm.config.FriendFIFOPath(friendID, RemoveIn)
-		fm.monitorSingleFIFO(ctx, removeInPath, func(data string) { fm.handleFriendRemoveIn(friendID, data) })
-	}()
-
-	// Wait for all monitoring goroutines to finish
-	wg.Wait()
+	fm.monitorCommands(ctx, fm.client.friendCommands(friendID), func(name string) string {
+		return fm.config.FriendFIFOPath(friendID, name)
+	})
 }
 
 // monitorConferenceFIFOs monitors FIFO files for a conference
 func (fm *FIFOManager) monitorConferenceFIFOs(ctx context.Context, conferenceID uint32) {
 	conferenceIDStr := fmt.Sprintf("%d", conferenceID)
-	var wg sync.WaitGroup
-
-	// Monitor text_in
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		textInPath := fm.config.ConferenceFIFOPath(conferenceIDStr, ConferenceTextIn)
-		fm.monitorSingleFIFO(ctx, textInPath, func(data string) {
-			fm.handleConferenceTextIn(conferenceID, data)
-		})
-	}()
-
-	// Monitor invite_in
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		inviteInPath := fm.config.ConferenceFIFOPath(conferenceIDStr, ConferenceInviteIn)
-		fm.monitorSingleFIFO(ctx, inviteInPath, func(data string) {
-			fm.handleConferenceInviteIn(conferenceID, data)
-		})
-	}()
-
-	// Wait for all monitoring goroutines to finish
-	wg.Wait()
+	fm.monitorCommands(ctx, fm.client.conferenceCommands(conferenceID), func(name string) string {
+		return fm.config.ConferenceFIFOPath(conferenceIDStr, name)
+	})
 }
 
 // readFIFO reads data from a FIFO and calls the handler function
@@ -608,364 +693,261 @@ func (fm *FIFOManager) writeFIFO(path, data string) error {
 	return nil
 }
 
-// FIFO event handlers
-
-// handleRequestIn processes friend request acceptance
-func (fm *FIFOManager) handleRequestIn(toxID string) {
-	toxID = strings.TrimSpace(toxID)
+// reportError logs a rejected command and writes it to the errors FIFO in
+// the same directory as the input FIFO. Errors from friend and conference
+// directories are also written to client/errors.
+func (fm *FIFOManager) reportError(fifoPath, input string, reason error) {
+	fm.client.logf("Rejected command on %s: %v", fifoPath, reason)
 
-	// Accept both 64-character public key and 76-character full Tox ID
-	var publicKeyHex string
-	if len(toxID) == 64 {
-		// 64-character public key format
-		publicKeyHex = toxID
-	} else if len(toxID) == 76 {
-		// 76-character full Tox ID format (public key + nospam + checksum)
-		publicKeyHex = toxID[:64]
-	} else {
-		log.Printf("Invalid Tox ID format: expected 64 or 76 characters, got %d", len(toxID))
-		return
+	dir := filepath.Dir(fifoPath)
+	line := formatErrorLine(filepath.Base(fifoPath), input, reason)
+	if err := fm.writeFIFO(filepath.Join(dir, ErrorsOut), line); err != nil && fm.config.Debug {
+		fm.client.logf("Failed to write errors FIFO: %v", err)
 	}
 
-	// Decode public key from hex
-	publicKeyBytes, err := hex.DecodeString(publicKeyHex)
-	if err != nil {
-		log.Printf("Invalid public key in Tox ID: %v", err)
+	globalPath := fm.config.GlobalFIFOPath(ErrorsOut)
+	if filepath.Join(dir, ErrorsOut) == globalPath {
 		return
 	}
 
-	var publicKey [32]byte
-	copy(publicKey[:], publicKeyBytes)
-
-	// Accept friend request
-	if _, err := fm.client.AcceptFriendRequest(publicKey); err != nil {
-		log.Printf("Failed to accept friend request: %v", err)
-	}
-}
-
-// handleNameChange processes display name changes
-func (fm *FIFOManager) handleNameChange(name string) {
-	name = strings.TrimSpace(name)
-	if len(name) == 0 {
-		log.Printf("Empty name provided")
-		return
+	name := fifoPath
+	if rel, err := filepath.Rel(fm.config.ConfigDir, fifoPath); err == nil {
+		name = rel
 	}
-
-	if err := fm.client.UpdateSelfName(name); err != nil {
-		log.Printf("Failed to update name: %v", err)
+	if err := fm.writeFIFO(globalPath, formatErrorLine(name, input, reason)); err != nil && fm.config.Debug {
+		fm.client.logf("Failed to write errors FIFO: %v", err)
 	}
 }
 
-// handleStatusMessageChange processes status message changes
-func (fm *FIFOManager) handleStatusMessageChange(message string) {
-	message = strings.TrimSpace(message)
-
-	if err := fm.client.UpdateSelfStatusMessage(message); err != nil {
-		log.Printf("Failed to update status message: %v", err)
-	}
+// formatErrorLine formats a rejected command as "<fifo> <quoted input>
+// <reason>" so the input stays on one field even if it contains spaces
+func formatErrorLine(fifoName, input string, reason error) string {
+	return fmt.Sprintf("%s %s %v", fifoName, strconv.Quote(input), reason)
 }
 
-// handleConferenceIn processes conference creation requests
-func (fm *FIFOManager) handleConferenceIn(input string) {
-	input = strings.TrimSpace(input)
-	if input == "" {
-		return
-	}
-
-	// Create a new conference
-	conferenceID, err := fm.client.CreateConference()
-	if err != nil {
-		log.Printf("Failed to create conference: %v", err)
-		return
-	}
-
-	// Create conference directory and FIFOs
-	if err := fm.createConferenceFIFOs(conferenceID); err != nil {
-		log.Printf("Failed to create conference FIFOs: %v", err)
-		return
+// FriendRemoved removes a deleted friend's directory
+func (fm *FIFOManager) FriendRemoved(friendID string) error {
+	if err := os.RemoveAll(fm.config.FriendDir(friendID)); err != nil {
+		return fmt.Errorf("failed to remove friend directory: %w", err)
 	}
+	return nil
+}
 
-	// Start monitoring conference FIFOs
-	go fm.monitorConferenceFIFOs(fm.ctx, conferenceID)
-
-	if fm.config.Debug {
-		log.Printf("Created conference %d", conferenceID)
-	}
+// AliasChanged moves the friend's by-name symlink
+func (fm *FIFOManager) AliasChanged(friendID, oldAlias, newAlias string) error {
+	return fm.updateAliasLink(friendID, oldAlias, newAlias)
 }
 
-// handleFriendTextIn processes outgoing text messages
-func (fm *FIFOManager) handleFriendTextIn(friendID, message string) {
-	message = strings.TrimSpace(message)
-	if len(message) == 0 {
-		return
+// updateAliasLink replaces the by-name symlink for oldAlias with one for
+// newAlias. Either alias may be empty.
+func (fm *FIFOManager) updateAliasLink(friendID, oldAlias, newAlias string) error {
+	if oldAlias != "" && oldAlias != newAlias {
+		if err := os.Remove(fm.config.AliasLinkPath(oldAlias)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove alias link: %w", err)
+		}
 	}
 
-	// Find friend by public key
-	publicKeyBytes, err := hex.DecodeString(friendID)
-	if err != nil {
-		log.Printf("Invalid friend ID: %v", err)
-		return
+	if newAlias == "" {
+		return nil
 	}
 
-	// Validate public key length to prevent buffer overflow
-	if len(publicKeyBytes) != 32 {
-		log.Printf("Invalid friend ID length: expected 32 bytes, got %d", len(publicKeyBytes))
-		return
+	if err := os.MkdirAll(fm.config.AliasDir(), DirPerm); err != nil {
+		return fmt.Errorf("failed to create alias directory: %w", err)
 	}
 
-	var publicKey [32]byte
-	copy(publicKey[:], publicKeyBytes)
-
-	// Find friend number by public key using toxcore API
-	friendNum, err := fm.client.tox.FriendByPublicKey(publicKey)
-	if err != nil {
-		log.Printf("Friend not found: %s (%v)", friendID, err)
-		return
+	linkPath := fm.config.AliasLinkPath(newAlias)
+	if err := os.Remove(linkPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace alias link: %w", err)
 	}
 
-	// Determine message type (action messages start with "/me ")
-	messageType := toxcore.MessageTypeNormal
-	if strings.HasPrefix(message, "/me ") {
-		messageType = toxcore.MessageTypeAction
-		message = strings.TrimPrefix(message, "/me ")
+	// Relative target so the profile directory can be moved
+	target := filepath.Join("..", friendID)
+	if err := os.Symlink(target, linkPath); err != nil {
+		return fmt.Errorf("failed to create alias link: %w", err)
 	}
 
-	// Send message
-	if err := fm.client.SendMessage(friendNum, message, messageType); err != nil {
-		log.Printf("Failed to send message to friend %s: %v", friendID, err)
-	}
+	return nil
 }
 
-// handleFriendFileIn processes outgoing file transfers
-func (fm *FIFOManager) handleFriendFileIn(friendID, filePath string) {
-	filePath = strings.TrimSpace(filePath)
-	if len(filePath) == 0 {
-		return
+// syncAliasLinks rebuilds the by-name directory from the alias store,
+// removing links left behind by aliases that no longer exist
+func (fm *FIFOManager) syncAliasLinks() error {
+	aliasDir := fm.config.AliasDir()
+	if err := os.MkdirAll(aliasDir, DirPerm); err != nil {
+		return fmt.Errorf("failed to create alias directory: %w", err)
 	}
 
-	log.Printf("File transfer request for %s: %s", friendID, filePath)
-
-	friendNum, err := fm.resolveFriendNumber(friendID)
+	entries, err := os.ReadDir(aliasDir)
 	if err != nil {
-		return
+		return fmt.Errorf("failed to read alias directory: %w", err)
 	}
-
-	fileInfo, fileSize, err := fm.validateFileForSending(filePath)
-	if err != nil {
-		return
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		if err := os.Remove(filepath.Join(aliasDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove stale alias link: %w", err)
+		}
 	}
 
-	file, transferID, err := fm.initiateFileSend(friendNum, filePath, fileSize, fileInfo)
-	if err != nil {
-		return
+	for friendID, alias := range fm.client.aliases.All() {
+		if err := fm.updateAliasLink(friendID, "", alias); err != nil {
+			return err
+		}
 	}
 
-	fm.trackOutgoingTransfer(friendNum, transferID, file, filePath, fileInfo.Name(), fileSize)
-	log.Printf("File transfer initiated: %s (%d bytes) to friend %d, transfer ID: %d", fileInfo.Name(), fileSize, friendNum, transferID)
+	return nil
 }
 
-func (fm *FIFOManager) resolveFriendNumber(friendID string) (uint32, error) {
-	publicKeyBytes, err := hex.DecodeString(friendID)
-	if err != nil {
-		log.Printf("Invalid friend ID: %v", err)
-		return 0, err
-	}
-
-	if len(publicKeyBytes) != 32 {
-		err := fmt.Errorf("invalid friend ID length: expected 32 bytes, got %d", len(publicKeyBytes))
-		log.Print(err)
-		return 0, err
-	}
-
-	var publicKey [32]byte
-	copy(publicKey[:], publicKeyBytes)
-
-	friendNum, err := fm.client.tox.FriendByPublicKey(publicKey)
-	if err != nil {
-		log.Printf("Friend not found: %s (%v)", friendID, err)
-		return 0, err
-	}
+// Write functions for output FIFOs
 
-	return friendNum, nil
+// FriendRequest writes a friend request to the request_out FIFO
+func (fm *FIFOManager) FriendRequest(friendID, message string) error {
+	path := fm.config.GlobalFIFOPath(RequestOut)
+	data := fmt.Sprintf("%s %s", friendID, message)
+	return fm.writeFIFO(path, data)
 }
 
-func (fm *FIFOManager) validateFileForSending(filePath string) (os.FileInfo, uint64, error) {
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		log.Printf("File not found or inaccessible: %v", err)
-		return nil, 0, err
-	}
-
-	if fileInfo.IsDir() {
-		err := fmt.Errorf("cannot send directory: %s", filePath)
-		log.Print(err)
-		return nil, 0, err
-	}
-
-	rawSize := fileInfo.Size()
-	if rawSize < 0 {
-		err := fmt.Errorf("invalid file size (negative): %d", rawSize)
-		log.Print(err)
-		return nil, 0, err
-	}
-	if fm.client.config.MaxFileSize > 0 && rawSize > fm.client.config.MaxFileSize {
-		err := fmt.Errorf("file too large (%d bytes), maximum allowed: %d", rawSize, fm.client.config.MaxFileSize)
-		log.Print(err)
-		return nil, 0, err
-	}
-	fileSize := uint64(rawSize)
-
-	return fileInfo, fileSize, nil
+// FriendText writes a message to a friend's text_out FIFO
+func (fm *FIFOManager) FriendText(friendID, line string) error {
+	path := fm.config.FriendFIFOPath(friendID, TextOut)
+	return fm.writeFIFO(path, line)
 }
 
-func (fm *FIFOManager) initiateFileSend(friendNum uint32, filePath string, fileSize uint64, fileInfo os.FileInfo) (*os.File, uint32, error) {
-	h := sha256.Sum256([]byte(filePath))
-	fileID := h
+// FriendStatus writes status to a friend's status FIFO
+func (fm *FIFOManager) FriendStatus(friendID, line string) error {
+	path := fm.config.FriendFIFOPath(friendID, Status)
+	return fm.writeFIFO(path, line)
+}
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		log.Printf("Failed to open file: %v", err)
-		return nil, 0, err
-	}
+// FriendStatusMessage writes status message to a friend's status_message FIFO
+func (fm *FIFOManager) FriendStatusMessage(friendID, statusMessage string) error {
+	path := fm.config.FriendFIFOPath(friendID, FriendStatusMessage)
+	return fm.writeFIFO(path, statusMessage)
+}
 
-	filename := filepath.Base(filePath)
-	transferID, err := fm.client.tox.FileSend(friendNum, 0, fileSize, fileID, filename)
-	if err != nil {
-		log.Printf("Failed to initiate file transfer: %v", err)
-		file.Close()
-		return nil, 0, err
+// FriendTyping writes "1" or "0" to a friend's typing FIFO
+func (fm *FIFOManager) FriendTyping(friendID string, typing bool) error {
+	typingStatus := "0"
+	if typing {
+		typingStatus = "1"
 	}
 
-	return file, transferID, nil
+	path := fm.config.FriendFIFOPath(friendID, Typing)
+	return fm.writeFIFO(path, typingStatus)
 }
 
-func (fm *FIFOManager) trackOutgoingTransfer(friendNum, transferID uint32, file *os.File, filePath, filename string, fileSize uint64) {
-	transferKey := fmt.Sprintf("%d:%d", friendNum, transferID)
-	fm.client.transfersMu.Lock()
-	defer fm.client.transfersMu.Unlock()
-
-	fm.client.outgoingTransfers[transferKey] = &outgoingTransfer{
-		File:         file,
-		FilePath:     filePath,
-		Filename:     filename,
-		FileSize:     fileSize,
-		Sent:         0,
-		LastActivity: time.Now(),
-	}
+// FriendFile writes file transfer info to a friend's file_out FIFO
+func (fm *FIFOManager) FriendFile(friendID, line string) error {
+	path := fm.config.FriendFIFOPath(friendID, FileOut)
+	return fm.writeFIFO(path, line)
 }
 
-// handleFriendRemoveIn processes friend removal requests
-func (fm *FIFOManager) handleFriendRemoveIn(friendID, data string) {
-	data = strings.TrimSpace(data)
-	if data == "" {
-		return
-	}
+// FriendChanged rewrites the friend's metadata files
+func (fm *FIFOManager) FriendChanged(friendID string, friend *Friend) error {
+	return fm.writeFriendInfoFiles(friendID, friend)
+}
 
-	if fm.client.config.Debug {
-		log.Printf("Friend removal requested for %s with confirmation: %s", friendID, data)
-	}
+// ConnectionChanged rewrites client/connection_status
+func (fm *FIFOManager) ConnectionChanged() error {
+	return fm.createConnectionStatusFile()
+}
 
-	if !fm.validateRemovalConfirmation(friendID, data) {
-		return
-	}
+// IDChanged rewrites client/id
+func (fm *FIFOManager) IDChanged() error {
+	return fm.createIDFile()
+}
 
-	friendNum, err := fm.getFriendNumber(friendID)
-	if err != nil {
-		return
-	}
+// ConfigChanged rewrites client/config_status
+func (fm *FIFOManager) ConfigChanged() error {
+	return fm.writeConfigStatusFile()
+}
 
-	fm.removeFriend(friendID, friendNum)
+// BootstrapChanged rewrites client/bootstrap_status
+func (fm *FIFOManager) BootstrapChanged() error {
+	return fm.writeBootstrapStatusFile()
 }
 
-func (fm *FIFOManager) validateRemovalConfirmation(friendID, data string) bool {
-	if data != "confirm" && data != friendID {
-		log.Printf("Invalid removal confirmation. Expected 'confirm' or friend ID, got: %s", data)
-		return false
-	}
-	return true
+// RequestsChanged rewrites client/requests_pending
+func (fm *FIFOManager) RequestsChanged() error {
+	return fm.writeRequestsPendingFile()
 }
 
-func (fm *FIFOManager) getFriendNumber(friendID string) (uint32, error) {
-	publicKeyBytes, err := hex.DecodeString(friendID)
-	if err != nil {
-		log.Printf("Invalid friend ID: %v", err)
-		return 0, err
+// writeFriendInfoFiles writes the regular metadata files in a friend's
+// directory so scripts can read current values without attaching to a FIFO
+func (fm *FIFOManager) writeFriendInfoFiles(friendID string, friend *Friend) error {
+	for _, file := range friendInfoFiles(friendID, friend) {
+		path := fm.config.FriendFIFOPath(friendID, file.name)
+		if err := os.WriteFile(path, []byte(file.value+"\n"), 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
 	}
 
-	if len(publicKeyBytes) != 32 {
-		log.Printf("Invalid friend ID length: expected 32 bytes, got %d", len(publicKeyBytes))
-		return 0, fmt.Errorf("invalid length")
-	}
+	return nil
+}
 
-	var publicKey [32]byte
-	copy(publicKey[:], publicKeyBytes)
+// friendInfoFile is a friend metadata file and its current value
+type friendInfoFile struct {
+	name  string
+	value string
+}
 
-	friendNum, err := fm.client.tox.FriendByPublicKey(publicKey)
-	if err != nil {
-		log.Printf("Friend not found: %s (%v)", friendID, err)
-		return 0, err
+// friendInfoFiles returns the metadata files kept in a friend's directory
+func friendInfoFiles(friendID string, friend *Friend) []friendInfoFile {
+	return []friendInfoFile{
+		{FriendName, friend.Name},
+		{FriendPublicKey, friendID},
+		{FriendLastSeen, lastSeenString(friend)},
+		{FriendConnection, connectionTypeString(friend.Connection)},
+		{FriendUserStatus, userStatusString(friend.Status)},
 	}
-
-	return friendNum, nil
 }
 
-func (fm *FIFOManager) removeFriend(friendID string, friendNum uint32) {
-	if err := fm.client.tox.DeleteFriend(friendNum); err != nil {
-		log.Printf("Failed to delete friend %d: %v", friendNum, err)
-		return
+// lastSeenString describes when a friend was last seen: "online" while
+// connected, "never" if unknown, otherwise an RFC 3339 time
+func lastSeenString(friend *Friend) string {
+	if friend.Online {
+		return "online"
 	}
-
-	fm.client.friendsMu.Lock()
-	delete(fm.client.friends, friendNum)
-	fm.client.friendsMu.Unlock()
-
-	friendDir := fm.config.FriendDir(friendID)
-	if err := os.RemoveAll(friendDir); err != nil {
-		log.Printf("Failed to remove friend directory %s: %v", friendDir, err)
+	if friend.LastSeen.IsZero() {
+		return "never"
 	}
-
-	fm.client.saveToxData()
-
-	log.Printf("Friend %s removed successfully", friendID)
-}
-
-// Write functions for output FIFOs
-
-// WriteRequestOut writes a friend request to the request_out FIFO
-func (fm *FIFOManager) WriteRequestOut(friendID, message string) error {
-	path := fm.config.GlobalFIFOPath(RequestOut)
-	data := fmt.Sprintf("%s %s", friendID, message)
-	return fm.writeFIFO(path, data)
-}
-
-// WriteFriendTextOut writes a message to a friend's text_out FIFO
-func (fm *FIFOManager) WriteFriendTextOut(friendID, message string) error {
-	path := fm.config.FriendFIFOPath(friendID, TextOut)
-	return fm.writeFIFO(path, message)
+	return friend.LastSeen.Format(time.RFC3339)
 }
 
-// WriteFriendStatus writes status to a friend's status FIFO
-func (fm *FIFOManager) WriteFriendStatus(friendID, status string) error {
-	path := fm.config.FriendFIFOPath(friendID, Status)
-	return fm.writeFIFO(path, status)
-}
+// writeLastSeenFile writes every friend as "<public_key> <last_seen> <quoted name>",
+// longest-absent first, so stale contacts are easy to find
+func (fm *FIFOManager) writeLastSeenFile() error {
+	listPath := fm.config.GlobalFIFOPath(LastSeenList)
 
-// WriteFriendStatusMessage writes status message to a friend's status_message FIFO
-func (fm *FIFOManager) WriteFriendStatusMessage(friendID, statusMessage string) error {
-	path := fm.config.FriendFIFOPath(friendID, FriendStatusMessage)
-	return fm.writeFIFO(path, statusMessage)
-}
+	if err := os.WriteFile(listPath, []byte(fm.client.lastSeenText()), 0o600); err != nil {
+		return fmt.Errorf("failed to write last seen file: %w", err)
+	}
 
-// WriteFriendTyping writes typing status to a friend's typing FIFO
-func (fm *FIFOManager) WriteFriendTyping(friendID, typingStatus string) error {
-	path := fm.config.FriendFIFOPath(friendID, Typing)
-	return fm.writeFIFO(path, typingStatus)
+	return nil
 }
 
-// WriteFriendFileOut writes file transfer info to a friend's file_out FIFO
-func (fm *FIFOManager) WriteFriendFileOut(friendID, fileInfo string) error {
-	path := fm.config.FriendFIFOPath(friendID, FileOut)
-	return fm.writeFIFO(path, fileInfo)
+// lastSeenText lists every friend as "<public_key> <last_seen> <quoted name>",
+// longest-absent first
+func (c *Client) lastSeenText() string {
+	friends := c.Friends()
+	sort.SliceStable(friends, func(i, j int) bool {
+		a, b := friends[i], friends[j]
+		if a.Online != b.Online {
+			return !a.Online
+		}
+		return a.LastSeen.Before(b.LastSeen)
+	})
+
+	var sb strings.Builder
+	for i := range friends {
+		friend := &friends[i]
+		if friend.PublicKey == ([32]byte{}) {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s %s %s\n", hex.EncodeToString(friend.PublicKey[:]), lastSeenString(friend), strconv.Quote(friend.Name))
+	}
+	return sb.String()
 }
 
 // periodicCleanup performs periodic maintenance tasks
@@ -994,7 +976,7 @@ func (fm *FIFOManager) cleanupUnusedFIFOs() {
 	for path, fifo := range fm.fifos {
 		if fifo.LastUsed.Before(cutoff) && !isGlobalFIFO(path) {
 			if fm.config.Debug {
-				log.Printf("Cleaning up unused FIFO: %s", path)
+				fm.client.logf("Cleaning up unused FIFO: %s", path)
 			}
 			delete(fm.fifos, path)
 		}
@@ -1004,46 +986,5 @@ func (fm *FIFOManager) cleanupUnusedFIFOs() {
 // isGlobalFIFO returns true if the path is a global FIFO
 func isGlobalFIFO(path string) bool {
 	name := filepath.Base(path)
-	return name == RequestIn || name == RequestOut || name == Name || name == StatusMessage || name == ConferenceIn
-}
-
-// handleConferenceTextIn processes outgoing conference messages
-func (fm *FIFOManager) handleConferenceTextIn(conferenceID uint32, message string) {
-	message = strings.TrimSpace(message)
-	if message == "" {
-		return
-	}
-
-	if err := fm.client.SendConferenceMessage(conferenceID, message); err != nil {
-		log.Printf("Failed to send conference message: %v", err)
-	}
-}
-
-// handleConferenceInviteIn processes conference invite requests
-func (fm *FIFOManager) handleConferenceInviteIn(conferenceID uint32, friendID string) {
-	friendID = strings.TrimSpace(friendID)
-	if friendID == "" {
-		return
-	}
-
-	// Convert hex friend ID to friend number
-	publicKeyBytes, err := hex.DecodeString(friendID)
-	if err != nil {
-		log.Printf("Invalid friend ID for conference invite: %v", err)
-		return
-	}
-
-	var publicKey [32]byte
-	copy(publicKey[:], publicKeyBytes)
-
-	// Look up friend number
-	friendNum, err := fm.client.tox.FriendByPublicKey(publicKey)
-	if err != nil {
-		log.Printf("Friend not found for conference invite: %v", err)
-		return
-	}
-
-	if err := fm.client.InviteToConference(friendNum, conferenceID); err != nil {
-		log.Printf("Failed to invite friend to conference: %v", err)
-	}
+	return name == RequestIn || name == RequestOut || name == RequestReject || name == RequestSend || name == Name || name == StatusMessage || name == ConferenceIn || name == NospamIn || name == ErrorsOut
 }
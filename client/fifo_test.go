@@ -1,11 +1,17 @@
 package client
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/opd-ai/go-ratox/config"
+	"github.com/opd-ai/toxcore"
 )
 
 // TestFIFOPathGeneration tests FIFO path generation using config methods
@@ -296,6 +302,7 @@ func TestFIFOConstants(t *testing.T) {
 		{"Status constant", Status, "status"},
 		{"RemoveIn constant", RemoveIn, "remove_in"},
 		{"Typing constant", Typing, "typing"},
+		{"ErrorsOut constant", ErrorsOut, "errors"},
 	}
 
 	for _, tt := range tests {
@@ -386,8 +393,7 @@ func TestFriendRemovalConfirmation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Friend removal confirmation logic
-			isValid := tt.input == "confirm" || tt.input == friendID
+			isValid := validateRemovalConfirmation(friendID, tt.input) == nil
 
 			if isValid != tt.isValid {
 				t.Errorf("Expected validity %v, got %v for input '%s'", tt.isValid, isValid, tt.input)
@@ -443,3 +449,182 @@ func isHexString(s string) bool {
 	}
 	return true
 }
+
+// TestParseNospam tests nospam parsing for the nospam_in FIFO
+func TestParseNospam(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    [4]byte
+		expectError bool
+	}{
+		{"lowercase hex", "deadbeef", [4]byte{0xde, 0xad, 0xbe, 0xef}, false},
+		{"uppercase hex", "0102A0FF", [4]byte{0x01, 0x02, 0xa0, 0xff}, false},
+		{"too short", "dead", [4]byte{}, true},
+		{"too long", "deadbeef00", [4]byte{}, true},
+		{"non-hex", "zzzzzzzz", [4]byte{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nospam, err := parseNospam(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if nospam != tt.expected {
+				t.Errorf("Expected nospam %x, got %x", tt.expected, nospam)
+			}
+		})
+	}
+}
+
+// TestWriteFriendInfoFiles tests the per-friend metadata files
+func TestWriteFriendInfoFiles(t *testing.T) {
+	cfg := &config.Config{ConfigDir: t.TempDir()}
+	fm := &FIFOManager{config: cfg}
+	friendID := strings.Repeat("ef", 32)
+
+	if err := os.MkdirAll(cfg.FriendDir(friendID), DirPerm); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	lastSeen := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	friend := &Friend{
+		Name:       "Eve",
+		Status:     2,
+		Online:     false,
+		Connection: toxcore.ConnectionNone,
+		LastSeen:   lastSeen,
+	}
+
+	if err := fm.writeFriendInfoFiles(friendID, friend); err != nil {
+		t.Fatalf("writeFriendInfoFiles failed: %v", err)
+	}
+
+	expected := map[string]string{
+		FriendName:       "Eve",
+		FriendPublicKey:  friendID,
+		FriendLastSeen:   lastSeen.Format(time.RFC3339),
+		FriendConnection: "offline",
+		FriendUserStatus: "busy",
+	}
+
+	for name, want := range expected {
+		data, err := os.ReadFile(cfg.FriendFIFOPath(friendID, name))
+		if err != nil {
+			t.Errorf("Failed to read %s: %v", name, err)
+			continue
+		}
+		if got := strings.TrimSpace(string(data)); got != want {
+			t.Errorf("Expected %s to contain %q, got %q", name, want, got)
+		}
+	}
+}
+
+// TestReportError tests that rejected commands are written to the errors FIFO
+// next to the input and mirrored to client/errors
+func TestReportError(t *testing.T) {
+	cfg := &config.Config{ConfigDir: t.TempDir()}
+	fm := &FIFOManager{config: cfg, fifos: make(map[string]*FIFO)}
+	friendID := strings.Repeat("ab", 32)
+
+	for _, dir := range []string{filepath.Dir(cfg.GlobalFIFOPath(ErrorsOut)), cfg.FriendDir(friendID)} {
+		if err := os.MkdirAll(dir, DirPerm); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+	}
+
+	readers := make(map[string]*os.File)
+	for _, path := range []string{cfg.GlobalFIFOPath(ErrorsOut), cfg.FriendFIFOPath(friendID, ErrorsOut)} {
+		if err := fm.createFIFO(path, false, true); err != nil {
+			t.Fatalf("createFIFO failed: %v", err)
+		}
+		reader, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		defer reader.Close()
+		readers[path] = reader
+	}
+
+	fm.reportError(cfg.FriendFIFOPath(friendID, TextIn), "hello there", fmt.Errorf("message too long"))
+
+	expected := map[string]string{
+		cfg.FriendFIFOPath(friendID, ErrorsOut): `text_in "hello there" message too long`,
+		cfg.GlobalFIFOPath(ErrorsOut):           friendID + `/text_in "hello there" message too long`,
+	}
+	for path, want := range expected {
+		buf := make([]byte, 512)
+		n, err := readers[path].Read(buf)
+		if err != nil {
+			t.Fatalf("Read %s failed: %v", path, err)
+		}
+		if got := strings.TrimSpace(string(buf[:n])); got != want {
+			t.Errorf("Expected %q in %s, got %q", want, path, got)
+		}
+	}
+}
+
+// TestLastSeenString tests how last seen times are described
+func TestLastSeenString(t *testing.T) {
+	seen := time.Date(2023, 11, 2, 17, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		friend   Friend
+		expected string
+	}{
+		{"online friend", Friend{Online: true, LastSeen: seen}, "online"},
+		{"never seen", Friend{}, "never"},
+		{"seen before", Friend{LastSeen: seen}, "2023-11-02T17:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastSeenString(&tt.friend); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestReadFIFOBlockingCancel tests that cancelling ends a read waiting for a
+// writer to open the FIFO and one waiting for input from an open writer
+func TestReadFIFOBlockingCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "text_in")
+	if err := syscall.Mkfifo(path, 0o600); err != nil {
+		t.Fatalf("Mkfifo failed: %v", err)
+	}
+	fm := &FIFOManager{config: &config.Config{}}
+
+	for _, withWriter := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			fm.readFIFOBlocking(ctx, path, func(string) {})
+			close(done)
+		}()
+
+		if withWriter {
+			writer, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			defer writer.Close()
+		}
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("readFIFOBlocking did not return after cancellation (writer open: %v)", withWriter)
+		}
+	}
+}
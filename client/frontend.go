@@ -0,0 +1,235 @@
+// Package client implements the frontend abstraction for ratox-go
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// Frontend presents the client to its user. The client reports every change
+// to each attached frontend, and a frontend runs the user's commands against
+// the client while it is served. The FIFOManager is the default frontend.
+type Frontend interface {
+	// Start prepares the frontend before the client starts serving
+	Start() error
+	// Serve runs inbound commands until ctx is cancelled
+	Serve(ctx context.Context)
+
+	// FriendRequest reports an incoming friend request
+	FriendRequest(friendID, message string) error
+	// RequestsChanged reports a change to the pending friend requests
+	RequestsChanged() error
+	// FriendAdded reports a friend whose public key became known
+	FriendAdded(friendID string) error
+	// FriendRemoved reports a deleted friend
+	FriendRemoved(friendID string) error
+	// FriendChanged reports new details of a friend
+	FriendChanged(friendID string, friend *Friend) error
+	// FriendListChanged reports a change to the roster of all friends
+	FriendListChanged() error
+	// AliasChanged reports a friend's nickname change. Either alias may be
+	// empty.
+	AliasChanged(friendID, oldAlias, newAlias string) error
+	// FriendText reports a message from a friend as a text_out line
+	FriendText(friendID, line string) error
+	// FriendStatus reports a friend's user status or connection as a status line
+	FriendStatus(friendID, line string) error
+	// FriendStatusMessage reports a friend's new status message
+	FriendStatusMessage(friendID, message string) error
+	// FriendTyping reports whether a friend is typing
+	FriendTyping(friendID string, typing bool) error
+	// FriendFile reports a file transfer as a file_out line
+	FriendFile(friendID, line string) error
+	// ConnectionChanged reports a change of the client's own connection
+	ConnectionChanged() error
+	// IDChanged reports a change of the client's Tox ID
+	IDChanged() error
+	// ConfigChanged reports a reload of the configuration file
+	ConfigChanged() error
+	// BootstrapChanged reports new bootstrap node statistics
+	BootstrapChanged() error
+	// ConferenceAdded reports a new conference
+	ConferenceAdded(conferenceID uint32) error
+}
+
+// NopFrontend ignores every notification. Frontends that only need some of
+// them, or that follow the event bus instead, can embed it.
+type NopFrontend struct{}
+
+func (NopFrontend) FriendRequest(friendID, message string) error           { return nil }
+func (NopFrontend) RequestsChanged() error                                 { return nil }
+func (NopFrontend) FriendAdded(friendID string) error                      { return nil }
+func (NopFrontend) FriendRemoved(friendID string) error                    { return nil }
+func (NopFrontend) FriendChanged(friendID string, friend *Friend) error    { return nil }
+func (NopFrontend) FriendListChanged() error                               { return nil }
+func (NopFrontend) AliasChanged(friendID, oldAlias, newAlias string) error { return nil }
+func (NopFrontend) FriendText(friendID, line string) error                 { return nil }
+func (NopFrontend) FriendStatus(friendID, line string) error               { return nil }
+func (NopFrontend) FriendStatusMessage(friendID, message string) error     { return nil }
+func (NopFrontend) FriendTyping(friendID string, typing bool) error        { return nil }
+func (NopFrontend) FriendFile(friendID, line string) error                 { return nil }
+func (NopFrontend) ConnectionChanged() error                               { return nil }
+func (NopFrontend) IDChanged() error                                       { return nil }
+func (NopFrontend) ConfigChanged() error                                   { return nil }
+func (NopFrontend) BootstrapChanged() error                                { return nil }
+func (NopFrontend) ConferenceAdded(conferenceID uint32) error              { return nil }
+
+// frontendSet reports every notification to each frontend in turn. The
+// errors of all frontends are joined.
+type frontendSet []Frontend
+
+var _ Frontend = frontendSet(nil)
+
+// newFrontends creates the frontends selected by cfg
+func newFrontends(c *Client, cfg *config.Config) (frontendSet, error) {
+	var set frontendSet
+
+	switch cfg.Frontend {
+	case "", config.FrontendFIFO:
+		set = append(set, NewFIFOManager(c))
+	case config.FrontendFUSE:
+		set = append(set, NewFUSEFrontend(c, cfg.FUSEMountPoint()))
+	default:
+		return nil, fmt.Errorf("unknown frontend %q, expected %q or %q", cfg.Frontend, config.FrontendFIFO, config.FrontendFUSE)
+	}
+
+	if cfg.NineP.Enabled {
+		set = append(set, NewNinePServer(c))
+	}
+
+	return set, nil
+}
+
+// each calls notify for every frontend and joins the errors
+func (s frontendSet) each(notify func(Frontend) error) error {
+	var errs []error
+	for _, f := range s {
+		if err := notify(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Start starts the frontends in order, stopping at the first failure
+func (s frontendSet) Start() error {
+	for _, f := range s {
+		if err := f.Start(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Serve serves every frontend until ctx is cancelled
+func (s frontendSet) Serve(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, f := range s {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.Serve(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s frontendSet) FriendRequest(friendID, message string) error {
+	return s.each(func(f Frontend) error { return f.FriendRequest(friendID, message) })
+}
+
+func (s frontendSet) RequestsChanged() error {
+	return s.each(Frontend.RequestsChanged)
+}
+
+func (s frontendSet) FriendAdded(friendID string) error {
+	return s.each(func(f Frontend) error { return f.FriendAdded(friendID) })
+}
+
+func (s frontendSet) FriendRemoved(friendID string) error {
+	return s.each(func(f Frontend) error { return f.FriendRemoved(friendID) })
+}
+
+func (s frontendSet) FriendChanged(friendID string, friend *Friend) error {
+	return s.each(func(f Frontend) error { return f.FriendChanged(friendID, friend) })
+}
+
+func (s frontendSet) FriendListChanged() error {
+	return s.each(Frontend.FriendListChanged)
+}
+
+func (s frontendSet) AliasChanged(friendID, oldAlias, newAlias string) error {
+	return s.each(func(f Frontend) error { return f.AliasChanged(friendID, oldAlias, newAlias) })
+}
+
+func (s frontendSet) FriendText(friendID, line string) error {
+	return s.each(func(f Frontend) error { return f.FriendText(friendID, line) })
+}
+
+func (s frontendSet) FriendStatus(friendID, line string) error {
+	return s.each(func(f Frontend) error { return f.FriendStatus(friendID, line) })
+}
+
+func (s frontendSet) FriendStatusMessage(friendID, message string) error {
+	return s.each(func(f Frontend) error { return f.FriendStatusMessage(friendID, message) })
+}
+
+func (s frontendSet) FriendTyping(friendID string, typing bool) error {
+	return s.each(func(f Frontend) error { return f.FriendTyping(friendID, typing) })
+}
+
+func (s frontendSet) FriendFile(friendID, line string) error {
+	return s.each(func(f Frontend) error { return f.FriendFile(friendID, line) })
+}
+
+func (s frontendSet) ConnectionChanged() error {
+	return s.each(Frontend.ConnectionChanged)
+}
+
+func (s frontendSet) IDChanged() error {
+	return s.each(Frontend.IDChanged)
+}
+
+func (s frontendSet) ConfigChanged() error {
+	return s.each(Frontend.ConfigChanged)
+}
+
+func (s frontendSet) BootstrapChanged() error {
+	return s.each(Frontend.BootstrapChanged)
+}
+
+func (s frontendSet) ConferenceAdded(conferenceID uint32) error {
+	return s.each(func(f Frontend) error { return f.ConferenceAdded(conferenceID) })
+}
+
+// AddFrontend attaches another frontend to the client. It must be called
+// before Run. The frontend is told about the friends and conferences the
+// client already has.
+func (c *Client) AddFrontend(f Frontend) error {
+	var errs []error
+	for _, friend := range c.Friends() {
+		if friend.PublicKey == ([32]byte{}) {
+			continue
+		}
+		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
+		if err := f.FriendAdded(friendIDStr); err != nil {
+			errs = append(errs, err)
+		}
+		if err := f.FriendChanged(friendIDStr, &friend); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, conferenceID := range c.Conferences() {
+		if err := f.ConferenceAdded(conferenceID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	c.frontends = append(c.frontends, f)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/opd-ai/toxcore"
+)
+
+// recordingFrontend records the notifications it receives
+type recordingFrontend struct {
+	NopFrontend
+	calls []string
+	err   error
+}
+
+func (r *recordingFrontend) Start() error              { return nil }
+func (r *recordingFrontend) Serve(ctx context.Context) {}
+
+func (r *recordingFrontend) record(format string, args ...interface{}) error {
+	r.calls = append(r.calls, fmt.Sprintf(format, args...))
+	return r.err
+}
+
+func (r *recordingFrontend) FriendRequest(friendID, message string) error {
+	return r.record("FriendRequest %s %s", friendID, message)
+}
+
+func (r *recordingFrontend) RequestsChanged() error {
+	return r.record("RequestsChanged")
+}
+
+func (r *recordingFrontend) FriendAdded(friendID string) error {
+	return r.record("FriendAdded %s", friendID)
+}
+
+func (r *recordingFrontend) FriendChanged(friendID string, friend *Friend) error {
+	return r.record("FriendChanged %s %s", friendID, friend.Name)
+}
+
+func (r *recordingFrontend) FriendText(friendID, line string) error {
+	return r.record("FriendText %s", friendID)
+}
+
+func (r *recordingFrontend) FriendTyping(friendID string, typing bool) error {
+	return r.record("FriendTyping %s %v", friendID, typing)
+}
+
+func (r *recordingFrontend) ConferenceAdded(conferenceID uint32) error {
+	return r.record("ConferenceAdded %d", conferenceID)
+}
+
+func TestFrontendSet(t *testing.T) {
+	errFailed := errors.New("failed")
+	first := &recordingFrontend{}
+	failing := &recordingFrontend{err: errFailed}
+	last := &recordingFrontend{}
+	set := frontendSet{first, failing, last}
+
+	// Every frontend is told even when one of them fails
+	if err := set.FriendTyping("abc", true); !errors.Is(err, errFailed) {
+		t.Errorf("FriendTyping error = %v, want %v", err, errFailed)
+	}
+	for i, r := range []*recordingFrontend{first, failing, last} {
+		if want := []string{"FriendTyping abc true"}; !reflect.DeepEqual(r.calls, want) {
+			t.Errorf("Frontend %d calls = %q, want %q", i, r.calls, want)
+		}
+	}
+
+	if err := (frontendSet{first, last}).RequestsChanged(); err != nil {
+		t.Errorf("RequestsChanged error = %v, want nil", err)
+	}
+}
+
+func TestClientNotifiesFrontends(t *testing.T) {
+	c, friendID := newTreeTestClient(t)
+	c.conferences = map[uint32]*Conference{3: {ID: 3}}
+
+	r := &recordingFrontend{}
+	if err := c.AddFrontend(r); err != nil {
+		t.Fatalf("AddFrontend failed: %v", err)
+	}
+
+	c.handleFriendMessage(0, "hi", toxcore.MessageTypeNormal)
+	c.handleFriendTyping(0, false)
+	c.handleFriendTyping(7, true) // unknown friends are not reported
+
+	want := []string{
+		"FriendAdded " + friendID,
+		"FriendChanged " + friendID + " Bob",
+		"ConferenceAdded 3",
+		"FriendText " + friendID,
+		"FriendTyping " + friendID + " false",
+	}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("Calls = %q, want %q", r.calls, want)
+	}
+}
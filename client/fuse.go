@@ -0,0 +1,246 @@
+// Package client implements the FUSE frontend for ratox-go
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// FUSEFrontend presents the same tree as the FIFOManager as a mounted FUSE
+// filesystem backed directly by the client. Output files block on read until
+// the next line is produced, and rejected writes fail with EINVAL. It follows
+// the event bus, so it ignores the Frontend notifications.
+type FUSEFrontend struct {
+	NopFrontend
+	tree       *fileTree
+	mountPoint string
+	server     *fuse.Server
+}
+
+// NewFUSEFrontend creates a FUSE frontend that will be mounted on mountPoint
+func NewFUSEFrontend(client *Client, mountPoint string) *FUSEFrontend {
+	return &FUSEFrontend{
+		tree:       newFileTree(client),
+		mountPoint: mountPoint,
+	}
+}
+
+// MountPoint returns the directory the filesystem is mounted on
+func (ff *FUSEFrontend) MountPoint() string {
+	return ff.mountPoint
+}
+
+// Start mounts the filesystem
+func (ff *FUSEFrontend) Start() error {
+	if err := ff.Mount(); err != nil {
+		return fmt.Errorf("failed to start FUSE frontend: %w", err)
+	}
+
+	ff.tree.client.logf("FUSE filesystem mounted on %s", ff.mountPoint)
+	return nil
+}
+
+// Mount mounts the filesystem, creating the mount point if needed
+func (ff *FUSEFrontend) Mount() error {
+	if err := os.MkdirAll(ff.mountPoint, DirPerm); err != nil {
+		return fmt.Errorf("failed to create mount point: %w", err)
+	}
+
+	// The tree changes as friends come and go, so nothing may be cached
+	noCache := time.Duration(0)
+	server, err := fs.Mount(ff.mountPoint, &fuseNode{tree: ff.tree, node: ff.tree.root()}, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName: "ratox-go",
+			Name:   "ratox",
+			Debug:  ff.tree.client.config.Debug,
+			// Mount without fusermount when running with the privileges to
+			// do so; go-fuse falls back to fusermount otherwise
+			DirectMount: true,
+		},
+		EntryTimeout:    &noCache,
+		AttrTimeout:     &noCache,
+		NegativeTimeout: &noCache,
+		UID:             uint32(os.Getuid()), //nolint:gosec // UIDs are non-negative
+		GID:             uint32(os.Getgid()), //nolint:gosec // GIDs are non-negative
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mount %s: %w", ff.mountPoint, err)
+	}
+
+	ff.server = server
+	return nil
+}
+
+// Serve keeps the filesystem mounted until ctx is cancelled, then ends any
+// blocked reads and unmounts it
+func (ff *FUSEFrontend) Serve(ctx context.Context) {
+	<-ctx.Done()
+	ff.tree.close()
+
+	if ff.server == nil {
+		return
+	}
+	if err := ff.server.Unmount(); err != nil {
+		ff.tree.client.logf("Failed to unmount %s: %v", ff.mountPoint, err)
+		return
+	}
+	ff.server.Wait()
+}
+
+// fuseNode exposes a node of the tree as a FUSE inode
+type fuseNode struct {
+	fs.Inode
+	tree *fileTree
+	node *treeNode
+}
+
+var (
+	_ fs.NodeGetattrer = (*fuseNode)(nil)
+	_ fs.NodeSetattrer = (*fuseNode)(nil)
+	_ fs.NodeLookuper  = (*fuseNode)(nil)
+	_ fs.NodeReaddirer = (*fuseNode)(nil)
+	_ fs.NodeOpener    = (*fuseNode)(nil)
+)
+
+// fuseMode returns the FUSE mode of a tree node
+func fuseMode(n *treeNode) uint32 {
+	if n.isDir() {
+		return fuse.S_IFDIR | n.perm()
+	}
+	return fuse.S_IFREG | n.perm()
+}
+
+// Getattr reports the node's mode and, for snapshots, current size
+func (n *fuseNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = fuseMode(n.node)
+	out.Size = n.node.size()
+	return 0
+}
+
+// Setattr accepts the truncation done by shell redirections on input files
+func (n *fuseNode) Setattr(ctx context.Context, f fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if !n.node.isInput() {
+		return syscall.EACCES
+	}
+	out.Mode = fuseMode(n.node)
+	return 0
+}
+
+// Lookup finds a child by name
+func (n *fuseNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if !n.node.isDir() {
+		return nil, syscall.ENOTDIR
+	}
+	child, exists := n.node.entries()[name]
+	if !exists {
+		return nil, syscall.ENOENT
+	}
+
+	out.Mode = fuseMode(child)
+	out.Size = child.size()
+	return n.NewInode(ctx, &fuseNode{tree: n.tree, node: child}, fs.StableAttr{Mode: out.Mode & syscall.S_IFMT}), 0
+}
+
+// Readdir lists the children in name order
+func (n *fuseNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	if !n.node.isDir() {
+		return nil, syscall.ENOTDIR
+	}
+	entries := n.node.entries()
+
+	list := make([]fuse.DirEntry, 0, len(entries))
+	for _, name := range sortedNames(entries) {
+		list = append(list, fuse.DirEntry{Name: name, Mode: fuseMode(entries[name])})
+	}
+	return fs.NewListDirStream(list), 0
+}
+
+// Open opens input files for writing and streams and snapshots for reading
+func (n *fuseNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	readOnly := flags&syscall.O_ACCMODE == syscall.O_RDONLY
+
+	switch {
+	case n.node.isInput() && !readOnly:
+		return &fuseInputHandle{w: n.tree.openInput(n.node)}, fuse.FOPEN_DIRECT_IO, 0
+	case n.node.isStream() && readOnly:
+		return &fuseStreamHandle{r: n.tree.openStream(n.node)}, fuse.FOPEN_DIRECT_IO | fuse.FOPEN_NONSEEKABLE, 0
+	case n.node.isSnapshot() && readOnly:
+		return &fuseSnapshotHandle{data: []byte(n.node.content())}, fuse.FOPEN_DIRECT_IO, 0
+	}
+	return nil, 0, syscall.EACCES
+}
+
+// fuseInputHandle runs the lines written to an input file
+type fuseInputHandle struct {
+	w *treeInputWriter
+}
+
+var (
+	_ fs.FileWriter  = (*fuseInputHandle)(nil)
+	_ fs.FileFlusher = (*fuseInputHandle)(nil)
+)
+
+// Write runs every complete line. If any of them is rejected the write fails
+// with EINVAL.
+func (h *fuseInputHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	if err := h.w.Write(data); err != nil {
+		return 0, syscall.EINVAL
+	}
+	return uint32(len(data)), 0 //nolint:gosec // FUSE writes are far below 4GB
+}
+
+// Flush runs a final line written without a trailing newline
+func (h *fuseInputHandle) Flush(ctx context.Context) syscall.Errno {
+	if err := h.w.Flush(); err != nil {
+		return syscall.EINVAL
+	}
+	return 0
+}
+
+// fuseStreamHandle is one reader of a stream
+type fuseStreamHandle struct {
+	r *treeStreamReader
+}
+
+var (
+	_ fs.FileReader   = (*fuseStreamHandle)(nil)
+	_ fs.FileReleaser = (*fuseStreamHandle)(nil)
+)
+
+// Read waits for the next line. It returns end of file once the frontend
+// shuts down.
+func (h *fuseStreamHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := h.r.Read(ctx, dest)
+	if err != nil && ctx.Err() != nil {
+		return nil, syscall.EINTR
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+// Release unsubscribes the handle
+func (h *fuseStreamHandle) Release(ctx context.Context) syscall.Errno {
+	h.r.Close()
+	return 0
+}
+
+// fuseSnapshotHandle reads the content captured at open
+type fuseSnapshotHandle struct {
+	data []byte
+}
+
+var _ fs.FileReader = (*fuseSnapshotHandle)(nil)
+
+// Read returns the captured content at off
+func (h *fuseSnapshotHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	if off >= int64(len(h.data)) {
+		return fuse.ReadResultData(nil), 0
+	}
+	n := copy(dest, h.data[off:])
+	return fuse.ReadResultData(dest[:n]), 0
+}
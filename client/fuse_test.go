@@ -0,0 +1,97 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// newFUSETestFrontend mounts a FUSE frontend over a test client with one
+// friend, skipping the test where FUSE is unavailable
+func newFUSETestFrontend(t *testing.T) (*FUSEFrontend, string) {
+	t.Helper()
+
+	c, friendID := newTreeTestClient(t)
+	ff := NewFUSEFrontend(c, filepath.Join(c.config.ConfigDir, "mnt"))
+	if err := ff.Mount(); err != nil {
+		t.Skipf("FUSE is unavailable: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ff.Serve(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return ff, friendID
+}
+
+func TestFUSEFrontend(t *testing.T) {
+	ff, friendID := newFUSETestFrontend(t)
+	root := ff.MountPoint()
+	friendDir := filepath.Join(root, friendID)
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("Failed to list mount point: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got, want := strings.Join(names, " "), friendID+" client conferences"; got != want {
+		t.Errorf("Root entries = %q, want %q", got, want)
+	}
+
+	errorsFile, err := os.Open(filepath.Join(root, "client", "errors"))
+	if err != nil {
+		t.Fatalf("Failed to open client/errors: %v", err)
+	}
+	defer errorsFile.Close()
+
+	// A rejected write fails and is reported in client/errors
+	err = os.WriteFile(filepath.Join(friendDir, AliasIn), []byte("bad alias\n"), FIFOPermInput)
+	if !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Rejected write error = %v, want EINVAL", err)
+	}
+	line, err := bufio.NewReader(errorsFile).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read client/errors: %v", err)
+	}
+	if want := friendID + "/alias_in \"bad alias\" "; !strings.HasPrefix(line, want) {
+		t.Errorf("Error line = %q, want prefix %q", line, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(friendDir, AliasIn), []byte("bobby\n"), FIFOPermInput); err != nil {
+		t.Fatalf("Failed to write alias_in: %v", err)
+	}
+	if alias := ff.tree.client.FriendAlias(friendID); alias != "bobby" {
+		t.Errorf("Alias = %q, want %q", alias, "bobby")
+	}
+
+	textOut, err := os.Open(filepath.Join(friendDir, TextOut))
+	if err != nil {
+		t.Fatalf("Failed to open text_out: %v", err)
+	}
+	defer textOut.Close()
+
+	// Reads block until the next event, so publish once the stream is open
+	ff.tree.client.publishEvent(EventMessage, friendID, map[string]interface{}{"message": "hi"})
+	line, err = bufio.NewReader(textOut).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read text_out: %v", err)
+	}
+	if !strings.HasSuffix(line, "<Bob> hi\n") {
+		t.Errorf("text_out line = %q, want suffix %q", line, "<Bob> hi\n")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"os"
 	"strings"
@@ -19,15 +18,26 @@ import (
 func (c *Client) handleFriendRequest(publicKey [32]byte, message string) {
 	if c.config.Debug {
 		friendIDStr := hex.EncodeToString(publicKey[:])
-		log.Printf("Friend request from %s: %s", friendIDStr, message)
+		c.logf("Friend request from %s: %s", friendIDStr, message)
 	}
 
-	// Write request to request_out FIFO
 	friendIDStr := hex.EncodeToString(publicKey[:])
 
-	if err := c.fifoManager.WriteRequestOut(friendIDStr, message); err != nil {
-		log.Printf("Failed to write friend request to FIFO: %v", err)
+	// Persist the request so it can be accepted or rejected later, even if
+	// nobody is reading request_out right now
+	if err := c.pendingRequests.Add(friendIDStr, message, time.Now()); err != nil {
+		c.logf("Failed to store pending friend request: %v", err)
+	}
+	if err := c.frontends.RequestsChanged(); err != nil {
+		c.logf("Failed to update pending requests file: %v", err)
+	}
+
+	// Write request to request_out FIFO
+	if err := c.frontends.FriendRequest(friendIDStr, message); err != nil {
+		c.logf("Failed to write friend request to FIFO: %v", err)
 	}
+
+	c.publishEvent(EventFriendRequest, friendIDStr, map[string]interface{}{"message": message})
 }
 
 // handleFriendMessage processes incoming messages from friends
@@ -37,33 +47,40 @@ func (c *Client) handleFriendMessage(friendID uint32, message string, messageTyp
 	c.friendsMu.RUnlock()
 
 	if !exists {
-		log.Printf("Received message from unknown friend %d", friendID)
+		c.logf("Received message from unknown friend %d", friendID)
 		return
 	}
 
-	// Update last seen
-	friend.LastSeen = time.Now()
-
 	// Format message with timestamp and type
-	timestamp := time.Now().Format("15:04:05")
-	var formattedMessage string
-
-	switch messageType {
-	case toxcore.MessageTypeAction:
-		formattedMessage = fmt.Sprintf("[%s] * %s %s", timestamp, friend.Name, message)
-	default: // MessageTypeNormal
-		formattedMessage = fmt.Sprintf("[%s] <%s> %s", timestamp, friend.Name, message)
-	}
+	formattedMessage := formatTextOut(time.Now(), friend.Name, message, messageType == toxcore.MessageTypeAction, false)
 
 	// Write to friend's text_out FIFO
 	friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-	if err := c.fifoManager.WriteFriendTextOut(friendIDStr, formattedMessage); err != nil {
-		log.Printf("Failed to write message to text_out FIFO: %v", err)
+	if err := c.frontends.FriendText(friendIDStr, formattedMessage); err != nil {
+		c.logf("Failed to write message to text_out FIFO: %v", err)
 	}
 
+	c.publishEvent(EventMessage, friendIDStr, map[string]interface{}{
+		"message": message,
+		"action":  messageType == toxcore.MessageTypeAction,
+	})
+
 	if c.config.Debug {
-		log.Printf("Message from %s (%d): %s", friend.Name, friendID, message)
+		c.logf("Message from %s (%d): %s", friend.Name, friendID, message)
+	}
+}
+
+// formatTextOut formats a received message as written to text_out
+func formatTextOut(received time.Time, name, message string, action, isAsync bool) string {
+	prefix := "[" + received.Format("15:04:05") + "]"
+	if isAsync {
+		prefix += " [ASYNC]"
+	}
+
+	if action {
+		return fmt.Sprintf("%s * %s %s", prefix, name, message)
 	}
+	return fmt.Sprintf("%s <%s> %s", prefix, name, message)
 }
 
 // handleFriendNameChange processes friend name changes
@@ -86,13 +103,16 @@ func (c *Client) handleFriendNameChange(friendID uint32, name string) {
 	// Create FIFOs outside the lock to prevent deadlock
 	if exists && friend.PublicKey != ([32]byte{}) {
 		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-		if err := c.fifoManager.CreateFriendFIFOs(friendIDStr); err != nil {
-			log.Printf("Warning: failed to create FIFOs for friend %s: %v", friendIDStr, err)
+		if err := c.frontends.FriendAdded(friendIDStr); err != nil {
+			c.logf("Warning: failed to create FIFOs for friend %s: %v", friendIDStr, err)
 		}
+		c.refreshFriendInfo(friendID)
+		c.refreshFriendListings()
+		c.publishEvent(EventName, friendIDStr, map[string]interface{}{"name": name})
 	}
 
 	if c.config.Debug && exists {
-		log.Printf("Friend %d changed name to: %s", friendID, name)
+		c.logf("Friend %d changed name to: %s", friendID, name)
 	}
 }
 
@@ -108,56 +128,59 @@ func (c *Client) handleFriendStatusChange(friendID uint32, status int) {
 	if exists {
 		// Write status to friend's status FIFO
 		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-		statusStr := "offline"
-		switch status {
-		case 0:
-			statusStr = "online"
-		case 1:
-			statusStr = "away"
-		case 2:
-			statusStr = "busy"
-		}
+		statusStr := userStatusString(status)
 
-		if err := c.fifoManager.WriteFriendStatus(friendIDStr, statusStr); err != nil {
-			log.Printf("Failed to write friend status to FIFO: %v", err)
+		if err := c.frontends.FriendStatus(friendIDStr, statusStr); err != nil {
+			c.logf("Failed to write friend status to FIFO: %v", err)
 		}
+		c.refreshFriendInfo(friendID)
+		c.refreshFriendListings()
+		c.publishEvent(EventStatus, friendIDStr, map[string]interface{}{"user_status": statusStr})
 
 		if c.config.Debug {
-			log.Printf("Friend %s (%d) status changed to: %s", friend.Name, friendID, statusStr)
+			c.logf("Friend %s (%d) status changed to: %s", friend.Name, friendID, statusStr)
 		}
 	}
 }
 
 // handleFriendConnectionStatusChange processes friend connection status changes
 func (c *Client) handleFriendConnectionStatusChange(friendID uint32, status toxcore.ConnectionStatus) {
+	now := time.Now()
 	c.friendsMu.Lock()
 	friend, exists := c.friends[friendID]
+	var transitioned bool
 	if exists {
-		friend.Online = status != toxcore.ConnectionNone
+		online := status != toxcore.ConnectionNone
+		// Going online or offline both mean the friend was seen just now
+		transitioned = online != friend.Online
+		if transitioned {
+			friend.LastSeen = now
+		}
+		friend.Online = online
+		friend.Connection = status
 	}
 	c.friendsMu.Unlock()
 
+	if transitioned {
+		if err := c.lastSeen.Set(hex.EncodeToString(friend.PublicKey[:]), now); err != nil {
+			c.logf("Failed to save last seen time: %v", err)
+		}
+	}
+
 	if exists {
 		// Write connection status to friend's status FIFO
 		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-		var statusStr string
-		switch status {
-		case toxcore.ConnectionNone:
-			statusStr = "offline"
-		case toxcore.ConnectionTCP:
-			statusStr = "online (TCP)"
-		case toxcore.ConnectionUDP:
-			statusStr = "online (UDP)"
-		default:
-			statusStr = "unknown"
-		}
+		statusStr := friendConnectionLine(connectionTypeString(status))
 
-		if err := c.fifoManager.WriteFriendStatus(friendIDStr, statusStr); err != nil {
-			log.Printf("Failed to write connection status to FIFO: %v", err)
+		if err := c.frontends.FriendStatus(friendIDStr, statusStr); err != nil {
+			c.logf("Failed to write connection status to FIFO: %v", err)
 		}
+		c.refreshFriendInfo(friendID)
+		c.refreshFriendListings()
+		c.publishEvent(EventConnection, friendIDStr, map[string]interface{}{"connection": connectionTypeString(status)})
 
 		if c.config.Debug {
-			log.Printf("Friend %s (%d) connection status changed to: %s", friend.Name, friendID, statusStr)
+			c.logf("Friend %s (%d) connection status changed to: %s", friend.Name, friendID, statusStr)
 		}
 	}
 }
@@ -174,12 +197,14 @@ func (c *Client) handleFriendStatusMessageChange(friendID uint32, statusMessage
 	if exists {
 		// Write status message to friend's status_message FIFO
 		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-		if err := c.fifoManager.WriteFriendStatusMessage(friendIDStr, statusMessage); err != nil {
-			log.Printf("Failed to write friend status message to FIFO: %v", err)
+		if err := c.frontends.FriendStatusMessage(friendIDStr, statusMessage); err != nil {
+			c.logf("Failed to write friend status message to FIFO: %v", err)
 		}
+		c.refreshFriendListings()
+		c.publishEvent(EventStatusMessage, friendIDStr, map[string]interface{}{"status_message": statusMessage})
 
 		if c.config.Debug {
-			log.Printf("Friend %s (%d) status message changed to: %s", friend.Name, friendID, statusMessage)
+			c.logf("Friend %s (%d) status message changed to: %s", friend.Name, friendID, statusMessage)
 		}
 	}
 }
@@ -195,42 +220,73 @@ func (c *Client) handleFriendTyping(friendID uint32, isTyping bool) {
 	}
 
 	friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-	typingStr := "0"
-	if isTyping {
-		typingStr = "1"
+	if err := c.frontends.FriendTyping(friendIDStr, isTyping); err != nil {
+		c.logf("Failed to write typing status to FIFO: %v", err)
 	}
 
-	if err := c.fifoManager.WriteFriendTyping(friendIDStr, typingStr); err != nil {
-		log.Printf("Failed to write typing status to FIFO: %v", err)
-	}
+	c.publishEvent(EventTyping, friendIDStr, map[string]interface{}{"typing": isTyping})
 
 	if c.config.Debug {
-		log.Printf("Friend %s (%d) typing: %v", friend.Name, friendID, isTyping)
+		c.logf("Friend %s (%d) typing: %v", friend.Name, friendID, isTyping)
 	}
 }
 
 // handleSelfConnectionStatusChange processes self connection status changes
 func (c *Client) handleSelfConnectionStatusChange(status toxcore.ConnectionStatus) {
 	// Update connection status file immediately
-	if err := c.fifoManager.createConnectionStatusFile(); err != nil {
+	if err := c.frontends.ConnectionChanged(); err != nil {
 		if c.config.Debug {
-			log.Printf("Failed to update connection status file: %v", err)
+			c.logf("Failed to update connection status file: %v", err)
 		}
 	}
 
+	c.publishEvent(EventSelfConnection, "", map[string]interface{}{"connection": connectionTypeString(status)})
+
 	if c.config.Debug {
-		var statusStr string
-		switch status {
-		case toxcore.ConnectionNone:
-			statusStr = "offline"
-		case toxcore.ConnectionTCP:
-			statusStr = "tcp"
-		case toxcore.ConnectionUDP:
-			statusStr = "udp"
-		default:
-			statusStr = "unknown"
-		}
-		log.Printf("Self connection status changed to: %s", statusStr)
+		c.logf("Self connection status changed to: %s", connectionTypeString(status))
+	}
+}
+
+// userStatusString returns the name of a Tox user status
+func userStatusString(status int) string {
+	switch status {
+	case 0:
+		return "online"
+	case 1:
+		return "away"
+	case 2:
+		return "busy"
+	default:
+		return "offline"
+	}
+}
+
+// connectionTypeString returns the name of a Tox connection status
+func connectionTypeString(status toxcore.ConnectionStatus) string {
+	switch status {
+	case toxcore.ConnectionNone:
+		return "offline"
+	case toxcore.ConnectionTCP:
+		return "tcp"
+	case toxcore.ConnectionUDP:
+		return "udp"
+	default:
+		return "unknown"
+	}
+}
+
+// friendConnectionLine describes a connection type, as returned by
+// connectionTypeString, the way it is written to a friend's status FIFO
+func friendConnectionLine(connection string) string {
+	switch connection {
+	case "offline":
+		return "offline"
+	case "tcp":
+		return "online (TCP)"
+	case "udp":
+		return "online (UDP)"
+	default:
+		return "unknown"
 	}
 }
 
@@ -241,38 +297,61 @@ func (c *Client) handleFileReceive(friendID, fileNumber uint32, kind int, fileSi
 	c.friendsMu.RUnlock()
 
 	if !exists {
-		log.Printf("File receive from unknown friend %d", friendID)
+		c.logf("File receive from unknown friend %d", friendID)
 		return
 	}
 
 	if c.config.Debug {
-		log.Printf("File receive from %s: %s (%d bytes)", friend.Name, filename, fileSize)
+		c.logf("File receive from %s: %s (%d bytes)", friend.Name, filename, fileSize)
 	}
 
+	settings := c.liveConfig()
+
 	// Check file size limits
-	if c.config.MaxFileSize > 0 && fileSize > uint64(c.config.MaxFileSize) { //nolint:gosec // MaxFileSize>0 ensures safe uint64 conversion
+	if settings.MaxFileSize > 0 && fileSize > uint64(settings.MaxFileSize) { //nolint:gosec // MaxFileSize>0 ensures safe uint64 conversion
 		c.rejectFileTransfer(friendID, fileNumber, fileSize)
 		return
 	}
 
 	// Write file receive notification to file_out FIFO
 	friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-	fileInfo := fmt.Sprintf("%s %d", filename, fileSize)
+	fileInfo := fileOfferLine(filename, fileSize)
 
-	if err := c.fifoManager.WriteFriendFileOut(friendIDStr, fileInfo); err != nil {
-		log.Printf("Failed to write file receive notification: %v", err)
+	if err := c.frontends.FriendFile(friendIDStr, fileInfo); err != nil {
+		c.logf("Failed to write file receive notification: %v", err)
 	}
 
+	c.publishEvent(EventFileOffer, friendIDStr, map[string]interface{}{
+		"file_number": fileNumber,
+		"filename":    filename,
+		"size":        fileSize,
+	})
+
 	// Auto-accept files if configured
-	if c.config.AutoAcceptFiles {
+	if settings.AutoAcceptFiles {
 		c.acceptFileTransfer(friendID, fileNumber, friendIDStr, filename, fileSize)
 	}
 }
 
+// fileOfferLine formats an incoming file offer for file_out
+func fileOfferLine(filename string, size uint64) string {
+	return fmt.Sprintf("%s %d", filename, size)
+}
+
+// fileDoneLine formats a finished transfer for file_out
+func fileDoneLine(prefix, filename string, size uint64) string {
+	return fmt.Sprintf("%s %s %d", prefix, filename, size)
+}
+
+// fileAbortLine formats an aborted outgoing transfer for file_out
+func fileAbortLine(filename string, sent, size uint64) string {
+	return fmt.Sprintf("ABORTED %s %d %d", filename, sent, size)
+}
+
 func (c *Client) rejectFileTransfer(friendID, fileNumber uint32, fileSize uint64) {
-	log.Printf("File too large (%d bytes), rejecting", fileSize)
+	c.logf("File too large (%d bytes), rejecting", fileSize)
 	if err := c.tox.FileControl(friendID, fileNumber, toxcore.FileControlCancel); err != nil {
-		log.Printf("Failed to reject file transfer: %v", err)
+		c.logf("Failed to reject file transfer: %v", err)
 	}
 }
 
@@ -280,9 +359,16 @@ func (c *Client) acceptFileTransfer(friendID, fileNumber uint32, friendIDStr, fi
 	friendDir := c.config.FriendDir(friendIDStr)
 	destPath := fmt.Sprintf("%s/%s", friendDir, filename)
 
+	// Only the FIFO frontend creates friend directories up front
+	if err := os.MkdirAll(friendDir, DirPerm); err != nil {
+		c.logf("Failed to create friend directory: %v", err)
+		c.cancelFileTransfer(friendID, fileNumber)
+		return
+	}
+
 	file, err := os.Create(destPath)
 	if err != nil {
-		log.Printf("Failed to create destination file: %v", err)
+		c.logf("Failed to create destination file: %v", err)
 		c.cancelFileTransfer(friendID, fileNumber)
 		return
 	}
@@ -300,19 +386,19 @@ func (c *Client) acceptFileTransfer(friendID, fileNumber uint32, friendIDStr, fi
 	c.transfersMu.Unlock()
 
 	if err := c.tox.FileControl(friendID, fileNumber, toxcore.FileControlResume); err != nil {
-		log.Printf("Failed to accept file transfer: %v", err)
+		c.logf("Failed to accept file transfer: %v", err)
 		file.Close()
 		c.transfersMu.Lock()
 		delete(c.incomingTransfers, transferKey)
 		c.transfersMu.Unlock()
 	} else {
-		log.Printf("Auto-accepted file transfer: %s", filename)
+		c.logf("Auto-accepted file transfer: %s", filename)
 	}
 }
 
 func (c *Client) cancelFileTransfer(friendID, fileNumber uint32) {
 	if err := c.tox.FileControl(friendID, fileNumber, toxcore.FileControlCancel); err != nil {
-		log.Printf("Failed to cancel file transfer: %v", err)
+		c.logf("Failed to cancel file transfer: %v", err)
 	}
 }
 
@@ -326,7 +412,7 @@ func (c *Client) handleFileReceiveChunk(friendID, fileNumber uint32, position ui
 
 	if !exists {
 		if c.config.Debug {
-			log.Printf("Received chunk for unknown transfer: %s", transferKey)
+			c.logf("Received chunk for unknown transfer: %s", transferKey)
 		}
 		return
 	}
@@ -341,8 +427,10 @@ func (c *Client) handleFileReceiveChunk(friendID, fileNumber uint32, position ui
 		return
 	}
 
+	c.publishFileEvent(EventFileProgress, friendID, fileNumber, TransferIncoming, transfer.Filename, transfer.Received, transfer.FileSize)
+
 	if c.config.Debug {
-		log.Printf("Received file chunk: %d bytes at position %d (%d/%d total)",
+		c.logf("Received file chunk: %d bytes at position %d (%d/%d total)",
 			len(data), position, transfer.Received, transfer.FileSize)
 	}
 }
@@ -356,9 +444,9 @@ func (c *Client) notifyFileTransferComplete(friendID uint32, filename string, si
 
 	if exists {
 		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-		completionMsg := fmt.Sprintf("%s %s %d", msgPrefix, filename, size)
-		if err := c.fifoManager.WriteFriendFileOut(friendIDStr, completionMsg); err != nil {
-			log.Printf("Failed to write file transfer notification: %v", err)
+		completionMsg := fileDoneLine(msgPrefix, filename, size)
+		if err := c.frontends.FriendFile(friendIDStr, completionMsg); err != nil {
+			c.logf("Failed to write file transfer notification: %v", err)
 		}
 	}
 }
@@ -370,7 +458,8 @@ func (c *Client) completeFileReceive(friendID uint32, transferKey string, transf
 	delete(c.incomingTransfers, transferKey)
 	c.transfersMu.Unlock()
 
-	log.Printf("File transfer completed: %s (%d bytes)", transfer.Filename, transfer.Received)
+	c.logf("File transfer completed: %s (%d bytes)", transfer.Filename, transfer.Received)
+	c.publishFileEvent(EventFileComplete, friendID, transferFileNumber(transferKey), TransferIncoming, transfer.Filename, transfer.Received, transfer.FileSize)
 	c.notifyFileTransferComplete(friendID, transfer.Filename, transfer.Received, "COMPLETE")
 }
 
@@ -380,9 +469,9 @@ func (c *Client) writeFileChunk(transfer *incomingTransfer, position uint64, dat
 	}
 	if _, err := transfer.File.WriteAt(data, int64(position)); err != nil {
 		if isOutOfDiskSpaceError(err) {
-			log.Printf("Disk full: failed to write file chunk: %v", err)
+			c.logf("Disk full: failed to write file chunk: %v", err)
 		} else {
-			log.Printf("Failed to write file chunk: %v", err)
+			c.logf("Failed to write file chunk: %v", err)
 		}
 		return err
 	}
@@ -409,35 +498,40 @@ func (c *Client) abortFileReceive(friendID, fileNumber uint32, transferKey strin
 	delete(c.incomingTransfers, transferKey)
 	c.transfersMu.Unlock()
 	c.cancelFileTransfer(friendID, fileNumber)
+	c.publishFileEvent(EventFileAborted, friendID, fileNumber, TransferIncoming, transfer.Filename, transfer.Received, transfer.FileSize)
 
 	// Clean up partial file
 	if filePath != "" {
 		if err := os.Remove(filePath); err != nil {
-			log.Printf("Warning: failed to remove partial file %s: %v", filePath, err)
+			c.logf("Warning: failed to remove partial file %s: %v", filePath, err)
 		} else {
-			log.Printf("Removed partial file: %s", filePath)
+			c.logf("Removed partial file: %s", filePath)
 		}
 	}
 }
 
 func (c *Client) completeFileSend(friendID uint32, transferKey string, transfer *outgoingTransfer) {
 	transfer.File.Close()
+	c.removeUpload(transfer.FilePath)
 
 	c.transfersMu.Lock()
 	delete(c.outgoingTransfers, transferKey)
 	c.transfersMu.Unlock()
 
-	log.Printf("File send completed: %s (%d bytes)", transfer.Filename, transfer.Sent)
+	c.logf("File send completed: %s (%d bytes)", transfer.Filename, transfer.Sent)
+	c.publishFileEvent(EventFileComplete, friendID, transferFileNumber(transferKey), TransferOutgoing, transfer.Filename, transfer.Sent, transfer.FileSize)
 	c.notifyFileTransferComplete(friendID, transfer.Filename, transfer.Sent, "SENT")
 }
 
 func (c *Client) abortFileSend(friendID, fileNumber uint32, transferKey string, transfer *outgoingTransfer) {
 	transfer.File.Close()
+	c.removeUpload(transfer.FilePath)
 	c.transfersMu.Lock()
 	delete(c.outgoingTransfers, transferKey)
 	c.transfersMu.Unlock()
 
-	log.Printf("File send aborted: %s (sent %d/%d bytes)", transfer.Filename, transfer.Sent, transfer.FileSize)
+	c.logf("File send aborted: %s (sent %d/%d bytes)", transfer.Filename, transfer.Sent, transfer.FileSize)
+	c.publishFileEvent(EventFileAborted, friendID, fileNumber, TransferOutgoing, transfer.Filename, transfer.Sent, transfer.FileSize)
 
 	c.friendsMu.RLock()
 	friend, exists := c.friends[friendID]
@@ -445,9 +539,9 @@ func (c *Client) abortFileSend(friendID, fileNumber uint32, transferKey string,
 
 	if exists {
 		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-		abortMsg := fmt.Sprintf("ABORTED %s %d %d", transfer.Filename, transfer.Sent, transfer.FileSize)
-		if err := c.fifoManager.WriteFriendFileOut(friendIDStr, abortMsg); err != nil {
-			log.Printf("Failed to write file send abort notification: %v", err)
+		abortMsg := fileAbortLine(transfer.Filename, transfer.Sent, transfer.FileSize)
+		if err := c.frontends.FriendFile(friendIDStr, abortMsg); err != nil {
+			c.logf("Failed to write file send abort notification: %v", err)
 		}
 	}
 }
@@ -478,13 +572,13 @@ func (c *Client) handleFileChunkRequest(friendID, fileNumber uint32, position ui
 	c.transfersMu.RUnlock()
 
 	if !exists {
-		log.Printf("No outgoing transfer found for key %s", transferKey)
+		c.logf("No outgoing transfer found for key %s", transferKey)
 		return
 	}
 
 	if length == 0 {
 		if c.config.Debug {
-			log.Printf("File transfer paused for key %s", transferKey)
+			c.logf("File transfer paused for key %s", transferKey)
 		}
 		return
 	}
@@ -492,9 +586,9 @@ func (c *Client) handleFileChunkRequest(friendID, fileNumber uint32, position ui
 	chunk, err := c.readFileChunk(transfer, position, length)
 	if err != nil && err != io.EOF {
 		if os.IsNotExist(err) {
-			log.Printf("File no longer exists: %s (%v)", transfer.FilePath, err)
+			c.logf("File no longer exists: %s (%v)", transfer.FilePath, err)
 		} else {
-			log.Printf("Failed to read file chunk: %v", err)
+			c.logf("Failed to read file chunk: %v", err)
 		}
 		c.cancelFileTransfer(friendID, fileNumber)
 		c.abortFileSend(friendID, fileNumber, transferKey, transfer)
@@ -509,7 +603,7 @@ func (c *Client) handleFileChunkRequest(friendID, fileNumber uint32, position ui
 	}
 
 	if err := c.tox.FileSendChunk(friendID, fileNumber, position, dataToSend); err != nil {
-		log.Printf("Failed to send file chunk: %v", err)
+		c.logf("Failed to send file chunk: %v", err)
 		c.cancelFileTransfer(friendID, fileNumber)
 		c.abortFileSend(friendID, fileNumber, transferKey, transfer)
 		return
@@ -517,8 +611,12 @@ func (c *Client) handleFileChunkRequest(friendID, fileNumber uint32, position ui
 
 	if dataToSend == nil {
 		c.completeFileSend(friendID, transferKey, transfer)
-	} else if c.config.Debug {
-		log.Printf("Sent file chunk: %d bytes at position %d (%d/%d total)",
+		return
+	}
+
+	c.publishFileEvent(EventFileProgress, friendID, fileNumber, TransferOutgoing, transfer.Filename, transfer.Sent, transfer.FileSize)
+	if c.config.Debug {
+		c.logf("Sent file chunk: %d bytes at position %d (%d/%d total)",
 			len(chunk), position, transfer.Sent, transfer.FileSize)
 	}
 }
@@ -527,7 +625,7 @@ func (c *Client) handleFileChunkRequest(friendID, fileNumber uint32, position ui
 func (c *Client) handleAsyncMessage(senderPK [32]byte, message string, messageType async.MessageType) {
 	friendID, err := c.tox.GetFriendByPublicKey(senderPK)
 	if err != nil {
-		log.Printf("Received async message from unknown sender: %v", err)
+		c.logf("Received async message from unknown sender: %v", err)
 		return
 	}
 
@@ -536,26 +634,24 @@ func (c *Client) handleAsyncMessage(senderPK [32]byte, message string, messageTy
 	c.friendsMu.RUnlock()
 
 	if !exists {
-		log.Printf("Received async message from friend %d not in map", friendID)
+		c.logf("Received async message from friend %d not in map", friendID)
 		return
 	}
 
-	timestamp := time.Now().Format("15:04:05")
-	var formattedMessage string
-
-	switch messageType {
-	case async.MessageTypeAction:
-		formattedMessage = fmt.Sprintf("[%s] [ASYNC] * %s %s", timestamp, friend.Name, message)
-	default:
-		formattedMessage = fmt.Sprintf("[%s] [ASYNC] <%s> %s", timestamp, friend.Name, message)
-	}
+	formattedMessage := formatTextOut(time.Now(), friend.Name, message, messageType == async.MessageTypeAction, true)
 
 	friendIDStr := hex.EncodeToString(friend.PublicKey[:])
-	if err := c.fifoManager.WriteFriendTextOut(friendIDStr, formattedMessage); err != nil {
-		log.Printf("Failed to write async message to text_out FIFO: %v", err)
+	if err := c.frontends.FriendText(friendIDStr, formattedMessage); err != nil {
+		c.logf("Failed to write async message to text_out FIFO: %v", err)
 	}
 
+	c.publishEvent(EventMessage, friendIDStr, map[string]interface{}{
+		"message": message,
+		"action":  messageType == async.MessageTypeAction,
+		"async":   true,
+	})
+
 	if c.config.Debug {
-		log.Printf("Async message from %s (%d): %s", friend.Name, friendID, message)
+		c.logf("Async message from %s (%d): %s", friend.Name, friendID, message)
 	}
 }
@@ -22,7 +22,6 @@ func TestAbortFileSend(t *testing.T) {
 	c := &Client{
 		friends:           make(map[uint32]*Friend),
 		outgoingTransfers: make(map[string]*outgoingTransfer),
-		fifoManager:       nil, // We won't call methods that need FIFO
 	}
 
 	// Add a friend
@@ -160,7 +159,6 @@ func TestCompleteFileReceive(t *testing.T) {
 	c := &Client{
 		friends:           make(map[uint32]*Friend),
 		incomingTransfers: make(map[string]*incomingTransfer),
-		fifoManager:       nil, // Skip FIFO writes in test
 	}
 
 	friendID := uint32(1)
@@ -211,7 +209,6 @@ func TestCompleteFileSend(t *testing.T) {
 	c := &Client{
 		friends:           make(map[uint32]*Friend),
 		outgoingTransfers: make(map[string]*outgoingTransfer),
-		fifoManager:       nil, // Skip FIFO writes in test
 	}
 
 	friendID := uint32(1)
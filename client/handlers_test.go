@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/opd-ai/go-ratox/config"
+	"github.com/opd-ai/toxcore"
 )
 
 // TestFriendStruct tests the Friend struct and its properties
@@ -251,6 +252,28 @@ func TestStatusMapping(t *testing.T) {
 	}
 }
 
+// TestStatusStrings tests the user status and connection type names written
+// to friend metadata files
+func TestStatusStrings(t *testing.T) {
+	userStatuses := map[int]string{0: "online", 1: "away", 2: "busy", 7: "offline"}
+	for status, expected := range userStatuses {
+		if got := userStatusString(status); got != expected {
+			t.Errorf("userStatusString(%d): expected '%s', got '%s'", status, expected, got)
+		}
+	}
+
+	connections := map[toxcore.ConnectionStatus]string{
+		toxcore.ConnectionNone: "offline",
+		toxcore.ConnectionTCP:  "tcp",
+		toxcore.ConnectionUDP:  "udp",
+	}
+	for status, expected := range connections {
+		if got := connectionTypeString(status); got != expected {
+			t.Errorf("connectionTypeString(%d): expected '%s', got '%s'", status, expected, got)
+		}
+	}
+}
+
 // TestFriendMapOperations tests operations on the friends map
 func TestFriendMapOperations(t *testing.T) {
 	friends := make(map[uint32]*Friend)
@@ -0,0 +1,500 @@
+// Package client implements the optional HTTP/REST and WebSocket gateway for ratox-go
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// tokenBytes is the number of random bytes in a generated access token
+const tokenBytes = 32
+
+// httpShutdownTimeout bounds how long in-flight requests may delay shutdown
+const httpShutdownTimeout = 5 * time.Second
+
+// HTTPServer exposes the control API as REST endpoints and streams events
+// over a WebSocket. It only listens on loopback addresses and every request
+// must carry the profile's access token.
+type HTTPServer struct {
+	client   *Client
+	control  *ControlServer
+	token    string
+	listener net.Listener
+	server   *http.Server
+	ctx      context.Context
+}
+
+// receivedFile describes a file received from a friend
+type receivedFile struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// NewHTTPServer creates a gateway for client using the access token at the
+// configured path, generating one if it does not exist yet
+func NewHTTPServer(client *Client) (*HTTPServer, error) {
+	token, err := loadOrCreateToken(client.config.HTTPTokenPath())
+	if err != nil {
+		return nil, err
+	}
+
+	hs := &HTTPServer{
+		client:  client,
+		control: NewControlServer(client, client.config.ControlSocketPath()),
+		token:   token,
+		ctx:     context.Background(),
+	}
+	hs.server = &http.Server{
+		Handler:           hs.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return hs, nil
+}
+
+// loadOrCreateToken reads an access token, creating a random one with
+// owner-only permissions if the file is missing or empty
+func loadOrCreateToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read access token: %w", err)
+	}
+
+	raw := make([]byte, tokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write access token: %w", err)
+	}
+	return token, nil
+}
+
+// validateLoopbackAddress checks that addr only accepts local connections
+func validateLoopbackAddress(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("address %q is not a loopback address", addr)
+}
+
+// Listen binds the configured loopback address
+func (hs *HTTPServer) Listen() error {
+	addr := hs.client.config.HTTP.Address
+	if err := validateLoopbackAddress(addr); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	hs.listener = listener
+	return nil
+}
+
+// Addr returns the address the gateway is listening on
+func (hs *HTTPServer) Addr() string {
+	return hs.listener.Addr().String()
+}
+
+// Serve handles requests until ctx is cancelled
+func (hs *HTTPServer) Serve(ctx context.Context) {
+	hs.ctx = ctx
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		if err := hs.server.Shutdown(shutdownCtx); err != nil {
+			hs.client.logf("HTTP gateway shutdown error: %v", err)
+		}
+	}()
+
+	if err := hs.server.Serve(hs.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		hs.client.logf("HTTP gateway error: %v", err)
+	}
+}
+
+// routes builds the request multiplexer
+func (hs *HTTPServer) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/status", hs.rpcHandler("status"))
+	mux.HandleFunc("PUT /api/name", hs.rpcHandler("set_name"))
+	mux.HandleFunc("PUT /api/status_message", hs.rpcHandler("set_status_message"))
+
+	mux.HandleFunc("GET /api/friends", hs.rpcHandler("list_friends"))
+	mux.HandleFunc("POST /api/friends", hs.rpcHandler("add_friend"))
+	mux.HandleFunc("GET /api/friends/{friend}", hs.rpcHandler("get_friend", "friend"))
+	mux.HandleFunc("POST /api/friends/{friend}/messages", hs.rpcHandler("send_message", "friend"))
+	mux.HandleFunc("GET /api/friends/{friend}/files", hs.handleListFiles)
+	mux.HandleFunc("POST /api/friends/{friend}/files", hs.handleUploadFile)
+	mux.HandleFunc("GET /api/friends/{friend}/files/{name}", hs.handleDownloadFile)
+
+	mux.HandleFunc("GET /api/requests", hs.rpcHandler("list_requests"))
+	mux.HandleFunc("POST /api/requests/{public_key}/accept", hs.rpcHandler("accept_request", "public_key"))
+	mux.HandleFunc("POST /api/requests/{public_key}/reject", hs.rpcHandler("reject_request", "public_key"))
+
+	mux.HandleFunc("GET /api/transfers", hs.rpcHandler("list_transfers"))
+	mux.HandleFunc("DELETE /api/transfers/{friend}/{file_number}", hs.rpcHandler("cancel_transfer", "friend", "#file_number"))
+
+	mux.HandleFunc("POST /api/conferences", hs.rpcHandler("create_conference"))
+	mux.HandleFunc("POST /api/conferences/{conference_id}/invites", hs.rpcHandler("invite_to_conference", "#conference_id"))
+	mux.HandleFunc("POST /api/conferences/{conference_id}/messages", hs.rpcHandler("send_conference_message", "#conference_id"))
+
+	mux.HandleFunc("GET /api/events", hs.handleEvents)
+
+	return hs.authenticate(mux)
+}
+
+// authenticate rejects requests without the access token, given either as a
+// bearer token or, for browser WebSockets, a token query parameter
+func (hs *HTTPServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(hs.token)) != 1 {
+			writeHTTPError(w, http.StatusUnauthorized, &RPCError{Code: RPCInvalidRequest, Message: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rpcHandler serves a control method. The JSON request body supplies the
+// params, and the named path values are added to them; a leading "#" marks a
+// numeric path value.
+func (hs *HTTPServer) rpcHandler(method string, pathParams ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := make(map[string]interface{})
+
+		body := http.MaxBytesReader(w, r.Body, maxRPCRequestSize)
+		decoder := json.NewDecoder(body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+			writeHTTPError(w, http.StatusBadRequest, &RPCError{Code: RPCParseError, Message: "parse error", Data: err.Error()})
+			return
+		}
+
+		for _, name := range pathParams {
+			numeric := strings.HasPrefix(name, "#")
+			name = strings.TrimPrefix(name, "#")
+			value := r.PathValue(name)
+
+			if !numeric {
+				params[name] = value
+				continue
+			}
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				writeHTTPError(w, http.StatusBadRequest, invalidParams("%s must be a number", name))
+				return
+			}
+			params[name] = n
+		}
+
+		raw, err := json.Marshal(params)
+		if err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, toRPCError(err))
+			return
+		}
+
+		result, err := hs.control.call(method, raw)
+		if err != nil {
+			rpcErr := toRPCError(err)
+			writeHTTPError(w, httpStatusForRPCError(rpcErr), rpcErr)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// httpStatusForRPCError maps a control error code to an HTTP status
+func httpStatusForRPCError(err *RPCError) int {
+	switch err.Code {
+	case RPCParseError, RPCInvalidRequest, RPCInvalidParams:
+		return http.StatusBadRequest
+	case RPCMethodNotFound, RPCFriendNotFound, RPCTransferNotFound, RPCRequestNotPending:
+		return http.StatusNotFound
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write HTTP response: %v", err)
+	}
+}
+
+// writeHTTPError writes a structured error response
+func writeHTTPError(w http.ResponseWriter, status int, err *RPCError) {
+	writeJSON(w, status, map[string]*RPCError{"error": err})
+}
+
+// reservedFriendFiles are the FIFOs and metadata files in a friend directory,
+// which are never offered as received files
+var reservedFriendFiles = map[string]bool{
+	TextIn: true, TextOut: true, FileIn: true, FileOut: true, Status: true,
+	FriendStatusMessage: true, RemoveIn: true, Typing: true, AliasIn: true,
+	FriendName: true, FriendPublicKey: true, FriendLastSeen: true,
+	FriendConnection: true, FriendUserStatus: true,
+}
+
+// validFileName checks that name is a plain file name
+func validFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && filepath.Base(name) == name && !strings.ContainsRune(name, 0)
+}
+
+// friendFromPath resolves the {friend} path value, writing an error if it
+// does not name a friend
+func (hs *HTTPServer) friendFromPath(w http.ResponseWriter, r *http.Request) (uint32, string, bool) {
+	friendNum, friendIDStr, err := hs.control.resolveFriend(r.PathValue("friend"))
+	if err != nil {
+		rpcErr := toRPCError(err)
+		writeHTTPError(w, httpStatusForRPCError(rpcErr), rpcErr)
+		return 0, "", false
+	}
+	return friendNum, friendIDStr, true
+}
+
+// handleListFiles lists files received from a friend
+func (hs *HTTPServer) handleListFiles(w http.ResponseWriter, r *http.Request) {
+	_, friendIDStr, ok := hs.friendFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := os.ReadDir(hs.client.config.FriendDir(friendIDStr))
+	if err != nil && !os.IsNotExist(err) {
+		writeHTTPError(w, http.StatusInternalServerError, toRPCError(err))
+		return
+	}
+
+	files := make([]receivedFile, 0, len(entries))
+	for _, entry := range entries {
+		if reservedFriendFiles[entry.Name()] || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, receivedFile{Name: entry.Name(), Size: info.Size(), Modified: info.ModTime().UTC()})
+	}
+
+	writeJSON(w, http.StatusOK, files)
+}
+
+// handleDownloadFile serves a file received from a friend
+func (hs *HTTPServer) handleDownloadFile(w http.ResponseWriter, r *http.Request) {
+	_, friendIDStr, ok := hs.friendFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	if !validFileName(name) || reservedFriendFiles[name] {
+		writeHTTPError(w, http.StatusBadRequest, invalidParams("invalid file name %q", name))
+		return
+	}
+
+	path := hs.client.config.FriendFIFOPath(friendIDStr, name)
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		writeHTTPError(w, http.StatusNotFound, &RPCError{Code: RPCOperationFailed, Message: "file not found", Data: name})
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, toRPCError(err))
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
+
+// handleUploadFile stores the request body under the upload directory and
+// sends it to a friend under the name given by the name query parameter.
+// The upload is deleted once the transfer ends.
+func (hs *HTTPServer) handleUploadFile(w http.ResponseWriter, r *http.Request) {
+	friendNum, _, ok := hs.friendFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if !validFileName(name) {
+		writeHTTPError(w, http.StatusBadRequest, invalidParams("name query parameter must be a plain file name"))
+		return
+	}
+
+	uploadDir := hs.client.config.UploadDir()
+	if err := os.MkdirAll(uploadDir, DirPerm); err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, toRPCError(err))
+		return
+	}
+	dir, err := os.MkdirTemp(uploadDir, "upload-")
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, toRPCError(err))
+		return
+	}
+
+	filePath := filepath.Join(dir, name)
+	if err := hs.saveUpload(w, r, filePath); err != nil {
+		os.RemoveAll(dir)
+		writeHTTPError(w, http.StatusBadRequest, invalidParams("%v", err))
+		return
+	}
+
+	fileNumber, err := hs.client.SendFile(friendNum, filePath)
+	if err != nil {
+		os.RemoveAll(dir)
+		rpcErr := toRPCError(err)
+		writeHTTPError(w, httpStatusForRPCError(rpcErr), rpcErr)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"friend_number": friendNum, "file_number": fileNumber})
+}
+
+// saveUpload copies the request body to filePath, enforcing the maximum file size
+func (hs *HTTPServer) saveUpload(w http.ResponseWriter, r *http.Request, filePath string) error {
+	body := r.Body
+	if maxSize := hs.client.liveConfig().MaxFileSize; maxSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, maxSize)
+	}
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read upload: %w", err)
+	}
+	return file.Close()
+}
+
+// removeUpload deletes a file uploaded through the HTTP gateway once its
+// transfer has ended. Files outside the upload directory are left alone.
+func (c *Client) removeUpload(filePath string) {
+	rel, err := filepath.Rel(c.config.UploadDir(), filePath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") || filepath.Dir(rel) == "." {
+		return
+	}
+
+	if err := os.RemoveAll(filepath.Dir(filePath)); err != nil {
+		c.logf("Failed to remove upload %s: %v", filePath, err)
+	}
+}
+
+// handleEvents upgrades to a WebSocket carrying one JSON event per message.
+// The friends and events query parameters take comma-separated filters.
+func (hs *HTTPServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var friendKeys []string
+	for _, nameOrKey := range splitQueryList(r.URL.Query().Get("friends")) {
+		key, err := hs.control.friendFilterKey(nameOrKey)
+		if err != nil {
+			rpcErr := toRPCError(err)
+			writeHTTPError(w, httpStatusForRPCError(rpcErr), rpcErr)
+			return
+		}
+		friendKeys = append(friendKeys, key)
+	}
+
+	filter, err := newEventFilter(friendKeys, splitQueryList(r.URL.Query().Get("events")))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, invalidParams("%v", err))
+		return
+	}
+
+	websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			hs.streamEvents(ws, filter)
+		},
+	}.ServeHTTP(w, r)
+}
+
+// splitQueryList splits a comma-separated query value, ignoring empty items
+func splitQueryList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// streamEvents sends matching events until the peer closes the WebSocket or
+// the gateway shuts down
+func (hs *HTTPServer) streamEvents(ws *websocket.Conn, filter EventFilter) {
+	defer ws.Close()
+
+	sub := hs.client.events.Subscribe(filter)
+	defer hs.client.events.Unsubscribe(sub)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discard string
+		for websocket.Message.Receive(ws, &discard) == nil {
+			// Clients have nothing to send; reading detects disconnects
+		}
+	}()
+
+	for {
+		select {
+		case <-hs.ctx.Done():
+			return
+		case <-closed:
+			return
+		case event := <-sub.C:
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// newHTTPTestServer builds a gateway over the control test client
+func newHTTPTestServer(t *testing.T) (*HTTPServer, *httptest.Server) {
+	t.Helper()
+
+	cs := newControlTestServer(t)
+	hs := &HTTPServer{
+		client:  cs.client,
+		control: cs,
+		token:   "secret",
+		ctx:     context.Background(),
+	}
+
+	ts := httptest.NewServer(hs.routes())
+	t.Cleanup(ts.Close)
+	return hs, ts
+}
+
+// TestHTTPAuthentication tests that every request needs the access token
+func TestHTTPAuthentication(t *testing.T) {
+	_, ts := newHTTPTestServer(t)
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		want   int
+	}{
+		{"no token", "/api/requests", "", http.StatusUnauthorized},
+		{"wrong token", "/api/requests", "Bearer nope", http.StatusUnauthorized},
+		{"bearer token", "/api/requests", "Bearer secret", http.StatusOK},
+		{"query token", "/api/requests?token=secret", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.url, nil)
+			if err != nil {
+				t.Fatalf("NewRequest failed: %v", err)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
+
+// TestHTTPErrors tests that control errors map to HTTP statuses with a
+// structured body
+func TestHTTPErrors(t *testing.T) {
+	_, ts := newHTTPTestServer(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		body     string
+		want     int
+		wantCode int
+	}{
+		{"malformed body", http.MethodPost, "/api/friends", "{", http.StatusBadRequest, RPCParseError},
+		{"missing tox id", http.MethodPost, "/api/friends", "{}", http.StatusBadRequest, RPCInvalidParams},
+		{"request not pending", http.MethodPost, "/api/requests/" + strings.Repeat("ab", 32) + "/accept", "", http.StatusNotFound, RPCRequestNotPending},
+		{"bad file number", http.MethodDelete, "/api/transfers/alice/x", "", http.StatusBadRequest, RPCInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("NewRequest failed: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer secret")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var body struct {
+				Error *RPCError `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if resp.StatusCode != tt.want || body.Error == nil || body.Error.Code != tt.wantCode {
+				t.Errorf("Expected %d with code %d, got %d with %+v", tt.want, tt.wantCode, resp.StatusCode, body.Error)
+			}
+		})
+	}
+}
+
+// TestHTTPEvents tests the WebSocket event stream
+func TestHTTPEvents(t *testing.T) {
+	hs, ts := newHTTPTestServer(t)
+	keyA := strings.Repeat("aa", 32)
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/events?token=secret&events=typing"
+	ws, err := websocket.Dial(url, "", ts.URL)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer ws.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hs.client.events.mu.RLock()
+		n := len(hs.client.events.subs)
+		hs.client.events.mu.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Subscription was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hs.client.publishEvent(EventMessage, keyA, map[string]interface{}{"message": "filtered out"})
+	hs.client.publishEvent(EventTyping, keyA, map[string]interface{}{"typing": true})
+
+	var event Event
+	if err := websocket.JSON.Receive(ws, &event); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if event.Type != EventTyping || event.Friend != keyA {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+}
+
+// TestValidateLoopbackAddress tests that only loopback addresses are accepted
+func TestValidateLoopbackAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		isValid bool
+	}{
+		{"127.0.0.1:8777", true},
+		{"[::1]:8777", true},
+		{"localhost:8777", true},
+		{"0.0.0.0:8777", false},
+		{":8777", false},
+		{"192.168.1.2:8777", false},
+		{"127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := validateLoopbackAddress(tt.addr)
+			if (err == nil) != tt.isValid {
+				t.Errorf("Expected validity %v for %q, got error %v", tt.isValid, tt.addr, err)
+			}
+		})
+	}
+}
+
+// TestLoadOrCreateToken tests token generation and reuse
+func TestLoadOrCreateToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http_token")
+
+	token, err := loadOrCreateToken(path)
+	if err != nil {
+		t.Fatalf("loadOrCreateToken failed: %v", err)
+	}
+	if len(token) != tokenBytes*2 {
+		t.Errorf("Expected %d hex characters, got %d", tokenBytes*2, len(token))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("Expected token permissions 600, got %o", perm)
+	}
+
+	again, err := loadOrCreateToken(path)
+	if err != nil {
+		t.Fatalf("loadOrCreateToken failed: %v", err)
+	}
+	if again != token {
+		t.Error("Expected the existing token to be reused")
+	}
+}
+
+// TestRemoveUpload tests that only gateway uploads are deleted
+func TestRemoveUpload(t *testing.T) {
+	cs := newControlTestServer(t)
+	c := cs.client
+
+	uploadDir := filepath.Join(c.config.UploadDir(), "upload-1")
+	if err := os.MkdirAll(uploadDir, DirPerm); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	upload := filepath.Join(uploadDir, "photo.jpg")
+	other := filepath.Join(c.config.ConfigDir, "photo.jpg")
+	for _, path := range []string{upload, other} {
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	c.removeUpload(other)
+	if _, err := os.Stat(other); err != nil {
+		t.Error("Expected files outside the upload directory to be kept")
+	}
+
+	c.removeUpload(upload)
+	if _, err := os.Stat(uploadDir); !os.IsNotExist(err) {
+		t.Error("Expected the upload directory to be removed")
+	}
+	if _, err := os.Stat(c.config.UploadDir()); err != nil {
+		t.Error("Expected the upload root to be kept")
+	}
+}
@@ -0,0 +1,110 @@
+// Package client implements persistent friend last-seen tracking for ratox-go
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// lastSeenStore persists the last time each friend was seen online, keyed by
+// hex public key, so the value survives restarts
+type lastSeenStore struct {
+	path  string
+	times map[string]time.Time
+	mu    sync.RWMutex
+}
+
+// newLastSeenStore creates a last-seen store backed by the given file and
+// loads any times already saved there
+func newLastSeenStore(path string) (*lastSeenStore, error) {
+	ls := &lastSeenStore{
+		path:  path,
+		times: make(map[string]time.Time),
+	}
+
+	if err := ls.load(); err != nil {
+		return nil, err
+	}
+
+	return ls, nil
+}
+
+// load reads the store from disk. A missing file is treated as an empty store.
+func (ls *lastSeenStore) load() error {
+	data, err := os.ReadFile(ls.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read last seen times: %w", err)
+	}
+
+	times := make(map[string]time.Time)
+	if err := json.Unmarshal(data, &times); err != nil {
+		return fmt.Errorf("failed to parse last seen times: %w", err)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.times = times
+
+	return nil
+}
+
+// saveLocked writes the store to disk. The caller must hold ls.mu.
+func (ls *lastSeenStore) saveLocked() error {
+	data, err := json.MarshalIndent(ls.times, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal last seen times: %w", err)
+	}
+
+	if err := config.WriteFileAtomic(ls.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write last seen times: %w", err)
+	}
+
+	return nil
+}
+
+// Set records when a friend was last seen
+func (ls *lastSeenStore) Set(publicKey string, seen time.Time) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.times[publicKey] = seen.UTC()
+	return ls.saveLocked()
+}
+
+// SetMany records several last-seen times with a single write
+func (ls *lastSeenStore) SetMany(times map[string]time.Time) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for publicKey, seen := range times {
+		ls.times[publicKey] = seen.UTC()
+	}
+	return ls.saveLocked()
+}
+
+// Get returns when a friend was last seen, or the zero time if never
+func (ls *lastSeenStore) Get(publicKey string) time.Time {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	return ls.times[publicKey]
+}
+
+// Remove forgets a friend's last-seen time
+func (ls *lastSeenStore) Remove(publicKey string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if _, exists := ls.times[publicKey]; !exists {
+		return nil
+	}
+	delete(ls.times, publicKey)
+	return ls.saveLocked()
+}
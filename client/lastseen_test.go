@@ -0,0 +1,46 @@
+package client
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLastSeenStorePersistence tests that last seen times survive a reload
+func TestLastSeenStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "last_seen.json")
+	seen := time.Date(2024, 2, 29, 23, 59, 0, 0, time.UTC)
+
+	ls, err := newLastSeenStore(path)
+	if err != nil {
+		t.Fatalf("newLastSeenStore failed: %v", err)
+	}
+	if !ls.Get("aaaa").IsZero() {
+		t.Error("Expected zero time for unknown friend")
+	}
+
+	if err := ls.Set("aaaa", seen); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := ls.SetMany(map[string]time.Time{"bbbb": seen.Add(time.Hour)}); err != nil {
+		t.Fatalf("SetMany failed: %v", err)
+	}
+
+	reloaded, err := newLastSeenStore(path)
+	if err != nil {
+		t.Fatalf("reloading store failed: %v", err)
+	}
+	if got := reloaded.Get("aaaa"); !got.Equal(seen) {
+		t.Errorf("Expected %v, got %v", seen, got)
+	}
+	if got := reloaded.Get("bbbb"); !got.Equal(seen.Add(time.Hour)) {
+		t.Errorf("Expected %v, got %v", seen.Add(time.Hour), got)
+	}
+
+	if err := reloaded.Remove("aaaa"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if !reloaded.Get("aaaa").IsZero() {
+		t.Error("Expected zero time after removal")
+	}
+}
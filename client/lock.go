@@ -0,0 +1,107 @@
+// Package client implements the single-instance lock on a profile directory
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// ProfileLock is an exclusive lock on a profile directory. The kernel drops
+// it when the holder exits, so a crash cannot leave the profile locked.
+type ProfileLock struct {
+	file *os.File
+}
+
+// ProfileLockedError is returned when another process holds the lock
+type ProfileLockedError struct {
+	Dir string
+	// PID is the holder's process ID, or 0 if it has not recorded one yet
+	PID int
+}
+
+func (e *ProfileLockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("profile %s is in use by another process", e.Dir)
+	}
+	return fmt.Sprintf("profile %s is in use by ratox-go (PID %d)", e.Dir, e.PID)
+}
+
+// LockProfile takes the lock on the profile in configDir, creating the
+// directory if needed, and records this process's PID in the lock file
+func LockProfile(configDir string) (*ProfileLock, error) {
+	if err := os.MkdirAll(configDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory: %w", err)
+	}
+
+	path := filepath.Join(configDir, config.LockFileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		pid := lockHolder(file)
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, &ProfileLockedError{Dir: configDir, PID: pid}
+		}
+		return nil, fmt.Errorf("failed to lock profile: %w", err)
+	}
+
+	// A PID left in an unlocked file belongs to a process that exited
+	// without releasing the lock
+	if pid := lockHolder(file); pid != 0 && pid != os.Getpid() {
+		log.Printf("Cleared stale lock on %s left by PID %d", configDir, pid)
+	}
+
+	if err := writeLockHolder(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return &ProfileLock{file: file}, nil
+}
+
+// writeLockHolder records this process's PID in the lock file
+func writeLockHolder(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// lockHolder returns the PID recorded in the lock file, or 0
+func lockHolder(file *os.File) int {
+	data := make([]byte, 32)
+	n, _ := file.ReadAt(data, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data[:n])))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
+
+// Release clears the recorded PID and drops the lock. A nil lock is a no-op.
+func (l *ProfileLock) Release() {
+	if l == nil || l.file == nil {
+		return
+	}
+
+	// Truncating first means a PID is only ever left behind by a crash
+	if err := l.file.Truncate(0); err != nil {
+		log.Printf("Failed to clear lock file: %v", err)
+	}
+	l.file.Close()
+	l.file = nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// TestLockProfile tests that a held lock is refused with its holder's PID
+func TestLockProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, config.LockFileName)
+
+	lock, err := LockProfile(dir)
+	if err != nil {
+		t.Fatalf("LockProfile failed: %v", err)
+	}
+	if got := readFileOrEmpty(t, path); got != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("Lock file = %q, want this process's PID", got)
+	}
+
+	_, err = LockProfile(dir)
+	var locked *ProfileLockedError
+	if !errors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Fatalf("Expected ProfileLockedError with PID %d, got %v", os.Getpid(), err)
+	}
+
+	lock.Release()
+	lock.Release()
+	if got := readFileOrEmpty(t, path); got != "" {
+		t.Errorf("Lock file after Release = %q, want empty", got)
+	}
+
+	lock, err = LockProfile(dir)
+	if err != nil {
+		t.Fatalf("LockProfile after Release failed: %v", err)
+	}
+	lock.Release()
+}
+
+// TestLockProfileStale tests that a lock file left by a crash is taken over
+func TestLockProfileStale(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, config.LockFileName)
+	if err := os.WriteFile(path, []byte("999999999\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	lock, err := LockProfile(dir)
+	if err != nil {
+		t.Fatalf("LockProfile failed: %v", err)
+	}
+	defer lock.Release()
+
+	if got := readFileOrEmpty(t, path); got != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("Lock file = %q, want this process's PID", got)
+	}
+}
@@ -0,0 +1,953 @@
+// Package client implements the 9P2000 file server for ratox-go
+package client
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 9P2000 message types
+const (
+	ninepTversion = 100 + iota
+	ninepRversion
+	ninepTauth
+	ninepRauth
+	ninepTattach
+	ninepRattach
+	ninepTerror // never sent
+	ninepRerror
+	ninepTflush
+	ninepRflush
+	ninepTwalk
+	ninepRwalk
+	ninepTopen
+	ninepRopen
+	ninepTcreate
+	ninepRcreate
+	ninepTread
+	ninepRread
+	ninepTwrite
+	ninepRwrite
+	ninepTclunk
+	ninepRclunk
+	ninepTremove
+	ninepRremove
+	ninepTstat
+	ninepRstat
+	ninepTwstat
+	ninepRwstat
+)
+
+const (
+	ninepVersion = "9P2000"
+	ninepNoFid   = ^uint32(0)
+
+	// ninepHeaderSize is size[4] type[1] tag[2]
+	ninepHeaderSize = 7
+	// ninepIOHeaderSize is the room left for the header of Rread and Twrite
+	ninepIOHeaderSize = 24
+	// ninepMinMsize and ninepMaxMsize bound the negotiated message size
+	ninepMinMsize = 256
+	ninepMaxMsize = 64*1024 + ninepIOHeaderSize
+	// ninepMaxWalk is the most names a single Twalk may carry
+	ninepMaxWalk = 16
+
+	ninepQTDir  = 0x80
+	ninepQTFile = 0x00
+	ninepDMDir  = 0x80000000
+
+	ninepOREAD  = 0
+	ninepOWRITE = 1
+	ninepORDWR  = 2
+	ninepOEXEC  = 3
+)
+
+// 9P error strings, worded as Plan 9 and the Linux client expect
+var (
+	errNinepBadMessage  = errors.New("malformed message")
+	errNinepNoAuth      = errors.New("authentication not required")
+	errNinepBadToken    = errors.New("access token required as aname")
+	errNinepUnknownFid  = errors.New("fid unknown or out of range")
+	errNinepFidInUse    = errors.New("fid already in use")
+	errNinepNotFound    = errors.New("file does not exist")
+	errNinepNotDir      = errors.New("not a directory")
+	errNinepPerm        = errors.New("permission denied")
+	errNinepOpen        = errors.New("file already open")
+	errNinepNotOpen     = errors.New("file not open")
+	errNinepInterrupted = errors.New("interrupted")
+	errNinepBadType     = errors.New("unknown message type")
+)
+
+// NinePServer exports the same tree as the FIFOManager over 9P2000, backed
+// directly by the client, so it can be mounted with standard 9P clients. It
+// listens on a Unix socket in the profile directory or on a loopback address.
+// Reads of output files block until the next line, and rejected writes fail
+// with the reason as the 9P error. It follows the event bus, so it ignores the
+// Frontend notifications.
+type NinePServer struct {
+	NopFrontend
+	tree    *fileTree
+	network string
+	address string
+	owner   string
+	// token must be given as the aname on a TCP listener, which any local
+	// user can reach
+	token    string
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// NewNinePServer creates a 9P server for client on the configured address
+func NewNinePServer(client *Client) *NinePServer {
+	ns := &NinePServer{
+		tree:    newFileTree(client),
+		network: "unix",
+		address: client.config.NinePSocketPath(),
+		owner:   strconv.Itoa(os.Getuid()),
+		conns:   make(map[net.Conn]struct{}),
+	}
+	if addr := client.config.NineP.Address; addr != "" {
+		ns.network = "tcp"
+		ns.address = addr
+	}
+	if u, err := user.Current(); err == nil {
+		ns.owner = u.Username
+	}
+	return ns
+}
+
+// Start opens the listener
+func (ns *NinePServer) Start() error {
+	if err := ns.Listen(); err != nil {
+		return fmt.Errorf("failed to start 9P server: %w", err)
+	}
+
+	if ns.token != "" {
+		ns.tree.client.logf("9P server listening on %s (token in %s)", ns.Addr(), ns.tree.client.config.NinePTokenPath())
+		return nil
+	}
+	ns.tree.client.logf("9P server listening on %s", ns.Addr())
+	return nil
+}
+
+// Listen binds the Unix socket, replacing a stale one, or the loopback address
+// guarded by the access token in 9p_token
+func (ns *NinePServer) Listen() error {
+	if ns.network == "tcp" {
+		if err := validateLoopbackAddress(ns.address); err != nil {
+			return err
+		}
+		token, err := loadOrCreateToken(ns.tree.client.config.NinePTokenPath())
+		if err != nil {
+			return err
+		}
+		ns.token = token
+		listener, err := net.Listen("tcp", ns.address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", ns.address, err)
+		}
+		ns.listener = listener
+		return nil
+	}
+
+	if err := removeStaleSocket(ns.address); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", ns.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on 9P socket: %w", err)
+	}
+	if err := os.Chmod(ns.address, SocketPerm); err != nil {
+		listener.Close()
+		os.Remove(ns.address)
+		return fmt.Errorf("failed to set 9P socket permissions: %w", err)
+	}
+	ns.listener = listener
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (ns *NinePServer) Addr() string {
+	return ns.listener.Addr().String()
+}
+
+// Serve accepts connections until ctx is cancelled, then ends blocked reads,
+// closes every connection and removes the socket
+func (ns *NinePServer) Serve(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		ns.tree.close()
+		ns.listener.Close()
+		ns.mu.Lock()
+		for conn := range ns.conns {
+			conn.Close()
+		}
+		ns.mu.Unlock()
+	}()
+
+	for {
+		conn, err := ns.listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				ns.tree.client.logf("9P server accept error: %v", err)
+			}
+			break
+		}
+
+		ns.mu.Lock()
+		if ctx.Err() != nil {
+			ns.mu.Unlock()
+			conn.Close()
+			break
+		}
+		ns.conns[conn] = struct{}{}
+		ns.mu.Unlock()
+
+		ns.wg.Add(1)
+		go func() {
+			defer ns.wg.Done()
+			ns.handleConn(conn)
+			ns.mu.Lock()
+			delete(ns.conns, conn)
+			ns.mu.Unlock()
+		}()
+	}
+
+	ns.wg.Wait()
+	if ns.network == "unix" {
+		if err := os.Remove(ns.address); err != nil && !os.IsNotExist(err) {
+			ns.tree.client.logf("Failed to remove 9P socket: %v", err)
+		}
+	}
+}
+
+// ninepEncoder appends little-endian 9P fields to a message
+type ninepEncoder struct {
+	buf []byte
+}
+
+func (e *ninepEncoder) u8(v uint8)   { e.buf = append(e.buf, v) }
+func (e *ninepEncoder) u16(v uint16) { e.buf = binary.LittleEndian.AppendUint16(e.buf, v) }
+func (e *ninepEncoder) u32(v uint32) { e.buf = binary.LittleEndian.AppendUint32(e.buf, v) }
+func (e *ninepEncoder) u64(v uint64) { e.buf = binary.LittleEndian.AppendUint64(e.buf, v) }
+
+func (e *ninepEncoder) str(s string) {
+	e.u16(uint16(len(s))) //nolint:gosec // names and errors are short
+	e.buf = append(e.buf, s...)
+}
+
+func (e *ninepEncoder) qid(q ninepQid) {
+	e.u8(q.typ)
+	e.u32(q.version)
+	e.u64(q.path)
+}
+
+// ninepDecoder reads little-endian 9P fields from a message, remembering
+// whether it ran short
+type ninepDecoder struct {
+	buf []byte
+	err error
+}
+
+// ninepZero stands in for the fixed-size fields of a short message
+var ninepZero [8]byte
+
+func (d *ninepDecoder) next(n int) []byte {
+	if d.err != nil || n < 0 || len(d.buf) < n {
+		d.err = errNinepBadMessage
+		if n <= len(ninepZero) && n >= 0 {
+			return ninepZero[:n]
+		}
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *ninepDecoder) u8() uint8   { return d.next(1)[0] }
+func (d *ninepDecoder) u16() uint16 { return binary.LittleEndian.Uint16(d.next(2)) }
+func (d *ninepDecoder) u32() uint32 { return binary.LittleEndian.Uint32(d.next(4)) }
+func (d *ninepDecoder) u64() uint64 { return binary.LittleEndian.Uint64(d.next(8)) }
+func (d *ninepDecoder) str() string { return string(d.next(int(d.u16()))) }
+
+// ninepQid identifies a file to the client
+type ninepQid struct {
+	typ     uint8
+	version uint32
+	path    uint64
+}
+
+// qidFor derives a stable qid from a file's path in the tree
+func qidFor(path []string, n *treeNode) ninepQid {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(path, "/")))
+
+	q := ninepQid{typ: ninepQTFile, path: h.Sum64()}
+	if n.isDir() {
+		q.typ = ninepQTDir
+	}
+	return q
+}
+
+// ninepFid is a client's handle on a path in the tree and, once opened, on
+// the open file
+type ninepFid struct {
+	path   []string
+	opened bool
+	node   *treeNode
+	input  *treeInputWriter
+	stream *treeStreamReader
+	data   []byte
+}
+
+// close releases the open file, running a final partial line written to an
+// input file
+func (f *ninepFid) close() error {
+	if f.stream != nil {
+		f.stream.Close()
+	}
+	if f.input != nil {
+		return f.input.Flush()
+	}
+	return nil
+}
+
+// ninepRequest is a request in progress, which Tflush can cancel
+type ninepRequest struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	// aborted drops the reply of a request cancelled by Tversion. Guarded by
+	// ninepConn.mu.
+	aborted bool
+}
+
+// ninepConn is one client connection
+type ninepConn struct {
+	server   *NinePServer
+	conn     net.Conn
+	msize    uint32
+	fids     map[uint32]*ninepFid
+	requests map[uint16]*ninepRequest
+	mu       sync.Mutex
+	writeMu  sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// handleConn reads requests from one connection and answers each in its own
+// goroutine, so a blocked read does not hold up the others
+func (ns *NinePServer) handleConn(conn net.Conn) {
+	c := &ninepConn{
+		server:   ns,
+		conn:     conn,
+		msize:    ninepMaxMsize,
+		fids:     make(map[uint32]*ninepFid),
+		requests: make(map[uint16]*ninepRequest),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		c.wg.Wait()
+		c.clunkAll()
+		conn.Close()
+	}()
+
+	for {
+		msgType, tag, body, err := c.readMessage()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && ns.tree.client.config.Debug {
+				ns.tree.client.logf("9P read error: %v", err)
+			}
+			return
+		}
+
+		// Tversion resets the session, aborting everything else first
+		if msgType == ninepTversion {
+			c.abortAll()
+			c.wg.Wait()
+			c.reply(tag, ninepRversion, c.version(body))
+			continue
+		}
+
+		reqCtx, reqCancel := context.WithCancel(ctx)
+		req := &ninepRequest{cancel: reqCancel, done: make(chan struct{})}
+		c.mu.Lock()
+		c.requests[tag] = req
+		c.mu.Unlock()
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			defer reqCancel()
+
+			respType, resp, err := c.handle(reqCtx, msgType, body)
+			if err != nil {
+				e := &ninepEncoder{}
+				e.str(err.Error())
+				respType, resp = ninepRerror, e.buf
+			}
+
+			c.mu.Lock()
+			if c.requests[tag] == req {
+				delete(c.requests, tag)
+			}
+			aborted := req.aborted
+			c.mu.Unlock()
+
+			if !aborted {
+				c.reply(tag, respType, resp)
+			}
+			close(req.done)
+		}()
+	}
+}
+
+// readMessage reads one message, which must fit the negotiated size
+func (c *ninepConn) readMessage() (uint8, uint16, []byte, error) {
+	header := make([]byte, ninepHeaderSize)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return 0, 0, nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(header)
+	if size < ninepHeaderSize || size > c.msize {
+		return 0, 0, nil, fmt.Errorf("invalid message size %d", size)
+	}
+
+	body := make([]byte, size-ninepHeaderSize)
+	if _, err := io.ReadFull(c.conn, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return header[4], binary.LittleEndian.Uint16(header[5:]), body, nil
+}
+
+// reply writes one response
+func (c *ninepConn) reply(tag uint16, msgType uint8, body []byte) {
+	e := &ninepEncoder{buf: make([]byte, 0, ninepHeaderSize+len(body))}
+	e.u32(uint32(ninepHeaderSize + len(body))) //nolint:gosec // bounded by msize
+	e.u8(msgType)
+	e.u16(tag)
+	e.buf = append(e.buf, body...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.conn.Write(e.buf); err != nil && c.server.tree.client.config.Debug {
+		c.server.tree.client.logf("9P write error: %v", err)
+	}
+}
+
+// handle dispatches one request and returns the response type and body
+func (c *ninepConn) handle(ctx context.Context, msgType uint8, body []byte) (uint8, []byte, error) {
+	d := &ninepDecoder{buf: body}
+
+	var (
+		resp []byte
+		err  error
+	)
+	switch msgType {
+	case ninepTauth:
+		return 0, nil, errNinepNoAuth
+	case ninepTattach:
+		resp, err = c.attach(d)
+	case ninepTflush:
+		resp, err = c.flush(d)
+	case ninepTwalk:
+		resp, err = c.walk(d)
+	case ninepTopen:
+		resp, err = c.open(d)
+	case ninepTcreate:
+		return 0, nil, errNinepPerm
+	case ninepTread:
+		resp, err = c.read(ctx, d)
+	case ninepTwrite:
+		resp, err = c.write(d)
+	case ninepTclunk:
+		resp, err = c.clunk(d)
+	case ninepTremove:
+		// Remove clunks the fid even though nothing can be removed
+		if _, err := c.clunk(d); err != nil {
+			return 0, nil, err
+		}
+		return 0, nil, errNinepPerm
+	case ninepTstat:
+		resp, err = c.stat(d)
+	case ninepTwstat:
+		resp, err = c.wstat(d)
+	default:
+		return 0, nil, errNinepBadType
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return msgType + 1, resp, nil
+}
+
+// version negotiates the message size and protocol version and resets the
+// session
+func (c *ninepConn) version(body []byte) []byte {
+	d := &ninepDecoder{buf: body}
+	msize := d.u32()
+	version := d.str()
+
+	c.clunkAll()
+
+	e := &ninepEncoder{}
+	if d.err != nil || msize < ninepMinMsize || !strings.HasPrefix(version, ninepVersion) {
+		e.u32(c.msize)
+		e.str("unknown")
+		return e.buf
+	}
+
+	c.msize = min(msize, ninepMaxMsize)
+	e.u32(c.msize)
+	e.str(ninepVersion)
+	return e.buf
+}
+
+// abortAll cancels every request in progress without replying to it, so
+// blocked stream reads return
+func (c *ninepConn) abortAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, req := range c.requests {
+		req.aborted = true
+		req.cancel()
+	}
+}
+
+// clunkAll releases every fid
+func (c *ninepConn) clunkAll() {
+	c.mu.Lock()
+	fids := c.fids
+	c.fids = make(map[uint32]*ninepFid)
+	c.mu.Unlock()
+
+	for _, fid := range fids {
+		fid.close()
+	}
+}
+
+// fid looks up a fid
+func (c *ninepConn) fid(id uint32) (*ninepFid, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fid, exists := c.fids[id]
+	if !exists {
+		return nil, errNinepUnknownFid
+	}
+	return fid, nil
+}
+
+// addFid registers a new fid
+func (c *ninepConn) addFid(id uint32, fid *ninepFid) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.fids[id]; exists {
+		return errNinepFidInUse
+	}
+	c.fids[id] = fid
+	return nil
+}
+
+// attach gives the client a fid on the root of the tree
+func (c *ninepConn) attach(d *ninepDecoder) ([]byte, error) {
+	fid := d.u32()
+	afid := d.u32()
+	d.str() // uname
+	aname := d.str()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if afid != ninepNoFid {
+		return nil, errNinepNoAuth
+	}
+	if token := c.server.token; token != "" && subtle.ConstantTimeCompare([]byte(aname), []byte(token)) != 1 {
+		return nil, errNinepBadToken
+	}
+
+	if err := c.addFid(fid, &ninepFid{}); err != nil {
+		return nil, err
+	}
+
+	e := &ninepEncoder{}
+	e.qid(qidFor(nil, c.server.tree.root()))
+	return e.buf, nil
+}
+
+// flush cancels an earlier request and answers once it has been answered
+func (c *ninepConn) flush(d *ninepDecoder) ([]byte, error) {
+	oldTag := d.u16()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	c.mu.Lock()
+	req := c.requests[oldTag]
+	c.mu.Unlock()
+
+	if req != nil {
+		req.cancel()
+		<-req.done
+	}
+	return nil, nil
+}
+
+// walk follows names from fid, binding newfid to the result if every name
+// was found
+func (c *ninepConn) walk(d *ninepDecoder) ([]byte, error) {
+	fidID := d.u32()
+	newFidID := d.u32()
+	count := d.u16()
+	if count > ninepMaxWalk {
+		return nil, errNinepBadMessage
+	}
+	names := make([]string, count)
+	for i := range names {
+		names[i] = d.str()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	fid, err := c.fid(fidID)
+	if err != nil {
+		return nil, err
+	}
+	if fid.opened {
+		return nil, errNinepOpen
+	}
+
+	path := append([]string(nil), fid.path...)
+	qids := make([]ninepQid, 0, len(names))
+	for _, name := range names {
+		node, exists := c.server.tree.walk(path)
+		if !exists {
+			break
+		}
+		if !node.isDir() {
+			if len(qids) == 0 {
+				return nil, errNinepNotDir
+			}
+			break
+		}
+
+		next := append(append([]string(nil), path...), name)
+		if name == ".." {
+			next = path[:max(len(path)-1, 0)]
+		}
+		child, exists := c.server.tree.walk(next)
+		if !exists {
+			break
+		}
+		path = next
+		qids = append(qids, qidFor(path, child))
+	}
+
+	if len(qids) == 0 && len(names) > 0 {
+		return nil, errNinepNotFound
+	}
+
+	// Only a complete walk binds newfid
+	if len(qids) == len(names) {
+		if newFidID == fidID {
+			c.mu.Lock()
+			fid.path = path
+			c.mu.Unlock()
+		} else if err := c.addFid(newFidID, &ninepFid{path: path}); err != nil {
+			return nil, err
+		}
+	}
+
+	e := &ninepEncoder{}
+	e.u16(uint16(len(qids))) //nolint:gosec // at most ninepMaxWalk
+	for _, q := range qids {
+		e.qid(q)
+	}
+	return e.buf, nil
+}
+
+// open opens input files for writing and directories, streams and snapshots
+// for reading
+func (c *ninepConn) open(d *ninepDecoder) ([]byte, error) {
+	fidID := d.u32()
+	mode := d.u8()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	fid, err := c.fid(fidID)
+	if err != nil {
+		return nil, err
+	}
+	if fid.opened {
+		return nil, errNinepOpen
+	}
+
+	node, exists := c.server.tree.walk(fid.path)
+	if !exists {
+		return nil, errNinepNotFound
+	}
+
+	access := mode & 3
+	reading := access == ninepOREAD || access == ninepOEXEC
+	writing := access == ninepOWRITE || access == ninepORDWR
+
+	opened := &ninepFid{path: fid.path, opened: true, node: node}
+	switch {
+	case node.isDir() && reading:
+		opened.data = c.dirEntries(fid.path, node)
+	case node.isInput() && writing:
+		opened.input = c.server.tree.openInput(node)
+	case node.isStream() && reading:
+		opened.stream = c.server.tree.openStream(node)
+	case node.isSnapshot() && reading:
+		opened.data = []byte(node.content())
+	default:
+		return nil, errNinepPerm
+	}
+
+	// Another Topen or a Tclunk of the fid may have run meanwhile
+	c.mu.Lock()
+	current, exists := c.fids[fidID]
+	won := exists && current == fid
+	if won {
+		c.fids[fidID] = opened
+	}
+	c.mu.Unlock()
+	if !won {
+		opened.close()
+		if !exists {
+			return nil, errNinepUnknownFid
+		}
+		return nil, errNinepOpen
+	}
+
+	e := &ninepEncoder{}
+	e.qid(qidFor(fid.path, node))
+	e.u32(c.msize - ninepIOHeaderSize)
+	return e.buf, nil
+}
+
+// dirEntries encodes the stat of every entry of a directory, in name order
+func (c *ninepConn) dirEntries(path []string, dir *treeNode) []byte {
+	entries := dir.entries()
+	e := &ninepEncoder{}
+	for _, name := range sortedNames(entries) {
+		childPath := append(append([]string(nil), path...), name)
+		c.server.encodeStat(e, childPath, entries[name])
+	}
+	return e.buf
+}
+
+// read returns directory entries, snapshot content, or the next lines of a
+// stream, waiting for them if needed
+func (c *ninepConn) read(ctx context.Context, d *ninepDecoder) ([]byte, error) {
+	fidID := d.u32()
+	offset := d.u64()
+	count := d.u32()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	fid, err := c.fid(fidID)
+	if err != nil {
+		return nil, err
+	}
+	if !fid.opened {
+		return nil, errNinepNotOpen
+	}
+	count = min(count, c.msize-ninepIOHeaderSize)
+
+	var data []byte
+	switch {
+	case fid.node.isDir():
+		data = dirChunk(fid.data, offset, count)
+	case fid.stream != nil:
+		buf := make([]byte, count)
+		n, err := fid.stream.Read(ctx, buf)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, errNinepInterrupted
+		}
+		data = buf[:n]
+	case fid.node.isSnapshot():
+		if offset < uint64(len(fid.data)) {
+			data = fid.data[offset:]
+			data = data[:min(uint64(len(data)), uint64(count))]
+		}
+	default:
+		return nil, errNinepPerm
+	}
+
+	e := &ninepEncoder{buf: make([]byte, 0, 4+len(data))}
+	e.u32(uint32(len(data))) //nolint:gosec // bounded by msize
+	e.buf = append(e.buf, data...)
+	return e.buf, nil
+}
+
+// dirChunk returns the whole stat entries starting at offset that fit in
+// count bytes. Clients only read directories at offsets returned earlier.
+func dirChunk(data []byte, offset uint64, count uint32) []byte {
+	if offset >= uint64(len(data)) {
+		return nil
+	}
+	data = data[offset:]
+
+	end := 0
+	for end+2 <= len(data) {
+		size := 2 + int(binary.LittleEndian.Uint16(data[end:]))
+		if end+size > len(data) || end+size > int(count) {
+			break
+		}
+		end += size
+	}
+	return data[:end]
+}
+
+// write runs the lines written to an input file. A rejected line fails the
+// write with the rejection as the error.
+func (c *ninepConn) write(d *ninepDecoder) ([]byte, error) {
+	fidID := d.u32()
+	d.u64() // offset
+	count := d.u32()
+	if d.err == nil && int64(count) > int64(len(d.buf)) {
+		d.err = errNinepBadMessage
+	}
+	data := d.next(int(count))
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	fid, err := c.fid(fidID)
+	if err != nil {
+		return nil, err
+	}
+	if fid.input == nil {
+		if !fid.opened {
+			return nil, errNinepNotOpen
+		}
+		return nil, errNinepPerm
+	}
+
+	if err := fid.input.Write(data); err != nil {
+		return nil, err
+	}
+
+	e := &ninepEncoder{}
+	e.u32(count)
+	return e.buf, nil
+}
+
+// clunk forgets a fid, running a final partial line written to it
+func (c *ninepConn) clunk(d *ninepDecoder) ([]byte, error) {
+	fidID := d.u32()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	c.mu.Lock()
+	fid, exists := c.fids[fidID]
+	delete(c.fids, fidID)
+	c.mu.Unlock()
+
+	if !exists {
+		return nil, errNinepUnknownFid
+	}
+	if err := fid.close(); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// stat describes the file behind a fid
+func (c *ninepConn) stat(d *ninepDecoder) ([]byte, error) {
+	fidID := d.u32()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	fid, err := c.fid(fidID)
+	if err != nil {
+		return nil, err
+	}
+	node, exists := c.server.tree.walk(fid.path)
+	if !exists {
+		return nil, errNinepNotFound
+	}
+
+	stat := &ninepEncoder{}
+	c.server.encodeStat(stat, fid.path, node)
+
+	e := &ninepEncoder{}
+	e.u16(uint16(len(stat.buf))) //nolint:gosec // a stat is far below 64KB
+	e.buf = append(e.buf, stat.buf...)
+	return e.buf, nil
+}
+
+// wstat accepts the truncation of input files and refuses anything else
+func (c *ninepConn) wstat(d *ninepDecoder) ([]byte, error) {
+	fidID := d.u32()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	fid, err := c.fid(fidID)
+	if err != nil {
+		return nil, err
+	}
+	node, exists := c.server.tree.walk(fid.path)
+	if !exists {
+		return nil, errNinepNotFound
+	}
+	if !node.isInput() {
+		return nil, errNinepPerm
+	}
+	return nil, nil
+}
+
+// encodeStat appends the 9P stat of a file, including its size prefix
+func (ns *NinePServer) encodeStat(e *ninepEncoder, path []string, n *treeNode) {
+	name := "/"
+	if len(path) > 0 {
+		name = path[len(path)-1]
+	}
+
+	mode := n.perm()
+	if n.isDir() {
+		mode |= ninepDMDir
+	}
+	now := uint32(time.Now().Unix()) //nolint:gosec // 9P times are 32-bit
+
+	stat := &ninepEncoder{}
+	stat.u16(0) // type
+	stat.u32(0) // dev
+	stat.qid(qidFor(path, n))
+	stat.u32(mode)
+	stat.u32(now) // atime
+	stat.u32(now) // mtime
+	stat.u64(n.size())
+	stat.str(name)
+	stat.str(ns.owner) // uid
+	stat.str(ns.owner) // gid
+	stat.str(ns.owner) // muid
+
+	e.u16(uint16(len(stat.buf))) //nolint:gosec // a stat is far below 64KB
+	e.buf = append(e.buf, stat.buf...)
+}
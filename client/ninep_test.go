@@ -0,0 +1,412 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ninepTestClient speaks just enough 9P2000 to drive the server in tests
+type ninepTestClient struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+// newNinePTestClient serves the tree of a test client with one friend and
+// connects to it, returning the server, client and friend ID
+func newNinePTestClient(t *testing.T) (*NinePServer, *ninepTestClient, string) {
+	t.Helper()
+
+	c, friendID := newTreeTestClient(t)
+	c.config.NineP.Enabled = true
+	ns := NewNinePServer(c)
+	if err := ns.Listen(); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ns.Serve(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	conn, err := net.Dial("unix", c.config.NinePSocketPath())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	return ns, &ninepTestClient{t: t, conn: conn}, friendID
+}
+
+// send writes one request
+func (tc *ninepTestClient) send(msgType uint8, tag uint16, body func(e *ninepEncoder)) {
+	tc.t.Helper()
+
+	msg := &ninepEncoder{}
+	if body != nil {
+		body(msg)
+	}
+	e := &ninepEncoder{}
+	e.u32(uint32(ninepHeaderSize + len(msg.buf)))
+	e.u8(msgType)
+	e.u16(tag)
+	e.buf = append(e.buf, msg.buf...)
+	if _, err := tc.conn.Write(e.buf); err != nil {
+		tc.t.Fatalf("Failed to send: %v", err)
+	}
+}
+
+// recv reads one response, turning Rerror into an error
+func (tc *ninepTestClient) recv() (uint8, uint16, *ninepDecoder, error) {
+	tc.t.Helper()
+
+	header := make([]byte, ninepHeaderSize)
+	if _, err := io.ReadFull(tc.conn, header); err != nil {
+		tc.t.Fatalf("Failed to receive: %v", err)
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(header)-ninepHeaderSize)
+	if _, err := io.ReadFull(tc.conn, body); err != nil {
+		tc.t.Fatalf("Failed to receive: %v", err)
+	}
+
+	d := &ninepDecoder{buf: body}
+	if header[4] == ninepRerror {
+		return header[4], binary.LittleEndian.Uint16(header[5:]), nil, errors.New(d.str())
+	}
+	return header[4], binary.LittleEndian.Uint16(header[5:]), d, nil
+}
+
+// rpc sends one request and waits for its response
+func (tc *ninepTestClient) rpc(msgType uint8, body func(e *ninepEncoder)) (*ninepDecoder, error) {
+	tc.t.Helper()
+
+	tc.send(msgType, 1, body)
+	respType, _, d, err := tc.recv()
+	if err == nil && respType != msgType+1 {
+		tc.t.Fatalf("Response type = %d, want %d", respType, msgType+1)
+	}
+	return d, err
+}
+
+// must fails the test if a request was rejected
+func (tc *ninepTestClient) must(d *ninepDecoder, err error) *ninepDecoder {
+	tc.t.Helper()
+	if err != nil {
+		tc.t.Fatalf("Request failed: %v", err)
+	}
+	return d
+}
+
+// attach negotiates the session and binds fid 0 to the root
+func (tc *ninepTestClient) attach() {
+	tc.t.Helper()
+
+	d := tc.must(tc.rpc(ninepTversion, func(e *ninepEncoder) {
+		e.u32(8192)
+		e.str("9P2000")
+	}))
+	if msize, version := d.u32(), d.str(); msize != 8192 || version != ninepVersion {
+		tc.t.Fatalf("Rversion = %d %q, want 8192 %q", msize, version, ninepVersion)
+	}
+
+	tc.must(tc.rpc(ninepTattach, func(e *ninepEncoder) {
+		e.u32(0)
+		e.u32(ninepNoFid)
+		e.str("user")
+		e.str("")
+	}))
+}
+
+// open walks from the root to fid and opens it with mode. A walk that stops
+// early fails with errNinepNotFound.
+func (tc *ninepTestClient) open(fid uint32, mode uint8, names ...string) error {
+	tc.t.Helper()
+
+	d, err := tc.rpc(ninepTwalk, func(e *ninepEncoder) {
+		e.u32(0)
+		e.u32(fid)
+		e.u16(uint16(len(names)))
+		for _, name := range names {
+			e.str(name)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	// A partial walk succeeds without binding the new fid
+	if int(d.u16()) != len(names) {
+		return errNinepNotFound
+	}
+
+	_, err = tc.rpc(ninepTopen, func(e *ninepEncoder) {
+		e.u32(fid)
+		e.u8(mode)
+	})
+	return err
+}
+
+// read reads up to 8000 bytes from fid at offset
+func (tc *ninepTestClient) read(fid uint32, offset uint64) []byte {
+	tc.t.Helper()
+
+	d := tc.must(tc.rpc(ninepTread, func(e *ninepEncoder) {
+		e.u32(fid)
+		e.u64(offset)
+		e.u32(8000)
+	}))
+	return d.next(int(d.u32()))
+}
+
+// write writes data to fid
+func (tc *ninepTestClient) write(fid uint32, data string) error {
+	tc.t.Helper()
+
+	_, err := tc.rpc(ninepTwrite, func(e *ninepEncoder) {
+		e.u32(fid)
+		e.u64(0)
+		e.u32(uint32(len(data)))
+		e.buf = append(e.buf, data...)
+	})
+	return err
+}
+
+func TestNinePServer(t *testing.T) {
+	ns, tc, friendID := newNinePTestClient(t)
+	tc.attach()
+
+	// The root lists client/, conferences/ and the friend
+	tc.must(nil, tc.open(1, ninepOREAD))
+	var names []string
+	d := &ninepDecoder{buf: tc.read(1, 0)}
+	for len(d.buf) > 0 && d.err == nil {
+		d.u16()           // size
+		d.next(2 + 4)     // type, dev
+		d.next(13)        // qid
+		d.next(4 + 4 + 4) // mode, atime, mtime
+		d.u64()           // length
+		names = append(names, d.str())
+		d.str() // uid
+		d.str() // gid
+		d.str() // muid
+	}
+	if got, want := strings.Join(names, " "), friendID+" client conferences"; got != want {
+		t.Errorf("Root entries = %q, want %q", got, want)
+	}
+
+	if err := tc.open(2, ninepOREAD, "client", "missing"); err == nil || err.Error() != errNinepNotFound.Error() {
+		t.Errorf("Walk to a missing file error = %v, want %v", err, errNinepNotFound)
+	}
+	if err := tc.open(2, ninepOREAD, friendID, AliasIn); err == nil || err.Error() != errNinepPerm.Error() {
+		t.Errorf("Reading an input file error = %v, want %v", err, errNinepPerm)
+	}
+	tc.must(tc.rpc(ninepTclunk, func(e *ninepEncoder) { e.u32(2) }))
+
+	// A rejected write fails with the reason and is reported in client/errors
+	tc.must(nil, tc.open(3, ninepOREAD, "client", ErrorsOut))
+	tc.must(nil, tc.open(4, ninepOWRITE, friendID, AliasIn))
+	if err := tc.write(4, "bad alias\n"); err == nil || !strings.Contains(err.Error(), "whitespace") {
+		t.Errorf("Rejected write error = %v, want the rejection reason", err)
+	}
+	if line, want := string(tc.read(3, 0)), friendID+"/alias_in \"bad alias\" "; !strings.HasPrefix(line, want) {
+		t.Errorf("Error line = %q, want prefix %q", line, want)
+	}
+
+	// A line without a newline runs when the fid is clunked
+	tc.must(nil, tc.write(4, "bobby"))
+	tc.must(tc.rpc(ninepTclunk, func(e *ninepEncoder) { e.u32(4) }))
+	if alias := ns.tree.client.FriendAlias(friendID); alias != "bobby" {
+		t.Errorf("Alias = %q, want %q", alias, "bobby")
+	}
+
+	tc.must(nil, tc.open(5, ninepOREAD, "client", FriendsList))
+	if roster := string(tc.read(5, 0)); !strings.Contains(roster, "bobby") {
+		t.Errorf("Roster = %q, want it to contain the alias", roster)
+	}
+
+	// Reads of a stream block until the next event
+	tc.must(nil, tc.open(6, ninepOREAD, friendID, TextOut))
+	ns.tree.client.publishEvent(EventMessage, friendID, map[string]interface{}{"message": "hi"})
+	if line := string(tc.read(6, 0)); !strings.HasSuffix(line, "<Bob> hi\n") {
+		t.Errorf("text_out line = %q, want suffix %q", line, "<Bob> hi\n")
+	}
+
+	// Tflush interrupts a blocked read
+	tc.send(ninepTread, 7, func(e *ninepEncoder) {
+		e.u32(6)
+		e.u64(0)
+		e.u32(8000)
+	})
+	tc.send(ninepTflush, 8, func(e *ninepEncoder) { e.u16(7) })
+	for _, want := range []uint16{7, 8} {
+		respType, tag, _, err := tc.recv()
+		if tag != want {
+			t.Fatalf("Response tag = %d, want %d", tag, want)
+		}
+		if tag == 7 && (err == nil || err.Error() != errNinepInterrupted.Error()) {
+			t.Errorf("Flushed read error = %v, want %v", err, errNinepInterrupted)
+		}
+		if tag == 8 && respType != ninepRflush {
+			t.Errorf("Flush response type = %d, want %d", respType, ninepRflush)
+		}
+	}
+}
+
+func TestDirChunk(t *testing.T) {
+	// Three entries of 4, 5 and 6 bytes including their size prefix
+	data := []byte{2, 0, 'a', 'a', 3, 0, 'b', 'b', 'b', 4, 0, 'c', 'c', 'c', 'c'}
+
+	tests := []struct {
+		name   string
+		offset uint64
+		count  uint32
+		want   int
+	}{
+		{"everything", 0, 100, 15},
+		{"whole entries only", 0, 10, 9},
+		{"too small for one entry", 0, 3, 0},
+		{"from the second entry", 4, 100, 11},
+		{"past the end", 15, 100, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dirChunk(data, tt.offset, tt.count); len(got) != tt.want {
+				t.Errorf("dirChunk(%d, %d) returned %d bytes, want %d", tt.offset, tt.count, len(got), tt.want)
+			}
+		})
+	}
+}
+
+// TestNinePWriteCount tests that a Twrite claiming more data than it carries
+// is rejected without allocating the claimed size
+func TestNinePWriteCount(t *testing.T) {
+	_, tc, _ := newNinePTestClient(t)
+	tc.attach()
+
+	_, err := tc.rpc(ninepTwrite, func(e *ninepEncoder) {
+		e.u32(0)
+		e.u64(0)
+		e.u32(0xFFFFFFFF)
+		e.buf = append(e.buf, "short"...)
+	})
+	if err == nil || err.Error() != errNinepBadMessage.Error() {
+		t.Errorf("Oversized write error = %v, want %v", err, errNinepBadMessage)
+	}
+
+	d := &ninepDecoder{buf: []byte{1}}
+	if got := d.next(1 << 30); got != nil || d.err == nil {
+		t.Errorf("Short read returned %d bytes, err %v; want nil and an error", len(got), d.err)
+	}
+	if got := d.u32(); got != 0 {
+		t.Errorf("u32 after a short read = %d, want 0", got)
+	}
+}
+
+// TestNinePServerToken tests that a TCP listener requires the access token
+// as the aname
+func TestNinePServerToken(t *testing.T) {
+	c, _ := newTreeTestClient(t)
+	c.config.NineP.Enabled = true
+	c.config.NineP.Address = "127.0.0.1:0"
+	ns := NewNinePServer(c)
+	if err := ns.Listen(); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ns.Serve(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	token, err := loadOrCreateToken(c.config.NinePTokenPath())
+	if err != nil {
+		t.Fatalf("loadOrCreateToken failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		aname   string
+		wantErr bool
+	}{
+		{"no token", "", true},
+		{"wrong token", "not-the-token", true},
+		{"token", token, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", ns.Addr())
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			tc := &ninepTestClient{t: t, conn: conn}
+
+			tc.must(tc.rpc(ninepTversion, func(e *ninepEncoder) {
+				e.u32(8192)
+				e.str("9P2000")
+			}))
+			_, err = tc.rpc(ninepTattach, func(e *ninepEncoder) {
+				e.u32(0)
+				e.u32(ninepNoFid)
+				e.str("user")
+				e.str(tt.aname)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Tattach error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestNinePVersionAbortsReads tests that Tversion aborts a blocked stream
+// read instead of waiting for it, and clunks its fid
+func TestNinePVersionAbortsReads(t *testing.T) {
+	_, tc, friendID := newNinePTestClient(t)
+	tc.attach()
+	tc.must(nil, tc.open(1, ninepOREAD, friendID, TextOut))
+
+	tc.send(ninepTread, 7, func(e *ninepEncoder) {
+		e.u32(1)
+		e.u64(0)
+		e.u32(8000)
+	})
+	tc.send(ninepTversion, 8, func(e *ninepEncoder) {
+		e.u32(8192)
+		e.str("9P2000")
+	})
+
+	// The aborted read gets no reply, so the next one is Rversion
+	respType, tag, _, err := tc.recv()
+	if err != nil || respType != ninepRversion || tag != 8 {
+		t.Fatalf("Response = type %d tag %d err %v, want Rversion for tag 8", respType, tag, err)
+	}
+
+	_, err = tc.rpc(ninepTread, func(e *ninepEncoder) {
+		e.u32(1)
+		e.u64(0)
+		e.u32(8000)
+	})
+	if err == nil || err.Error() != errNinepUnknownFid.Error() {
+		t.Errorf("Read after Tversion error = %v, want %v", err, errNinepUnknownFid)
+	}
+}
@@ -0,0 +1,143 @@
+// Package client implements offline access to a profile for ratox-go
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/opd-ai/go-ratox/config"
+	"github.com/opd-ai/toxcore"
+	"github.com/opd-ai/toxcore/crypto"
+)
+
+// OpenOffline loads the identity and friends of the profile of cfg without
+// connecting to the network or creating the tree, for reading them while
+// ratox-go is not running. Nothing is written to the profile except by
+// AddFriendOffline. Close releases the client.
+func OpenOffline(cfg *config.Config) (*Client, error) {
+	saveData, err := os.ReadFile(cfg.SaveFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		config:            cfg,
+		ctx:               ctx,
+		cancel:            cancel,
+		friends:           make(map[uint32]*Friend),
+		conferences:       make(map[uint32]*Conference),
+		incomingTransfers: make(map[string]*incomingTransfer),
+		outgoingTransfers: make(map[string]*outgoingTransfer),
+		shutdown:          make(chan struct{}),
+	}
+
+	options := toxcore.NewOptions()
+	options.UDPEnabled = false
+	options.LocalDiscovery = false
+	if err := c.restoreToxFromSavedata(options, saveData); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if err := c.loadOfflineStores(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := c.loadFriends(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to load friends: %w", err)
+	}
+	return c, nil
+}
+
+// loadOfflineStores reads the request, alias and last-seen stores
+func (c *Client) loadOfflineStores() error {
+	var err error
+	if c.pendingRequests, err = newRequestStore(c.config.PendingRequestsPath()); err != nil {
+		return fmt.Errorf("failed to load pending friend requests: %w", err)
+	}
+	if c.outgoingRequests, err = newOutgoingStore(c.config.OutgoingRequestsPath()); err != nil {
+		return fmt.Errorf("failed to load outgoing friend requests: %w", err)
+	}
+	if c.aliases, err = newAliasStore(c.config.AliasesPath(), c.logf); err != nil {
+		return fmt.Errorf("failed to load friend aliases: %w", err)
+	}
+	if c.lastSeen, err = newLastSeenStore(c.config.LastSeenPath()); err != nil {
+		return fmt.Errorf("failed to load friend last seen times: %w", err)
+	}
+	return nil
+}
+
+// AddFriendOffline adds the friend with a full Tox ID to a profile opened
+// with OpenOffline and saves it. toxcore can only send the request from a
+// running client, so it is queued and sent on the next start. The caller must
+// hold the profile lock.
+func (c *Client) AddFriendOffline(toxID, message string) (uint32, error) {
+	id, err := crypto.ToxIDFromString(toxID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Tox ID: %w", err)
+	}
+	publicKey := hex.EncodeToString(id.PublicKey[:])
+	if _, err := c.tox.GetFriendByPublicKey(id.PublicKey); err == nil {
+		return 0, fmt.Errorf("%s is already a friend", publicKey)
+	}
+
+	// Queued before AddFriend saves the friend, so a saved friend always has
+	// its request queued. A queued request without a saved friend is still
+	// sent on the next start.
+	req := OutgoingRequest{PublicKey: publicKey, ToxID: toxID, Message: message}
+	if err := c.outgoingRequests.Add(req); err != nil {
+		return 0, err
+	}
+	friendID, err := c.AddFriend(toxID, message)
+	if err != nil {
+		if err := c.outgoingRequests.Remove(publicKey); err != nil {
+			c.logf("Failed to update outgoing friend requests: %v", err)
+		}
+		return 0, err
+	}
+	if err := c.writeSaveData(c.tox.GetSavedata()); err != nil {
+		return 0, fmt.Errorf("failed to save identity: %w", err)
+	}
+	return friendID, nil
+}
+
+// sendOutgoingRequests sends the friend requests queued by AddFriendOffline.
+// The friend is already in the save data without the request, so it is
+// removed and added again for toxcore to send it.
+func (c *Client) sendOutgoingRequests() {
+	queued := c.outgoingRequests.List()
+	for _, req := range queued {
+		id, err := crypto.ToxIDFromString(req.ToxID)
+		if err != nil {
+			c.logf("Dropping queued friend request to %s: %v", req.PublicKey, err)
+		} else {
+			if friendID, err := c.tox.GetFriendByPublicKey(id.PublicKey); err == nil {
+				if err := c.tox.DeleteFriend(friendID); err != nil {
+					c.logf("Failed to resend friend request to %s: %v", req.PublicKey, err)
+					continue
+				}
+			}
+			if _, err := c.tox.AddFriend(req.ToxID, req.Message); err != nil {
+				c.logf("Failed to resend friend request to %s: %v", req.PublicKey, err)
+				continue
+			}
+			c.logf("Sent friend request to %s queued while offline", req.PublicKey)
+		}
+		if err := c.outgoingRequests.Remove(req.PublicKey); err != nil {
+			c.logf("Failed to update outgoing friend requests: %v", err)
+		}
+	}
+	if len(queued) > 0 {
+		c.saveToxData()
+	}
+}
+
+// Close releases a client opened with OpenOffline
+func (c *Client) Close() {
+	c.cancel()
+	c.tox.Kill()
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"os"
+	"testing"
+
+	"github.com/opd-ai/go-ratox/config"
+	"github.com/opd-ai/toxcore"
+)
+
+// TestOpenOffline tests reading a saved profile without changing it
+func TestOpenOffline(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{ConfigDir: dir, SaveFile: dir + "/" + config.SaveDataFileName}
+
+	tox, err := toxcore.New(toxcore.NewOptionsForTesting())
+	if err != nil {
+		t.Fatalf("toxcore.New failed: %v", err)
+	}
+	if err := tox.SelfSetName("offline"); err != nil {
+		t.Fatalf("SelfSetName failed: %v", err)
+	}
+	saveData := tox.GetSavedata()
+	wantID := tox.SelfGetAddress()
+	tox.Kill()
+	if err := os.WriteFile(cfg.SaveFile, saveData, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	c, err := OpenOffline(cfg)
+	if err != nil {
+		t.Fatalf("OpenOffline failed: %v", err)
+	}
+	info := c.Status()
+	friends := c.FriendList()
+	c.Close()
+
+	if info.ToxID != wantID {
+		t.Errorf("ToxID = %s, want %s", info.ToxID, wantID)
+	}
+	if info.Name != "offline" {
+		t.Errorf("Name = %q, want %q", info.Name, "offline")
+	}
+	if len(friends) != 0 {
+		t.Errorf("Expected no friends, got %d", len(friends))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the save file in the profile, found %d entries", len(entries))
+	}
+}
+
+// TestOpenOfflineWithoutProfile tests that a missing identity is an error
+func TestOpenOfflineWithoutProfile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{ConfigDir: dir, SaveFile: dir + "/" + config.SaveDataFileName}
+	if _, err := OpenOffline(cfg); err == nil {
+		t.Error("Expected OpenOffline to fail without a save file")
+	}
+}
+
+// newOfflineFriendTest saves a fresh identity and returns its config and the
+// Tox ID of a peer to add
+func newOfflineFriendTest(t *testing.T) (*config.Config, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := &config.Config{ConfigDir: dir, SaveFile: dir + "/" + config.SaveDataFileName}
+
+	tox, err := toxcore.New(toxcore.NewOptionsForTesting())
+	if err != nil {
+		t.Fatalf("toxcore.New failed: %v", err)
+	}
+	saveData := tox.GetSavedata()
+	tox.Kill()
+	if err := os.WriteFile(cfg.SaveFile, saveData, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	peer, err := toxcore.New(toxcore.NewOptionsForTesting())
+	if err != nil {
+		t.Fatalf("toxcore.New failed: %v", err)
+	}
+	peerID := peer.SelfGetAddress()
+	peer.Kill()
+
+	return cfg, peerID
+}
+
+// TestAddFriendOffline tests that a friend added to a stopped profile is
+// saved and its request queued until it is sent on the next start
+func TestAddFriendOffline(t *testing.T) {
+	cfg, peerID := newOfflineFriendTest(t)
+
+	c, err := OpenOffline(cfg)
+	if err != nil {
+		t.Fatalf("OpenOffline failed: %v", err)
+	}
+	if _, err := c.AddFriendOffline(peerID, "hello"); err != nil {
+		t.Fatalf("AddFriendOffline failed: %v", err)
+	}
+	if _, err := c.AddFriendOffline(peerID, "again"); err == nil {
+		t.Error("Expected adding the friend twice to fail")
+	}
+	c.Close()
+
+	c, err = OpenOffline(cfg)
+	if err != nil {
+		t.Fatalf("OpenOffline failed: %v", err)
+	}
+	defer c.Close()
+	if friends := c.FriendList(); len(friends) != 1 {
+		t.Fatalf("Expected the friend to be saved, got %d friends", len(friends))
+	}
+	queued := c.outgoingRequests.List()
+	if len(queued) != 1 || queued[0].ToxID != peerID || queued[0].Message != "hello" {
+		t.Fatalf("Expected the request to be queued, got %+v", queued)
+	}
+
+	c.sendOutgoingRequests()
+	if queued := c.outgoingRequests.List(); len(queued) != 0 {
+		t.Errorf("Expected the queue to be empty once sent, got %+v", queued)
+	}
+	if _, err := os.Stat(cfg.OutgoingRequestsPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", cfg.OutgoingRequestsPath(), err)
+	}
+	if friends := c.tox.GetFriends(); len(friends) != 1 {
+		t.Errorf("Expected the friend to be kept, got %d friends", len(friends))
+	}
+}
+
+// TestAddFriendOfflineQueueFailure tests that a friend whose request cannot
+// be queued is not saved either
+func TestAddFriendOfflineQueueFailure(t *testing.T) {
+	cfg, peerID := newOfflineFriendTest(t)
+
+	c, err := OpenOffline(cfg)
+	if err != nil {
+		t.Fatalf("OpenOffline failed: %v", err)
+	}
+	c.outgoingRequests.path = cfg.ConfigDir + "/missing/" + config.OutgoingRequestsFileName
+	if _, err := c.AddFriendOffline(peerID, "hello"); err == nil {
+		t.Error("Expected AddFriendOffline to fail when the queue cannot be written")
+	}
+	c.Close()
+
+	c, err = OpenOffline(cfg)
+	if err != nil {
+		t.Fatalf("OpenOffline failed: %v", err)
+	}
+	defer c.Close()
+	if friends := c.FriendList(); len(friends) != 0 {
+		t.Errorf("Expected no friend to be saved, got %d", len(friends))
+	}
+}
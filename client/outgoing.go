@@ -0,0 +1,137 @@
+// Package client implements queued outgoing friend request storage for ratox-go
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// OutgoingRequest is a friend request added while ratox-go was not running.
+// toxcore keeps unsent requests only in memory, so it is sent on the next
+// start.
+type OutgoingRequest struct {
+	PublicKey string `json:"public_key"`
+	ToxID     string `json:"tox_id"`
+	Message   string `json:"message"`
+}
+
+// outgoingStore persists friend requests added offline in the profile
+// directory until a running client has sent them
+type outgoingStore struct {
+	path     string
+	requests map[string]*OutgoingRequest
+	mu       sync.Mutex
+}
+
+// newOutgoingStore creates an outgoing request store backed by the given
+// file and loads any requests already saved there
+func newOutgoingStore(path string) (*outgoingStore, error) {
+	qs := &outgoingStore{
+		path:     path,
+		requests: make(map[string]*OutgoingRequest),
+	}
+
+	if err := qs.load(); err != nil {
+		return nil, err
+	}
+
+	return qs, nil
+}
+
+// load reads the store from disk. A missing file is treated as an empty store.
+func (qs *outgoingStore) load() error {
+	data, err := os.ReadFile(qs.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read outgoing requests: %w", err)
+	}
+
+	var requests []*OutgoingRequest
+	if err := json.Unmarshal(data, &requests); err != nil {
+		return fmt.Errorf("failed to parse outgoing requests: %w", err)
+	}
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	for _, req := range requests {
+		qs.requests[req.PublicKey] = req
+	}
+
+	return nil
+}
+
+// saveLocked writes the store to disk, removing the file once it is empty.
+// The caller must hold qs.mu.
+func (qs *outgoingStore) saveLocked() error {
+	if len(qs.requests) == 0 {
+		if err := os.Remove(qs.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove outgoing requests: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(qs.listLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal outgoing requests: %w", err)
+	}
+
+	if err := config.WriteFileAtomic(qs.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write outgoing requests: %w", err)
+	}
+
+	return nil
+}
+
+// Add queues a friend request, replacing any earlier one to the same key
+func (qs *outgoingStore) Add(req OutgoingRequest) error {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	qs.requests[req.PublicKey] = &req
+	return qs.saveLocked()
+}
+
+// Remove drops a queued request once it has been sent
+func (qs *outgoingStore) Remove(publicKey string) error {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	if _, exists := qs.requests[publicKey]; !exists {
+		return nil
+	}
+	delete(qs.requests, publicKey)
+	return qs.saveLocked()
+}
+
+// List returns the queued requests ordered by public key
+func (qs *outgoingStore) List() []OutgoingRequest {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	queued := qs.listLocked()
+	result := make([]OutgoingRequest, len(queued))
+	for i, req := range queued {
+		result[i] = *req
+	}
+	return result
+}
+
+// listLocked returns the queued requests sorted by public key. The caller
+// must hold qs.mu.
+func (qs *outgoingStore) listLocked() []*OutgoingRequest {
+	queued := make([]*OutgoingRequest, 0, len(qs.requests))
+	for _, req := range qs.requests {
+		queued = append(queued, req)
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		return queued[i].PublicKey < queued[j].PublicKey
+	})
+	return queued
+}
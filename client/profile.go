@@ -0,0 +1,206 @@
+// Package client implements profile export and import for ratox-go
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// maxProfileEntrySize bounds each file read from a profile archive
+const maxProfileEntrySize = 64 * 1024 * 1024
+
+// ErrProfileExists is returned when importing over an existing identity
+var ErrProfileExists = errors.New("profile already has an identity")
+
+// profileFiles lists the files that make up a profile, in archive order.
+// Everything else in the profile directory is recreated at startup.
+var profileFiles = []string{
+	config.SaveDataFileName,
+	config.ConfigFileName,
+	config.AliasesFileName,
+	config.LastSeenFileName,
+	config.PendingRequestsFileName,
+	config.OutgoingRequestsFileName,
+}
+
+// ExportProfile writes the profile of cfg to w as a gzipped tar archive,
+// encrypted with passphrase unless it is empty
+func ExportProfile(cfg *config.Config, w io.Writer, passphrase string) error {
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(zw)
+
+	for _, name := range profileFiles {
+		data, err := os.ReadFile(filepath.Join(cfg.ConfigDir, name))
+		if os.IsNotExist(err) && name != config.SaveDataFileName {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	data := archive.Bytes()
+	if passphrase != "" {
+		key, err := newPassKey(passphrase)
+		if err != nil {
+			return err
+		}
+		if data, err = key.encrypt(data); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// IsEncryptedProfile reports whether the archive data needs a passphrase
+func IsEncryptedProfile(data []byte) bool {
+	return IsEncryptedSaveData(data)
+}
+
+// ImportProfile restores the archive data written by ExportProfile into
+// configDir, decrypting it with passphrase if it is encrypted. An existing
+// identity is only replaced if force is set, in which case its save data
+// backups and the profile files the archive lacks are removed first, so
+// nothing of the old identity survives. The archive is checked in full before
+// any file is written. The caller must hold the profile lock.
+func ImportProfile(data []byte, configDir, passphrase string, force bool) error {
+	if IsEncryptedProfile(data) {
+		if passphrase == "" {
+			return errors.New("archive is encrypted and no passphrase was given")
+		}
+		plain, _, err := decryptSaveData(data, passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt archive: %w", err)
+		}
+		data = plain
+	}
+
+	files, err := readProfileArchive(data)
+	if err != nil {
+		return err
+	}
+	if _, ok := files[config.SaveDataFileName]; !ok {
+		return fmt.Errorf("archive has no %s", config.SaveDataFileName)
+	}
+
+	saveFile := filepath.Join(configDir, config.SaveDataFileName)
+	if _, err := os.Stat(saveFile); err == nil && !force {
+		return fmt.Errorf("%s: %w", saveFile, ErrProfileExists)
+	}
+
+	if err := os.MkdirAll(configDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	if err := removeProfileLeftovers(configDir, files); err != nil {
+		return err
+	}
+	for _, name := range profileFiles {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := config.WriteFileAtomic(filepath.Join(configDir, name), content, 0o600); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// removeProfileLeftovers deletes the save data backups and the profile files
+// that are not in the archive, which restoreToxFromBackup or backup rotation
+// would otherwise bring back
+func removeProfileLeftovers(configDir string, files map[string][]byte) error {
+	backups, err := filepath.Glob(filepath.Join(configDir, config.SaveDataFileName+".*"))
+	if err != nil {
+		return fmt.Errorf("failed to list save data backups: %w", err)
+	}
+	for _, backup := range backups {
+		if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", filepath.Base(backup), err)
+		}
+	}
+
+	for _, name := range profileFiles {
+		if _, ok := files[name]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(configDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// readProfileArchive returns the files of a gzipped profile archive by name,
+// rejecting anything that is not a profile file
+func readProfileArchive(data []byte) (map[string][]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not a profile archive: %w", err)
+	}
+	defer zr.Close()
+
+	known := make(map[string]bool, len(profileFiles))
+	for _, name := range profileFiles {
+		known[name] = true
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if !known[header.Name] || header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("archive has unexpected entry %q", header.Name)
+		}
+		if header.Size > maxProfileEntrySize {
+			return nil, fmt.Errorf("archive entry %s is too large", header.Name)
+		}
+
+		content, err := io.ReadAll(io.LimitReader(tr, maxProfileEntrySize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from archive: %w", header.Name, err)
+		}
+		files[header.Name] = content
+	}
+	return files, nil
+}
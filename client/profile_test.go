@@ -0,0 +1,126 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// writeProfile creates a profile directory holding files
+func writeProfile(t *testing.T, files map[string]string) *config.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	return &config.Config{ConfigDir: dir, SaveFile: filepath.Join(dir, config.SaveDataFileName)}
+}
+
+func TestExportImportProfile(t *testing.T) {
+	files := map[string]string{
+		config.SaveDataFileName:        "identity",
+		config.ConfigFileName:          `{"name": "alice"}`,
+		config.AliasesFileName:         `{"abc": "bob"}`,
+		config.PendingRequestsFileName: `[]`,
+	}
+	cfg := writeProfile(t, files)
+	// Runtime files are not part of the profile
+	if err := os.WriteFile(cfg.HTTPTokenPath(), []byte("token"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	for _, passphrase := range []string{"", "correct horse"} {
+		var archive bytes.Buffer
+		if err := ExportProfile(cfg, &archive, passphrase); err != nil {
+			t.Fatalf("ExportProfile failed: %v", err)
+		}
+		if got := IsEncryptedProfile(archive.Bytes()); got != (passphrase != "") {
+			t.Errorf("IsEncryptedProfile = %v with passphrase %q", got, passphrase)
+		}
+
+		target := filepath.Join(t.TempDir(), "profile")
+		if passphrase != "" {
+			if err := ImportProfile(archive.Bytes(), target, "wrong", false); !errors.Is(err, ErrWrongPassphrase) {
+				t.Errorf("Import with the wrong passphrase error = %v, want %v", err, ErrWrongPassphrase)
+			}
+		}
+		if err := ImportProfile(archive.Bytes(), target, passphrase, false); err != nil {
+			t.Fatalf("ImportProfile failed: %v", err)
+		}
+
+		for name, want := range files {
+			if got := readFileOrEmpty(t, filepath.Join(target, name)); got != want {
+				t.Errorf("Imported %s = %q, want %q", name, got, want)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(target, config.HTTPTokenFileName)); !os.IsNotExist(err) {
+			t.Error("Runtime file was exported")
+		}
+
+		if err := ImportProfile(archive.Bytes(), target, passphrase, false); !errors.Is(err, ErrProfileExists) {
+			t.Errorf("Import over an identity error = %v, want %v", err, ErrProfileExists)
+		}
+
+		// A forced import leaves nothing of the identity it replaces
+		stale := []string{config.SaveDataFileName + ".1", config.SaveDataFileName + config.CorruptSaveDataSuffix, config.LastSeenFileName}
+		for _, name := range stale {
+			if err := os.WriteFile(filepath.Join(target, name), []byte("old"), 0o600); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+		}
+		if err := ImportProfile(archive.Bytes(), target, passphrase, true); err != nil {
+			t.Errorf("Forced import failed: %v", err)
+		}
+		for _, name := range stale {
+			if _, err := os.Stat(filepath.Join(target, name)); !os.IsNotExist(err) {
+				t.Errorf("%s of the replaced identity survived a forced import", name)
+			}
+		}
+	}
+}
+
+func TestImportProfileRejectsBadArchives(t *testing.T) {
+	// archive builds a gzipped tar of the named entries
+	archive := func(names ...string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		for _, name := range names {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: 1})
+			tw.Write([]byte("x"))
+		}
+		tw.Close()
+		zw.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not an archive", []byte("plain text")},
+		{"no identity", archive(config.ConfigFileName)},
+		{"path traversal", archive(config.SaveDataFileName, "../escape")},
+		{"unknown file", archive(config.SaveDataFileName, "notes.txt")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := filepath.Join(t.TempDir(), "profile")
+			if err := ImportProfile(tt.data, target, "", false); err == nil {
+				t.Fatal("ImportProfile succeeded")
+			}
+			if _, err := os.Stat(target); !os.IsNotExist(err) {
+				t.Error("Rejected archive created the profile directory")
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Package client implements configuration reloading for ratox-go
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// configWatchInterval is how often config.json is checked for changes
+const configWatchInterval = 2 * time.Second
+
+// configSetting is a config.json field compared on reload. Settings with an
+// apply function change while running; the others need a restart.
+type configSetting struct {
+	name  string
+	field func(cfg *config.Config) interface{}
+	apply func(c *Client, cfg *config.Config) error
+}
+
+// configSettings lists every setting in config.json order
+var configSettings = []configSetting{
+	{name: "debug", field: func(cfg *config.Config) interface{} { return cfg.Debug }},
+	{
+		name:  "name",
+		field: func(cfg *config.Config) interface{} { return cfg.Name },
+		apply: func(c *Client, cfg *config.Config) error {
+			if err := c.tox.SelfSetName(cfg.Name); err != nil {
+				return err
+			}
+			c.updateConfig(func(live *config.Config) { live.Name = cfg.Name })
+			return nil
+		},
+	},
+	{
+		name:  "status_message",
+		field: func(cfg *config.Config) interface{} { return cfg.StatusMessage },
+		apply: func(c *Client, cfg *config.Config) error {
+			if err := c.tox.SelfSetStatusMessage(cfg.StatusMessage); err != nil {
+				return err
+			}
+			c.updateConfig(func(live *config.Config) { live.StatusMessage = cfg.StatusMessage })
+			return nil
+		},
+	},
+	{
+		name:  "auto_accept_files",
+		field: func(cfg *config.Config) interface{} { return cfg.AutoAcceptFiles },
+		apply: func(c *Client, cfg *config.Config) error {
+			c.updateConfig(func(live *config.Config) { live.AutoAcceptFiles = cfg.AutoAcceptFiles })
+			return nil
+		},
+	},
+	{
+		name:  "max_file_size",
+		field: func(cfg *config.Config) interface{} { return cfg.MaxFileSize },
+		apply: func(c *Client, cfg *config.Config) error {
+			c.updateConfig(func(live *config.Config) { live.MaxFileSize = cfg.MaxFileSize })
+			return nil
+		},
+	},
+	{name: "nospam_rotation_hours", field: func(cfg *config.Config) interface{} { return cfg.NospamRotationHours }},
+	{
+		name:  "savedata_backups",
+		field: func(cfg *config.Config) interface{} { return cfg.SaveDataBackups },
+		apply: func(c *Client, cfg *config.Config) error {
+			c.updateConfig(func(live *config.Config) { live.SaveDataBackups = cfg.SaveDataBackups })
+			return nil
+		},
+	},
+	{name: "passphrase_file", field: func(cfg *config.Config) interface{} { return cfg.PassphraseFile }},
+	{
+		name:  "friends_json",
+		field: func(cfg *config.Config) interface{} { return cfg.FriendsJSON },
+		apply: func(c *Client, cfg *config.Config) error {
+			c.updateConfig(func(live *config.Config) { live.FriendsJSON = cfg.FriendsJSON })
+			c.refreshFriendListings()
+			return nil
+		},
+	},
+	{name: "control_socket", field: func(cfg *config.Config) interface{} { return cfg.ControlSocket }},
+	{name: "http", field: func(cfg *config.Config) interface{} { return cfg.HTTP }},
+	{name: "9p", field: func(cfg *config.Config) interface{} { return cfg.NineP }},
+	{name: "frontend", field: func(cfg *config.Config) interface{} { return cfg.Frontend }},
+	{name: "mount_point", field: func(cfg *config.Config) interface{} { return cfg.MountPoint }},
+	{
+		name:  "bootstrap_nodes",
+		field: func(cfg *config.Config) interface{} { return cfg.BootstrapNodes },
+		apply: func(c *Client, cfg *config.Config) error {
+			c.updateConfig(func(live *config.Config) { live.BootstrapNodes = cfg.BootstrapNodes })
+			c.bootstrap(true)
+			return nil
+		},
+	},
+	{name: "transport", field: func(cfg *config.Config) interface{} { return cfg.Transport }},
+	{name: "bootstrap_server", field: func(cfg *config.Config) interface{} { return cfg.BootstrapServer }},
+}
+
+// configReloader tracks config.json and the outcome of the last reload
+type configReloader struct {
+	mu sync.Mutex
+	// initial is the configuration the client started with, which settings
+	// that need a restart are compared against
+	initial  *config.Config
+	modTime  time.Time
+	size     int64
+	reloaded time.Time
+	err      error
+	applied  []string
+	restart  []string
+}
+
+// newConfigReloader records the state of the configuration file of cfg
+func newConfigReloader(cfg *config.Config) *configReloader {
+	initial := *cfg
+	r := &configReloader{initial: &initial}
+	r.fileChanged(cfg.FilePath())
+
+	return r
+}
+
+// fileChanged reports whether the file at path was modified since the last
+// call. A missing file is not a change.
+func (r *configReloader) fileChanged(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false
+	}
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return true
+}
+
+// liveConfig returns a copy of the configuration that is consistent with
+// concurrent reloads
+func (c *Client) liveConfig() config.Config {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
+	return *c.config
+}
+
+// updateConfig changes settings of the running configuration
+func (c *Client) updateConfig(update func(cfg *config.Config)) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	update(c.config)
+}
+
+// saveConfigSetting changes a setting of the running configuration and
+// writes only that setting to config.json, keeping edits there that are
+// still waiting for a reload or restart
+func (c *Client) saveConfigSetting(update func(cfg *config.Config)) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	update(c.config)
+
+	cfg, err := config.Read(c.config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	update(cfg)
+	return cfg.Save()
+}
+
+// ReloadConfig re-reads config.json, applies the settings that can change
+// while running and reports the outcome in client/config_status
+func (c *Client) ReloadConfig() error {
+	err := c.reloadConfig()
+
+	if err := c.frontends.ConfigChanged(); err != nil {
+		c.logf("Failed to update config status: %v", err)
+	}
+	return err
+}
+
+// reloadConfig applies the changed settings, recording the result
+func (c *Client) reloadConfig() error {
+	r := c.reloader
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reloaded = time.Now()
+	r.applied = nil
+
+	// Environment and flag overrides keep taking precedence over the file
+	cfg, err := config.Read(c.config.ConfigDir)
+	if err == nil {
+		err = cfg.Apply(c.config.Overrides())
+	}
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		r.err = err
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+
+	live := c.liveConfig()
+	var errs []error
+	r.restart = nil
+	for _, setting := range configSettings {
+		if setting.apply == nil {
+			if !reflect.DeepEqual(setting.field(r.initial), setting.field(cfg)) {
+				r.restart = append(r.restart, setting.name)
+			}
+			continue
+		}
+
+		if reflect.DeepEqual(setting.field(&live), setting.field(cfg)) {
+			continue
+		}
+		if err := setting.apply(c, cfg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", setting.name, err))
+			continue
+		}
+		r.applied = append(r.applied, setting.name)
+	}
+
+	r.err = errors.Join(errs...)
+	if r.err != nil {
+		return fmt.Errorf("failed to apply config: %w", r.err)
+	}
+
+	c.logf("Reloaded configuration from %s", c.config.FilePath())
+	if len(r.restart) > 0 {
+		c.logf("Changed settings need a restart: %s", strings.Join(r.restart, " "))
+	}
+	return nil
+}
+
+// watchConfig reloads the configuration whenever config.json changes
+func (c *Client) watchConfig() {
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.reloader.fileChanged(c.config.FilePath()) {
+				continue
+			}
+			if err := c.ReloadConfig(); err != nil {
+				c.logf("Config reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// configStatusText describes the last reload for client/config_status
+func (c *Client) configStatusText() string {
+	r := c.reloader
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reloaded, result := "never", "ok"
+	if !r.reloaded.IsZero() {
+		reloaded = r.reloaded.Format(time.RFC3339)
+	}
+	if r.err != nil {
+		result = strings.ReplaceAll(r.err.Error(), "\n", "; ")
+	}
+
+	return fmt.Sprintf("reloaded: %s\nresult: %s\napplied: %s\nrestart_required: %s\n",
+		reloaded, result, settingList(r.applied), settingList(r.restart))
+}
+
+// settingList joins setting names, or returns "none"
+func settingList(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// newReloadTestClient returns a test client whose configuration was loaded
+// from its profile directory
+func newReloadTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	c := newControlTestServer(t).client
+	cfg, err := config.Load(c.config.ConfigDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := cfg.Persist(); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	c.config = cfg
+	c.reloader = newConfigReloader(cfg)
+	return c
+}
+
+// editConfig rewrites config.json with the changes made by edit
+func editConfig(t *testing.T, dir string, edit func(cfg *config.Config)) {
+	t.Helper()
+
+	cfg, err := config.Read(dir)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	edit(cfg)
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	c := newReloadTestClient(t)
+	dir := c.config.ConfigDir
+
+	if status := c.configStatusText(); !strings.HasPrefix(status, "reloaded: never\n") {
+		t.Errorf("Status before any reload = %q", status)
+	}
+
+	editConfig(t, dir, func(cfg *config.Config) {
+		cfg.AutoAcceptFiles = true
+		cfg.MaxFileSize = 42
+		cfg.Transport.TCPEnabled = true
+		cfg.Frontend = config.FrontendFUSE
+	})
+	if err := c.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig failed: %v", err)
+	}
+
+	live := c.liveConfig()
+	if !live.AutoAcceptFiles || live.MaxFileSize != 42 {
+		t.Errorf("Live settings not applied: auto_accept_files=%v max_file_size=%d", live.AutoAcceptFiles, live.MaxFileSize)
+	}
+	if live.Transport.TCPEnabled || live.Frontend != config.FrontendFIFO {
+		t.Error("Settings that need a restart were applied")
+	}
+
+	status := c.configStatusText()
+	for _, want := range []string{
+		"result: ok\n",
+		"applied: auto_accept_files max_file_size\n",
+		"restart_required: frontend transport\n",
+	} {
+		if !strings.Contains(status, want) {
+			t.Errorf("Status = %q, want it to contain %q", status, want)
+		}
+	}
+
+	// An invalid file is rejected and the running settings are kept
+	editConfig(t, dir, func(cfg *config.Config) { cfg.MaxFileSize = -1 })
+	if err := c.ReloadConfig(); err == nil {
+		t.Error("Expected an error reloading an invalid config")
+	}
+	if got := c.liveConfig().MaxFileSize; got != 42 {
+		t.Errorf("MaxFileSize after a rejected reload = %d, want 42", got)
+	}
+	if status := c.configStatusText(); !strings.Contains(status, "result: invalid configuration: max_file_size: must not be negative, got -1\n") {
+		t.Errorf("Status after a rejected reload = %q", status)
+	}
+}
+
+// TestSaveConfigSetting tests that a setting changed at runtime is saved
+// without losing edits waiting for a restart
+func TestSaveConfigSetting(t *testing.T) {
+	c := newReloadTestClient(t)
+	dir := c.config.ConfigDir
+
+	editConfig(t, dir, func(cfg *config.Config) { cfg.Transport.TCPEnabled = true })
+	if err := c.saveConfigSetting(func(cfg *config.Config) { cfg.Name = "alice" }); err != nil {
+		t.Fatalf("saveConfigSetting failed: %v", err)
+	}
+
+	if got := c.liveConfig().Name; got != "alice" {
+		t.Errorf("Live name = %q, want %q", got, "alice")
+	}
+	saved, err := config.Read(dir)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if saved.Name != "alice" || !saved.Transport.TCPEnabled {
+		t.Errorf("Saved name = %q, tcp_enabled = %v; want %q and the pending edit kept", saved.Name, saved.Transport.TCPEnabled, "alice")
+	}
+}
+
+func TestConfigFileChanged(t *testing.T) {
+	c := newReloadTestClient(t)
+	path := c.config.FilePath()
+
+	if c.reloader.fileChanged(path) {
+		t.Error("Unmodified file reported as changed")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if !c.reloader.fileChanged(path) {
+		t.Error("Modified file not reported as changed")
+	}
+	if c.reloader.fileChanged(path) {
+		t.Error("Change reported twice")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if c.reloader.fileChanged(path) {
+		t.Error("Missing file reported as changed")
+	}
+}
+
+func TestReloadKeepsOverrides(t *testing.T) {
+	c := newReloadTestClient(t)
+	if err := c.config.Apply([]config.Override{{Path: "max_file_size", Value: "7", Source: config.SourceEnv}}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	editConfig(t, c.config.ConfigDir, func(cfg *config.Config) {
+		cfg.MaxFileSize = 99
+		cfg.AutoAcceptFiles = true
+	})
+	if err := c.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig failed: %v", err)
+	}
+
+	live := c.liveConfig()
+	if live.MaxFileSize != 7 {
+		t.Errorf("MaxFileSize = %d, want the override 7", live.MaxFileSize)
+	}
+	if !live.AutoAcceptFiles {
+		t.Error("AutoAcceptFiles from the file was not applied")
+	}
+}
@@ -0,0 +1,147 @@
+// Package client implements pending friend request storage for ratox-go
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/go-ratox/config"
+)
+
+// PendingRequest is a friend request that has been received but not yet
+// accepted or rejected
+type PendingRequest struct {
+	PublicKey string    `json:"public_key"`
+	Message   string    `json:"message"`
+	Received  time.Time `json:"received"`
+}
+
+// requestStore persists pending friend requests in the profile directory so
+// they survive restarts and are not lost when no reader is attached to
+// request_out
+type requestStore struct {
+	path     string
+	requests map[string]*PendingRequest
+	mu       sync.RWMutex
+}
+
+// newRequestStore creates a request store backed by the given file and loads
+// any requests already saved there
+func newRequestStore(path string) (*requestStore, error) {
+	rs := &requestStore{
+		path:     path,
+		requests: make(map[string]*PendingRequest),
+	}
+
+	if err := rs.load(); err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// load reads the store from disk. A missing file is treated as an empty store.
+func (rs *requestStore) load() error {
+	data, err := os.ReadFile(rs.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read pending requests: %w", err)
+	}
+
+	var requests []*PendingRequest
+	if err := json.Unmarshal(data, &requests); err != nil {
+		return fmt.Errorf("failed to parse pending requests: %w", err)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for _, req := range requests {
+		rs.requests[req.PublicKey] = req
+	}
+
+	return nil
+}
+
+// saveLocked writes the store to disk. The caller must hold rs.mu.
+func (rs *requestStore) saveLocked() error {
+	data, err := json.MarshalIndent(rs.listLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending requests: %w", err)
+	}
+
+	if err := config.WriteFileAtomic(rs.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write pending requests: %w", err)
+	}
+
+	return nil
+}
+
+// Add records a friend request, replacing any earlier request from the same key
+func (rs *requestStore) Add(publicKey, message string, received time.Time) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.requests[publicKey] = &PendingRequest{
+		PublicKey: publicKey,
+		Message:   message,
+		Received:  received,
+	}
+
+	return rs.saveLocked()
+}
+
+// Remove deletes a pending request and reports whether it existed
+func (rs *requestStore) Remove(publicKey string) (bool, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if _, exists := rs.requests[publicKey]; !exists {
+		return false, nil
+	}
+	delete(rs.requests, publicKey)
+
+	return true, rs.saveLocked()
+}
+
+// Has reports whether a request from the given public key is pending
+func (rs *requestStore) Has(publicKey string) bool {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	_, exists := rs.requests[publicKey]
+	return exists
+}
+
+// List returns all pending requests ordered by the time they were received
+func (rs *requestStore) List() []PendingRequest {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	pending := rs.listLocked()
+	result := make([]PendingRequest, len(pending))
+	for i, req := range pending {
+		result[i] = *req
+	}
+	return result
+}
+
+// listLocked returns the pending requests sorted by receive time. The caller
+// must hold rs.mu.
+func (rs *requestStore) listLocked() []*PendingRequest {
+	pending := make([]*PendingRequest, 0, len(rs.requests))
+	for _, req := range rs.requests {
+		pending = append(pending, req)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Received.Equal(pending[j].Received) {
+			return pending[i].PublicKey < pending[j].PublicKey
+		}
+		return pending[i].Received.Before(pending[j].Received)
+	})
+	return pending
+}
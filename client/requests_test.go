@@ -0,0 +1,169 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRequestStorePersistence tests that pending requests survive a reload
+func TestRequestStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.json")
+
+	rs, err := newRequestStore(path)
+	if err != nil {
+		t.Fatalf("newRequestStore failed: %v", err)
+	}
+
+	first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	if err := rs.Add("bbbb", "second request", second); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := rs.Add("aaaa", "first request", first); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	reloaded, err := newRequestStore(path)
+	if err != nil {
+		t.Fatalf("reloading store failed: %v", err)
+	}
+
+	pending := reloaded.List()
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending requests, got %d", len(pending))
+	}
+	if pending[0].PublicKey != "aaaa" || pending[0].Message != "first request" {
+		t.Errorf("Expected oldest request first, got %+v", pending[0])
+	}
+	if !pending[1].Received.Equal(second) {
+		t.Errorf("Expected received time %v, got %v", second, pending[1].Received)
+	}
+}
+
+// TestRequestStoreRemove tests removing pending requests
+func TestRequestStoreRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.json")
+
+	rs, err := newRequestStore(path)
+	if err != nil {
+		t.Fatalf("newRequestStore failed: %v", err)
+	}
+
+	if err := rs.Add("aaaa", "hello", time.Now()); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if !rs.Has("aaaa") {
+		t.Error("Expected request to be pending")
+	}
+
+	removed, err := rs.Remove("aaaa")
+	if err != nil || !removed {
+		t.Fatalf("Expected request to be removed, got removed=%v err=%v", removed, err)
+	}
+	if rs.Has("aaaa") {
+		t.Error("Expected request to no longer be pending")
+	}
+
+	removed, err = rs.Remove("aaaa")
+	if err != nil || removed {
+		t.Errorf("Expected second removal to be a no-op, got removed=%v err=%v", removed, err)
+	}
+}
+
+// TestRequestStoreCorruptFile tests that an unparsable store is reported
+func TestRequestStoreCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := newRequestStore(path); err == nil {
+		t.Error("Expected error for corrupt request store")
+	}
+}
+
+// TestParseRequestKey tests public key and Tox ID parsing for request FIFOs
+func TestParseRequestKey(t *testing.T) {
+	publicKeyHex := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name        string
+		input       string
+		expectError bool
+	}{
+		{"64-char public key", publicKeyHex, false},
+		{"76-char Tox ID", publicKeyHex + strings.Repeat("0", 12), false},
+		{"too short", publicKeyHex[:63], true},
+		{"non-hex", strings.Repeat("zz", 32), true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publicKey, err := parseRequestKey(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if publicKey[0] != 0xab || publicKey[31] != 0xab {
+				t.Errorf("Unexpected public key: %x", publicKey)
+			}
+		})
+	}
+}
+
+// TestParseRequestSend tests splitting request_send input into Tox ID and message
+func TestParseRequestSend(t *testing.T) {
+	toxID := strings.Repeat("AB", 38)
+
+	tests := []struct {
+		name            string
+		input           string
+		expectedToxID   string
+		expectedMessage string
+	}{
+		{"ID and message", toxID + " hello there", toxID, "hello there"},
+		{"ID only", toxID, toxID, DefaultFriendRequestMessage},
+		{"extra whitespace", "  " + toxID + "   hi  \n", toxID, "hi"},
+		{"empty", "", "", DefaultFriendRequestMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotID, gotMessage := parseRequestSend(tt.input)
+			if gotID != tt.expectedToxID {
+				t.Errorf("Expected Tox ID %q, got %q", tt.expectedToxID, gotID)
+			}
+			if gotMessage != tt.expectedMessage {
+				t.Errorf("Expected message %q, got %q", tt.expectedMessage, gotMessage)
+			}
+		})
+	}
+}
+
+// TestRequestsPendingText tests that messages are quoted onto a single line
+func TestRequestsPendingText(t *testing.T) {
+	rs, err := newRequestStore(filepath.Join(t.TempDir(), "requests.json"))
+	if err != nil {
+		t.Fatalf("newRequestStore failed: %v", err)
+	}
+	received := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := rs.Add("aaaa", "hi\nbbbb 2024-01-01T12:00:00Z forged", received); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	c := &Client{pendingRequests: rs}
+	want := `aaaa 2024-01-01T12:00:00Z "hi\nbbbb 2024-01-01T12:00:00Z forged"` + "\n"
+	if got := c.requestsPendingText(); got != want {
+		t.Errorf("requestsPendingText() = %q, want %q", got, want)
+	}
+}
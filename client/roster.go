@@ -0,0 +1,125 @@
+// Package client implements the global friends roster for ratox-go
+package client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FriendInfo is a printable summary of a friend, as listed in the roster
+type FriendInfo struct {
+	FriendNumber  uint32 `json:"friend_number"`
+	PublicKey     string `json:"public_key"`
+	Alias         string `json:"alias,omitempty"`
+	Name          string `json:"name"`
+	StatusMessage string `json:"status_message"`
+	UserStatus    string `json:"user_status"`
+	Connection    string `json:"connection"`
+	LastSeen      string `json:"last_seen"`
+}
+
+// FriendList returns a summary of every friend ordered by friend number
+func (c *Client) FriendList() []FriendInfo {
+	friends := c.Friends()
+	list := make([]FriendInfo, 0, len(friends))
+
+	for i := range friends {
+		friend := &friends[i]
+		if friend.PublicKey == ([32]byte{}) {
+			continue
+		}
+
+		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
+		list = append(list, FriendInfo{
+			FriendNumber:  friend.ID,
+			PublicKey:     friendIDStr,
+			Alias:         c.FriendAlias(friendIDStr),
+			Name:          friend.Name,
+			StatusMessage: friend.StatusMessage,
+			UserStatus:    userStatusString(friend.Status),
+			Connection:    connectionTypeString(friend.Connection),
+			LastSeen:      lastSeenString(friend),
+		})
+	}
+
+	return list
+}
+
+// refreshFriendListings rewrites the global friend listings after a friend is
+// added, removed, renamed or changes status
+func (c *Client) refreshFriendListings() {
+	if err := c.frontends.FriendListChanged(); err != nil {
+		c.logf("Failed to update friend listings: %v", err)
+	}
+}
+
+// FriendListChanged rewrites the friends roster and the last_seen listing
+func (fm *FIFOManager) FriendListChanged() error {
+	var errs []error
+	if err := fm.writeFriendsRoster(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := fm.writeLastSeenFile(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// writeFriendsRoster writes the friends file, one friend per line as
+// "<public_key> <alias> <user_status> <connection> <last_seen> <name>" with
+// "-" standing in for a missing alias, and optionally a friends.json sibling
+func (fm *FIFOManager) writeFriendsRoster() error {
+	list := fm.client.FriendList()
+
+	rosterPath := fm.config.GlobalFIFOPath(FriendsList)
+	if err := os.WriteFile(rosterPath, []byte(FriendsRosterText(list)), 0o600); err != nil {
+		return fmt.Errorf("failed to write friends file: %w", err)
+	}
+
+	jsonPath := fm.config.GlobalFIFOPath(FriendsJSON)
+	if !fm.client.liveConfig().FriendsJSON {
+		if err := os.Remove(jsonPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove friends JSON file: %w", err)
+		}
+		return nil
+	}
+
+	data, err := friendsJSONText(list)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(jsonPath, []byte(data), 0o600); err != nil {
+		return fmt.Errorf("failed to write friends JSON file: %w", err)
+	}
+
+	return nil
+}
+
+// FriendsRosterText formats the roster one friend per line, as in
+// client/friends. The name is quoted so it cannot break the line.
+func FriendsRosterText(list []FriendInfo) string {
+	var sb strings.Builder
+	for _, info := range list {
+		alias := info.Alias
+		if alias == "" {
+			alias = AliasClear
+		}
+		fmt.Fprintf(&sb, "%s %s %s %s %s %s\n",
+			info.PublicKey, alias, info.UserStatus, info.Connection, info.LastSeen, strconv.Quote(info.Name))
+	}
+	return sb.String()
+}
+
+// friendsJSONText formats the roster as an indented JSON array
+func friendsJSONText(list []FriendInfo) (string, error) {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal friends: %w", err)
+	}
+	return string(data) + "\n", nil
+}
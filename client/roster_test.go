@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opd-ai/go-ratox/config"
+	"github.com/opd-ai/toxcore"
+)
+
+// newRosterTestManager builds a FIFO manager backed by a client with two
+// friends, one of them aliased
+func newRosterTestManager(t *testing.T, friendsJSON bool) (*FIFOManager, string, string) {
+	t.Helper()
+
+	cfg := &config.Config{ConfigDir: t.TempDir(), FriendsJSON: friendsJSON}
+	if err := os.MkdirAll(filepath.Join(cfg.ConfigDir, "client"), DirPerm); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	aliases, err := newAliasStore(cfg.AliasesPath(), t.Logf)
+	if err != nil {
+		t.Fatalf("newAliasStore failed: %v", err)
+	}
+
+	var keyA, keyB [32]byte
+	keyA[0], keyB[0] = 0xaa, 0xbb
+	hexA, hexB := hex.EncodeToString(keyA[:]), hex.EncodeToString(keyB[:])
+	if _, err := aliases.Set(hexA, "alice"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	c := &Client{
+		config:  cfg,
+		aliases: aliases,
+		friends: map[uint32]*Friend{
+			1: {ID: 1, PublicKey: keyA, Name: "Alice", Status: 1, Online: true, Connection: toxcore.ConnectionTCP},
+			2: {ID: 2, PublicKey: keyB, Name: "Bob\nBuilder"},
+			3: {ID: 3, Name: "pending key"},
+		},
+	}
+
+	return &FIFOManager{config: cfg, client: c}, hexA, hexB
+}
+
+// TestWriteFriendsRoster tests the text roster and its JSON sibling
+func TestWriteFriendsRoster(t *testing.T) {
+	fm, hexA, hexB := newRosterTestManager(t, true)
+
+	if err := fm.writeFriendsRoster(); err != nil {
+		t.Fatalf("writeFriendsRoster failed: %v", err)
+	}
+
+	data, err := os.ReadFile(fm.config.GlobalFIFOPath(FriendsList))
+	if err != nil {
+		t.Fatalf("Failed to read roster: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 roster lines (friends without a key are skipped), got %d: %q", len(lines), lines)
+	}
+	if want := hexA + ` alice away tcp online "Alice"`; lines[0] != want {
+		t.Errorf("Expected line %q, got %q", want, lines[0])
+	}
+	if want := hexB + ` - online offline never "Bob\nBuilder"`; lines[1] != want {
+		t.Errorf("Expected line %q, got %q", want, lines[1])
+	}
+
+	jsonData, err := os.ReadFile(fm.config.GlobalFIFOPath(FriendsJSON))
+	if err != nil {
+		t.Fatalf("Failed to read friends.json: %v", err)
+	}
+	var list []FriendInfo
+	if err := json.Unmarshal(jsonData, &list); err != nil {
+		t.Fatalf("Failed to parse friends.json: %v", err)
+	}
+	if len(list) != 2 || list[0].Alias != "alice" || list[1].Name != "Bob\nBuilder" {
+		t.Errorf("Unexpected friends.json contents: %+v", list)
+	}
+}
+
+// TestWriteFriendsRosterWithoutJSON tests that friends.json is optional
+func TestWriteFriendsRosterWithoutJSON(t *testing.T) {
+	fm, _, _ := newRosterTestManager(t, false)
+
+	jsonPath := fm.config.GlobalFIFOPath(FriendsJSON)
+	if err := os.WriteFile(jsonPath, []byte("[]"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := fm.writeFriendsRoster(); err != nil {
+		t.Fatalf("writeFriendsRoster failed: %v", err)
+	}
+
+	if _, err := os.Stat(jsonPath); !os.IsNotExist(err) {
+		t.Error("Expected stale friends.json to be removed when disabled")
+	}
+}
+
+// TestLastSeenText tests that the listing quotes names onto a single line
+func TestLastSeenText(t *testing.T) {
+	fm, hexA, hexB := newRosterTestManager(t, true)
+
+	want := hexB + ` never "Bob\nBuilder"` + "\n" + hexA + ` online "Alice"` + "\n"
+	if got := fm.client.lastSeenText(); got != want {
+		t.Errorf("lastSeenText() = %q, want %q", got, want)
+	}
+}
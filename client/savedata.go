@@ -0,0 +1,116 @@
+// Package client implements crash-safe storage of the Tox save file for ratox-go
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/opd-ai/go-ratox/config"
+	"github.com/opd-ai/toxcore"
+)
+
+// writeSaveData atomically replaces the save file with data, encrypted if
+// the profile has a passphrase, first rotating the previous version into the
+// backups. Unchanged data is not rewritten, so the backups hold distinct
+// versions.
+func (c *Client) writeSaveData(data []byte) error {
+	cfg := c.liveConfig()
+
+	current, err := os.ReadFile(cfg.SaveFile)
+	if err == nil && c.sameSaveData(current, data) {
+		return nil
+	}
+	sealed, sealErr := c.sealSaveData(data)
+	if sealErr != nil {
+		return sealErr
+	}
+	if err == nil && cfg.SaveDataBackups > 0 {
+		// A failed rotation must not stop the identity from being saved
+		if err := rotateSaveDataBackups(&cfg); err != nil {
+			c.logf("Failed to back up Tox data: %v", err)
+		}
+	}
+
+	return config.WriteFileAtomic(cfg.SaveFile, sealed, 0o600)
+}
+
+// sameSaveData reports whether the save file content current holds data
+func (c *Client) sameSaveData(current, data []byte) bool {
+	if c.saveKey == nil {
+		return bytes.Equal(current, data)
+	}
+	plain, err := c.saveKey.decrypt(current)
+	return err == nil && bytes.Equal(plain, data)
+}
+
+// rotateSaveDataBackups shifts ratox.tox.1..N-1 up by one, dropping the
+// oldest, and makes the current save file the newest backup
+func rotateSaveDataBackups(cfg *config.Config) error {
+	for i := cfg.SaveDataBackups - 1; i >= 1; i-- {
+		err := os.Rename(cfg.SaveDataBackupPath(i), cfg.SaveDataBackupPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate backup %d: %w", i, err)
+		}
+	}
+
+	newest := cfg.SaveDataBackupPath(1)
+	if err := os.Remove(newest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove backup: %w", err)
+	}
+
+	// Linking keeps the save file in place until the new version replaces it;
+	// copy where the filesystem has no hard links
+	if err := os.Link(cfg.SaveFile, newest); err != nil {
+		data, err := os.ReadFile(cfg.SaveFile)
+		if err != nil {
+			return fmt.Errorf("failed to read save file: %w", err)
+		}
+		if err := config.WriteFileAtomic(newest, data, 0o600); err != nil {
+			return fmt.Errorf("failed to copy save file: %w", err)
+		}
+	}
+
+	return config.SyncDir(filepath.Dir(cfg.SaveFile))
+}
+
+// restoreToxFromBackup loads the newest backup Tox accepts after the save file
+// failed to load with cause. The damaged save file is set aside as
+// ratox.tox.corrupt and replaced by the backup, so it never enters the
+// rotation.
+func (c *Client) restoreToxFromBackup(options *toxcore.Options, cause error) error {
+	for i := 1; i <= c.config.SaveDataBackups; i++ {
+		path := c.config.SaveDataBackupPath(i)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		plain, key, err := c.openSaveData(data)
+		if err != nil {
+			c.logf("Skipping unusable Tox data backup %s: %v", path, err)
+			continue
+		}
+		tox, err := toxcore.NewFromSavedata(options, plain)
+		if err != nil {
+			c.logf("Skipping unusable Tox data backup %s: %v", path, err)
+			continue
+		}
+
+		c.logf("Warning: %s could not be loaded (%v), restored identity from %s", c.config.SaveFile, cause, path)
+		corrupt := c.config.SaveFile + config.CorruptSaveDataSuffix
+		if err := os.Rename(c.config.SaveFile, corrupt); err != nil && !os.IsNotExist(err) {
+			c.logf("Failed to set aside damaged Tox data: %v", err)
+		}
+		if err := config.WriteFileAtomic(c.config.SaveFile, data, 0o600); err != nil {
+			c.logf("Failed to restore %s from backup: %v", c.config.SaveFile, err)
+		}
+
+		c.tox = tox
+		c.saveKey = key
+		return nil
+	}
+
+	return cause
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"os"
+	"testing"
+
+	"github.com/opd-ai/go-ratox/config"
+	"github.com/opd-ai/toxcore"
+)
+
+// newSaveDataTestClient returns a client saving into a temporary directory
+func newSaveDataTestClient(t *testing.T, backups int) *Client {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		ConfigDir:       dir,
+		SaveFile:        dir + "/" + config.SaveDataFileName,
+		SaveDataBackups: backups,
+	}
+	return &Client{config: cfg}
+}
+
+// readFileOrEmpty returns the content of path, or "" if it does not exist
+func readFileOrEmpty(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestWriteSaveDataRotatesBackups(t *testing.T) {
+	c := newSaveDataTestClient(t, 2)
+
+	for _, version := range []string{"v1", "v2", "v2", "v3", "v4"} {
+		if err := c.writeSaveData([]byte(version)); err != nil {
+			t.Fatalf("writeSaveData(%s) failed: %v", version, err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{c.config.SaveFile, "v4"},
+		{c.config.SaveDataBackupPath(1), "v3"},
+		{c.config.SaveDataBackupPath(2), "v2"},
+		{c.config.SaveDataBackupPath(3), ""},
+	}
+	for _, tt := range tests {
+		if got := readFileOrEmpty(t, tt.path); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWriteSaveDataWithoutBackups(t *testing.T) {
+	c := newSaveDataTestClient(t, 0)
+
+	for _, version := range []string{"v1", "v2"} {
+		if err := c.writeSaveData([]byte(version)); err != nil {
+			t.Fatalf("writeSaveData(%s) failed: %v", version, err)
+		}
+	}
+
+	if got := readFileOrEmpty(t, c.config.SaveFile); got != "v2" {
+		t.Errorf("Save file = %q, want %q", got, "v2")
+	}
+	if got := readFileOrEmpty(t, c.config.SaveDataBackupPath(1)); got != "" {
+		t.Errorf("Backup written with savedata_backups 0: %q", got)
+	}
+}
+
+func TestCreateToxInstanceFallsBackToBackup(t *testing.T) {
+	c := newSaveDataTestClient(t, 2)
+
+	tox, err := toxcore.New(toxcore.NewOptionsForTesting())
+	if err != nil {
+		t.Fatalf("toxcore.New failed: %v", err)
+	}
+	saveData := tox.GetSavedata()
+	wantID := tox.SelfGetAddress()
+	tox.Kill()
+
+	if err := os.WriteFile(c.config.SaveDataBackupPath(1), []byte("damaged backup"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.WriteFile(c.config.SaveDataBackupPath(2), saveData, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.WriteFile(c.config.SaveFile, []byte("damaged"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := c.createToxInstance(toxcore.NewOptionsForTesting()); err != nil {
+		t.Fatalf("createToxInstance failed: %v", err)
+	}
+	defer c.tox.Kill()
+
+	if got := c.tox.SelfGetAddress(); got != wantID {
+		t.Errorf("Restored Tox ID = %s, want %s", got, wantID)
+	}
+	if got := readFileOrEmpty(t, c.config.SaveFile+config.CorruptSaveDataSuffix); got != "damaged" {
+		t.Errorf("Damaged save file set aside as %q, want %q", got, "damaged")
+	}
+	if got := readFileOrEmpty(t, c.config.SaveFile); got != string(saveData) {
+		t.Error("Save file was not replaced by the restored backup")
+	}
+}
+
+func TestCreateToxInstanceWithoutValidBackup(t *testing.T) {
+	c := newSaveDataTestClient(t, 1)
+
+	if err := os.WriteFile(c.config.SaveFile, []byte("damaged"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := c.createToxInstance(toxcore.NewOptionsForTesting()); err == nil {
+		c.tox.Kill()
+		t.Fatal("createToxInstance succeeded with damaged save data and no backup")
+	}
+	if got := readFileOrEmpty(t, c.config.SaveFile); got != "damaged" {
+		t.Errorf("Save file changed to %q without a backup to restore", got)
+	}
+}
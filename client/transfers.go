@@ -0,0 +1,185 @@
+// Package client implements file transfer control for ratox-go
+package client
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Transfer directions reported by Transfers
+const (
+	TransferIncoming = "incoming"
+	TransferOutgoing = "outgoing"
+)
+
+// TransferInfo is a summary of an active file transfer
+type TransferInfo struct {
+	FriendNumber uint32 `json:"friend_number"`
+	FileNumber   uint32 `json:"file_number"`
+	Direction    string `json:"direction"`
+	Filename     string `json:"filename"`
+	Path         string `json:"path"`
+	Size         uint64 `json:"size"`
+	Transferred  uint64 `json:"transferred"`
+}
+
+// SendFile offers the file at filePath to a friend and returns the file number
+// of the new transfer
+func (c *Client) SendFile(friendNum uint32, filePath string) (uint32, error) {
+	fileInfo, fileSize, err := c.validateFileForSending(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	file, transferID, err := c.initiateFileSend(friendNum, filePath, fileSize)
+	if err != nil {
+		return 0, err
+	}
+
+	c.trackOutgoingTransfer(friendNum, transferID, file, filePath, fileInfo.Name(), fileSize)
+	c.logf("File transfer initiated: %s (%d bytes) to friend %d, transfer ID: %d", fileInfo.Name(), fileSize, friendNum, transferID)
+
+	return transferID, nil
+}
+
+func (c *Client) validateFileForSending(filePath string) (os.FileInfo, uint64, error) {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("file not found or inaccessible: %w", err)
+	}
+
+	if fileInfo.IsDir() {
+		return nil, 0, fmt.Errorf("cannot send directory: %s", filePath)
+	}
+
+	rawSize := fileInfo.Size()
+	if rawSize < 0 {
+		return nil, 0, fmt.Errorf("invalid file size (negative): %d", rawSize)
+	}
+	if maxSize := c.liveConfig().MaxFileSize; maxSize > 0 && rawSize > maxSize {
+		return nil, 0, fmt.Errorf("file too large (%d bytes), maximum allowed: %d", rawSize, maxSize)
+	}
+
+	return fileInfo, uint64(rawSize), nil
+}
+
+func (c *Client) initiateFileSend(friendNum uint32, filePath string, fileSize uint64) (*os.File, uint32, error) {
+	fileID := sha256.Sum256([]byte(filePath))
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	filename := filepath.Base(filePath)
+	transferID, err := c.tox.FileSend(friendNum, 0, fileSize, fileID, filename)
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to initiate file transfer: %w", err)
+	}
+
+	return file, transferID, nil
+}
+
+func (c *Client) trackOutgoingTransfer(friendNum, transferID uint32, file *os.File, filePath, filename string, fileSize uint64) {
+	transferKey := fmt.Sprintf("%d:%d", friendNum, transferID)
+	c.transfersMu.Lock()
+	defer c.transfersMu.Unlock()
+
+	c.outgoingTransfers[transferKey] = &outgoingTransfer{
+		File:         file,
+		FilePath:     filePath,
+		Filename:     filename,
+		FileSize:     fileSize,
+		Sent:         0,
+		LastActivity: time.Now(),
+	}
+}
+
+// parseTransferKey splits a "<friend>:<file>" transfer key
+func parseTransferKey(key string) (uint32, uint32, bool) {
+	var friendNum, fileNumber uint32
+	if _, err := fmt.Sscanf(key, "%d:%d", &friendNum, &fileNumber); err != nil {
+		return 0, 0, false
+	}
+	return friendNum, fileNumber, true
+}
+
+// transferFileNumber returns the file number part of a transfer key
+func transferFileNumber(key string) uint32 {
+	_, fileNumber, _ := parseTransferKey(key)
+	return fileNumber
+}
+
+// Transfers returns a summary of every active file transfer, ordered by
+// friend number and file number
+func (c *Client) Transfers() []TransferInfo {
+	c.transfersMu.RLock()
+	list := make([]TransferInfo, 0, len(c.incomingTransfers)+len(c.outgoingTransfers))
+	for key, transfer := range c.incomingTransfers {
+		info := TransferInfo{
+			Direction:   TransferIncoming,
+			Filename:    transfer.Filename,
+			Path:        transfer.FilePath,
+			Size:        transfer.FileSize,
+			Transferred: transfer.Received,
+		}
+		if friendNum, fileNumber, ok := parseTransferKey(key); ok {
+			info.FriendNumber, info.FileNumber = friendNum, fileNumber
+			list = append(list, info)
+		}
+	}
+	for key, transfer := range c.outgoingTransfers {
+		info := TransferInfo{
+			Direction:   TransferOutgoing,
+			Filename:    transfer.Filename,
+			Path:        transfer.FilePath,
+			Size:        transfer.FileSize,
+			Transferred: transfer.Sent,
+		}
+		if friendNum, fileNumber, ok := parseTransferKey(key); ok {
+			info.FriendNumber, info.FileNumber = friendNum, fileNumber
+			list = append(list, info)
+		}
+	}
+	c.transfersMu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].FriendNumber != list[j].FriendNumber {
+			return list[i].FriendNumber < list[j].FriendNumber
+		}
+		if list[i].FileNumber != list[j].FileNumber {
+			return list[i].FileNumber < list[j].FileNumber
+		}
+		return list[i].Direction < list[j].Direction
+	})
+
+	return list
+}
+
+// CancelTransfer cancels an active transfer in either direction. Partially
+// received files are removed.
+func (c *Client) CancelTransfer(friendNum, fileNumber uint32) error {
+	transferKey := fmt.Sprintf("%d:%d", friendNum, fileNumber)
+
+	c.transfersMu.RLock()
+	incoming, isIncoming := c.incomingTransfers[transferKey]
+	outgoing, isOutgoing := c.outgoingTransfers[transferKey]
+	c.transfersMu.RUnlock()
+
+	switch {
+	case isIncoming:
+		c.abortFileReceive(friendNum, fileNumber, transferKey, incoming)
+	case isOutgoing:
+		c.cancelFileTransfer(friendNum, fileNumber)
+		c.abortFileSend(friendNum, fileNumber, transferKey, outgoing)
+	default:
+		return fmt.Errorf("no active transfer %d for friend %d", fileNumber, friendNum)
+	}
+
+	return nil
+}
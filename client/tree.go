@@ -0,0 +1,475 @@
+// Package client implements the file tree shared by the FUSE and 9P frontends
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventCommandError is published on a tree's error bus when a write to an
+// input file is rejected
+const eventCommandError = "command_error"
+
+// Modes of the files in the tree
+const (
+	treeInputPerm  = 0o200
+	treeOutputPerm = 0o400
+)
+
+// fileTree presents the same hierarchy as the FIFOManager, backed directly by
+// the client, for frontends that serve files themselves instead of creating
+// named pipes
+type fileTree struct {
+	client    *Client
+	errors    *EventBus
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// treeNode is a directory or file in the tree. Directories list their entries
+// on demand; files are input files running a command per line, streams
+// producing a line per event, or snapshots of some client state.
+type treeNode struct {
+	entries func() map[string]*treeNode
+
+	dir  string
+	name string
+	cmd  command
+
+	bus    *EventBus
+	filter EventFilter
+	format func(Event) (string, bool)
+
+	content func() string
+}
+
+func newFileTree(client *Client) *fileTree {
+	return &fileTree{
+		client: client,
+		errors: NewEventBus(),
+		done:   make(chan struct{}),
+	}
+}
+
+// close ends every blocked stream read
+func (t *fileTree) close() {
+	t.closeOnce.Do(func() { close(t.done) })
+}
+
+func (n *treeNode) isDir() bool      { return n.entries != nil }
+func (n *treeNode) isInput() bool    { return n.cmd != nil }
+func (n *treeNode) isStream() bool   { return n.bus != nil }
+func (n *treeNode) isSnapshot() bool { return n.content != nil }
+
+// perm returns the node's permission bits
+func (n *treeNode) perm() uint32 {
+	switch {
+	case n.isDir():
+		return DirPerm
+	case n.isInput():
+		return treeInputPerm
+	default:
+		return treeOutputPerm
+	}
+}
+
+// size returns the node's current length, which is only known for snapshots
+func (n *treeNode) size() uint64 {
+	if n.isSnapshot() {
+		return uint64(len(n.content()))
+	}
+	return 0
+}
+
+// sortedNames returns the names of a directory's entries in order
+func sortedNames(entries map[string]*treeNode) []string {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// root returns the top of the tree
+func (t *fileTree) root() *treeNode {
+	return t.newDir(t.rootEntries)
+}
+
+// walk resolves the path from the root, one name per element
+func (t *fileTree) walk(names []string) (*treeNode, bool) {
+	node := t.root()
+	for _, name := range names {
+		if !node.isDir() {
+			return nil, false
+		}
+		next, exists := node.entries()[name]
+		if !exists {
+			return nil, false
+		}
+		node = next
+	}
+	return node, true
+}
+
+func (t *fileTree) newDir(entries func() map[string]*treeNode) *treeNode {
+	return &treeNode{entries: entries}
+}
+
+func (t *fileTree) newInput(dir, name string, cmd command) *treeNode {
+	return &treeNode{dir: dir, name: name, cmd: cmd}
+}
+
+func (t *fileTree) newStream(bus *EventBus, filter EventFilter, format func(Event) (string, bool)) *treeNode {
+	return &treeNode{bus: bus, filter: filter, format: format}
+}
+
+func (t *fileTree) newSnapshot(content func() string) *treeNode {
+	return &treeNode{content: content}
+}
+
+// reportError logs a rejected write and publishes it to the errors files of
+// its directory and of client/
+func (t *fileTree) reportError(dir, name, input string, reason error) {
+	t.client.logf("Rejected command on %s: %v", path.Join(dir, name), reason)
+
+	t.errors.Publish(Event{
+		Type: eventCommandError,
+		Time: time.Now().UTC(),
+		Data: map[string]interface{}{
+			"dir":    dir,
+			"name":   name,
+			"input":  input,
+			"reason": reason,
+		},
+	})
+}
+
+// run runs one line written to an input file and reports it if it is rejected
+func (t *fileTree) run(n *treeNode, line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	if err := n.cmd(line); err != nil {
+		t.reportError(n.dir, n.name, line, err)
+		return err
+	}
+	return nil
+}
+
+// treeInputWriter runs the lines written to an open input file, buffering a
+// partial line until its newline or the close
+type treeInputWriter struct {
+	tree    *fileTree
+	node    *treeNode
+	pending []byte
+	mu      sync.Mutex
+}
+
+func (t *fileTree) openInput(n *treeNode) *treeInputWriter {
+	return &treeInputWriter{tree: t, node: n}
+}
+
+// Write runs every complete line and returns the first rejection
+func (w *treeInputWriter) Write(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, data...)
+
+	var rejected error
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.pending[:i])
+		w.pending = w.pending[i+1:]
+		if err := w.tree.run(w.node, line); err != nil && rejected == nil {
+			rejected = err
+		}
+	}
+	return rejected
+}
+
+// Flush runs a final line written without a trailing newline
+func (w *treeInputWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	line := string(w.pending)
+	w.pending = nil
+	return w.tree.run(w.node, line)
+}
+
+// treeStreamReader is one reader of a stream. It sees the lines produced
+// after it was opened.
+type treeStreamReader struct {
+	bus    *EventBus
+	sub    *Subscription
+	format func(Event) (string, bool)
+	done   <-chan struct{}
+	buf    []byte
+	mu     sync.Mutex
+}
+
+func (t *fileTree) openStream(n *treeNode) *treeStreamReader {
+	return &treeStreamReader{
+		bus:    n.bus,
+		sub:    n.bus.Subscribe(n.filter),
+		format: n.format,
+		done:   t.done,
+	}
+}
+
+// Read returns buffered lines, waiting for the next one if there are none.
+// It returns io.EOF once the tree is closed and ctx's error if ctx ends first.
+func (r *treeStreamReader) Read(ctx context.Context, dest []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for len(r.buf) == 0 {
+		select {
+		case event, ok := <-r.sub.C:
+			if !ok {
+				return 0, io.EOF
+			}
+			if line, ok := r.format(event); ok {
+				r.buf = append(r.buf, line...)
+				r.buf = append(r.buf, '\n')
+			}
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-r.done:
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(dest, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Close unsubscribes the reader
+func (r *treeStreamReader) Close() {
+	r.bus.Unsubscribe(r.sub)
+}
+
+// rootEntries lists client/, conferences/ and a directory per friend
+func (t *fileTree) rootEntries() map[string]*treeNode {
+	entries := map[string]*treeNode{
+		"client":      t.newDir(t.clientEntries),
+		"conferences": t.newDir(t.conferencesEntries),
+	}
+
+	for _, friend := range t.client.Friends() {
+		if friend.PublicKey == ([32]byte{}) {
+			continue
+		}
+		friendIDStr := hex.EncodeToString(friend.PublicKey[:])
+		entries[friendIDStr] = t.newDir(func() map[string]*treeNode {
+			return t.friendEntries(friendIDStr)
+		})
+	}
+
+	return entries
+}
+
+// clientEntries lists the files in client/
+func (t *fileTree) clientEntries() map[string]*treeNode {
+	c := t.client
+	entries := make(map[string]*treeNode)
+
+	for name, cmd := range c.globalCommands() {
+		entries[name] = t.newInput("client", name, cmd)
+	}
+
+	entries[RequestOut] = t.newStream(c.events, newTypeFilter(EventFriendRequest), func(event Event) (string, bool) {
+		message, _ := event.Data["message"].(string)
+		return event.Friend + " " + message, true
+	})
+	entries[ErrorsOut] = t.newErrorStream("")
+
+	entries[ID] = t.newSnapshot(func() string { return c.GetToxID() + "\n" })
+	entries[ConnectionStatus] = t.newSnapshot(c.connectionStatusText)
+	entries[TransportStatus] = t.newSnapshot(func() string { return transportStatusText(c.config.Transport) })
+	entries[ConfigStatus] = t.newSnapshot(c.configStatusText)
+	entries[BootstrapStatus] = t.newSnapshot(c.bootstrapStatusText)
+	entries[RequestsPending] = t.newSnapshot(c.requestsPendingText)
+	entries[LastSeenList] = t.newSnapshot(c.lastSeenText)
+	entries[FriendsList] = t.newSnapshot(func() string { return FriendsRosterText(c.FriendList()) })
+	if c.liveConfig().FriendsJSON {
+		entries[FriendsJSON] = t.newSnapshot(func() string {
+			text, err := friendsJSONText(c.FriendList())
+			if err != nil {
+				t.client.logf("Failed to list friends: %v", err)
+			}
+			return text
+		})
+	}
+
+	return entries
+}
+
+// friendEntries lists the files in a friend's directory, or nothing once the
+// friend is gone
+func (t *fileTree) friendEntries(friendIDStr string) map[string]*treeNode {
+	c := t.client
+	if _, exists := c.FriendByKey(friendIDStr); !exists {
+		return nil
+	}
+
+	entries := make(map[string]*treeNode)
+	for name, cmd := range c.friendCommands(friendIDStr) {
+		entries[name] = t.newInput(friendIDStr, name, cmd)
+	}
+
+	friendFilter := func(types ...string) EventFilter {
+		filter := newTypeFilter(types...)
+		filter.Friends = map[string]bool{friendIDStr: true}
+		return filter
+	}
+	entries[TextOut] = t.newStream(c.events, friendFilter(EventMessage), t.formatTextOut)
+	entries[FileOut] = t.newStream(c.events, friendFilter(EventFileOffer, EventFileComplete, EventFileAborted), formatFileOut)
+	entries[Status] = t.newStream(c.events, friendFilter(EventStatus, EventConnection), formatStatus)
+	entries[FriendStatusMessage] = t.newStream(c.events, friendFilter(EventStatusMessage), func(event Event) (string, bool) {
+		statusMessage, _ := event.Data["status_message"].(string)
+		return statusMessage, true
+	})
+	entries[Typing] = t.newStream(c.events, friendFilter(EventTyping), func(event Event) (string, bool) {
+		if typing, _ := event.Data["typing"].(bool); typing {
+			return "1", true
+		}
+		return "0", true
+	})
+	entries[ErrorsOut] = t.newErrorStream(friendIDStr)
+
+	for _, file := range friendInfoFiles(friendIDStr, &Friend{}) {
+		name := file.name
+		entries[name] = t.newSnapshot(func() string {
+			friend, exists := c.FriendByKey(friendIDStr)
+			if !exists {
+				return ""
+			}
+			for _, file := range friendInfoFiles(friendIDStr, &friend) {
+				if file.name == name {
+					return file.value + "\n"
+				}
+			}
+			return ""
+		})
+	}
+
+	return entries
+}
+
+// conferencesEntries lists a directory per conference
+func (t *fileTree) conferencesEntries() map[string]*treeNode {
+	entries := make(map[string]*treeNode)
+
+	for _, conferenceID := range t.client.Conferences() {
+		entries[strconv.FormatUint(uint64(conferenceID), 10)] = t.newDir(func() map[string]*treeNode {
+			return t.conferenceEntries(conferenceID)
+		})
+	}
+
+	return entries
+}
+
+// conferenceEntries lists the files in a conference directory
+func (t *fileTree) conferenceEntries(conferenceID uint32) map[string]*treeNode {
+	dir := path.Join("conferences", strconv.FormatUint(uint64(conferenceID), 10))
+	entries := make(map[string]*treeNode)
+
+	for name, cmd := range t.client.conferenceCommands(conferenceID) {
+		entries[name] = t.newInput(dir, name, cmd)
+	}
+	entries[ErrorsOut] = t.newErrorStream(dir)
+
+	return entries
+}
+
+// newErrorStream creates the errors file for dir. The one in client/, with
+// dir "", receives the errors of every directory prefixed with their path.
+func (t *fileTree) newErrorStream(dir string) *treeNode {
+	return t.newStream(t.errors, EventFilter{}, func(event Event) (string, bool) {
+		eventDir, _ := event.Data["dir"].(string)
+		name, _ := event.Data["name"].(string)
+		input, _ := event.Data["input"].(string)
+		reason, _ := event.Data["reason"].(error)
+
+		if dir == "" {
+			if eventDir != "client" {
+				name = path.Join(eventDir, name)
+			}
+		} else if dir != eventDir {
+			return "", false
+		}
+		return formatErrorLine(name, input, reason), true
+	})
+}
+
+// newTypeFilter builds a filter for the given event types
+func newTypeFilter(types ...string) EventFilter {
+	filter := EventFilter{Types: make(map[string]bool, len(types))}
+	for _, t := range types {
+		filter.Types[t] = true
+	}
+	return filter
+}
+
+// formatTextOut formats a message event as written to text_out
+func (t *fileTree) formatTextOut(event Event) (string, bool) {
+	friend, _ := t.client.FriendByKey(event.Friend)
+	message, _ := event.Data["message"].(string)
+	action, _ := event.Data["action"].(bool)
+	isAsync, _ := event.Data["async"].(bool)
+	return formatTextOut(event.Time.Local(), friend.Name, message, action, isAsync), true
+}
+
+// formatFileOut formats a file transfer event as written to file_out
+func formatFileOut(event Event) (string, bool) {
+	filename, _ := event.Data["filename"].(string)
+	size, _ := event.Data["size"].(uint64)
+	transferred, _ := event.Data["transferred"].(uint64)
+	direction, _ := event.Data["direction"].(string)
+
+	switch event.Type {
+	case EventFileOffer:
+		return fileOfferLine(filename, size), true
+	case EventFileComplete:
+		if direction == TransferOutgoing {
+			return fileDoneLine("SENT", filename, transferred), true
+		}
+		return fileDoneLine("COMPLETE", filename, transferred), true
+	case EventFileAborted:
+		// Only aborted sends are reported in file_out
+		return fileAbortLine(filename, transferred, size), direction == TransferOutgoing
+	}
+	return "", false
+}
+
+// formatStatus formats a user status or connection event as written to a
+// friend's status file
+func formatStatus(event Event) (string, bool) {
+	if event.Type == EventConnection {
+		connection, _ := event.Data["connection"].(string)
+		return friendConnectionLine(connection), true
+	}
+	userStatus, _ := event.Data["user_status"].(string)
+	return userStatus, true
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// newTreeTestClient creates a test client with one friend, Bob, and returns
+// it with the friend's ID
+func newTreeTestClient(t *testing.T) (*Client, string) {
+	t.Helper()
+
+	c := newControlTestServer(t).client
+	var publicKey [32]byte
+	copy(publicKey[:], strings.Repeat("\xab", 32))
+	c.friends[0] = &Friend{ID: 0, PublicKey: publicKey, Name: "Bob"}
+
+	return c, hex.EncodeToString(publicKey[:])
+}
+
+func TestTreeWalk(t *testing.T) {
+	c, friendID := newTreeTestClient(t)
+	tree := newFileTree(c)
+
+	tests := []struct {
+		name  string
+		path  []string
+		found bool
+		check func(*treeNode) bool
+	}{
+		{"root", nil, true, (*treeNode).isDir},
+		{"client directory", []string{"client"}, true, (*treeNode).isDir},
+		{"global input", []string{"client", Name}, true, (*treeNode).isInput},
+		{"global stream", []string{"client", RequestOut}, true, (*treeNode).isStream},
+		{"global snapshot", []string{"client", FriendsList}, true, (*treeNode).isSnapshot},
+		{"friend input", []string{friendID, TextIn}, true, (*treeNode).isInput},
+		{"friend stream", []string{friendID, TextOut}, true, (*treeNode).isStream},
+		{"friend snapshot", []string{friendID, "name"}, true, (*treeNode).isSnapshot},
+		{"unknown friend", []string{strings.Repeat("cd", 32)}, false, nil},
+		{"below a file", []string{"client", Name, "x"}, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, found := tree.walk(tt.path)
+			if found != tt.found {
+				t.Fatalf("walk(%v) found = %v, want %v", tt.path, found, tt.found)
+			}
+			if found && !tt.check(node) {
+				t.Errorf("walk(%v) returned the wrong kind of node", tt.path)
+			}
+		})
+	}
+}
+
+func TestTreeEventFormatting(t *testing.T) {
+	tests := []struct {
+		name   string
+		format func(Event) (string, bool)
+		event  Event
+		want   string
+		ok     bool
+	}{
+		{
+			name:   "file offer",
+			format: formatFileOut,
+			event:  Event{Type: EventFileOffer, Data: map[string]interface{}{"filename": "a.txt", "size": uint64(10)}},
+			want:   fileOfferLine("a.txt", 10),
+			ok:     true,
+		},
+		{
+			name:   "file sent",
+			format: formatFileOut,
+			event: Event{Type: EventFileComplete, Data: map[string]interface{}{
+				"filename": "a.txt", "transferred": uint64(10), "direction": TransferOutgoing,
+			}},
+			want: fileDoneLine("SENT", "a.txt", 10),
+			ok:   true,
+		},
+		{
+			name:   "incoming abort is not reported",
+			format: formatFileOut,
+			event: Event{Type: EventFileAborted, Data: map[string]interface{}{
+				"filename": "a.txt", "direction": TransferIncoming,
+			}},
+			want: fileAbortLine("a.txt", 0, 0),
+			ok:   false,
+		},
+		{
+			name:   "connection",
+			format: formatStatus,
+			event:  Event{Type: EventConnection, Data: map[string]interface{}{"connection": "udp"}},
+			want:   friendConnectionLine("udp"),
+			ok:     true,
+		},
+		{
+			name:   "user status",
+			format: formatStatus,
+			event:  Event{Type: EventStatus, Data: map[string]interface{}{"user_status": "away"}},
+			want:   "away",
+			ok:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.format(tt.event)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("format() = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,448 @@
+// Package main implements the ratox-go subcommands
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/opd-ai/go-ratox/client"
+	"github.com/opd-ai/go-ratox/config"
+	"github.com/sirupsen/logrus"
+)
+
+// command is a subcommand. run parses the arguments after the command name
+// with fs, which already has -profile, and returns the process exit status.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(fs *flag.FlagSet, args []string) int
+}
+
+// commands lists the subcommands; without one ratox-go runs the client
+var commands = []command{
+	{"id", "id [-profile DIR]", "Print the Tox ID", runID},
+	{"status", "status [-profile DIR] [-json]", "Show whether ratox-go is running and its state", runStatus},
+	{"friends", "friends [-profile DIR] [-json]", "List friends as in client/friends", runFriends},
+	{"send", "send [-profile DIR] [-action] FRIEND [MESSAGE...]", "Send a message, read from stdin without MESSAGE", runSend},
+	{"add", "add [-profile DIR] TOX_ID [MESSAGE...]", "Send a friend request", runAdd},
+	{"export", "export [-profile DIR] [-encrypt] FILE", "Write the profile to an archive, - for stdout", runExport},
+	{"import", "import [-profile DIR] [-force] FILE", "Restore a profile from an archive, - for stdin", runImport},
+}
+
+// errNotRunning is returned by commands that need a running ratox-go
+var errNotRunning = errors.New("ratox-go is not running on this profile, or its control socket is disabled")
+
+// runCommand runs the subcommand named by args[0], reporting whether there
+// was one
+func runCommand(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		// Commands report their own results; only log errors
+		logrus.SetLevel(logrus.ErrorLevel)
+
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		fs.StringVar(configPath, "profile", "", "Path to configuration directory")
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "usage: ratox-go %s\n\n%s\n\n", cmd.usage, cmd.summary)
+			fs.PrintDefaults()
+		}
+		return cmd.run(fs, args[1:]), true
+	}
+	return 0, false
+}
+
+// parseArgs parses the command line of a command taking between min and max
+// arguments, or at least min if max is negative
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) bool {
+	if err := fs.Parse(args); err != nil {
+		return false
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return false
+	}
+	return true
+}
+
+// commandFailed reports err and returns the failure exit status
+func commandFailed(fs *flag.FlagSet, err error) int {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Name(), err)
+	return 1
+}
+
+// commandConfig reads the configuration of the selected profile
+func commandConfig() (*config.Config, error) {
+	return readEffectiveConfig(determineConfigDir())
+}
+
+// dialRunning connects to the control socket of a running ratox-go, returning
+// nil if none answers
+func dialRunning(cfg *config.Config) *client.ControlClient {
+	if !cfg.ControlSocket {
+		return nil
+	}
+	cc, err := client.DialControl(cfg.ControlSocketPath())
+	if err != nil {
+		return nil
+	}
+	return cc
+}
+
+// openOffline loads the profile while ratox-go is not running
+func openOffline(cfg *config.Config) (*client.Client, error) {
+	passphrase, err := profilePassphrase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Passphrase = passphrase
+	return client.OpenOffline(cfg)
+}
+
+// selfStatus returns the state of the running ratox-go, or of the profile
+// read offline, and whether it is running
+func selfStatus(cfg *config.Config) (client.SelfInfo, bool, error) {
+	var info client.SelfInfo
+	if cc := dialRunning(cfg); cc != nil {
+		defer cc.Close()
+		err := cc.Call("status", nil, &info)
+		return info, true, err
+	}
+
+	c, err := openOffline(cfg)
+	if err != nil {
+		return info, false, err
+	}
+	defer c.Close()
+	return c.Status(), false, nil
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Printf("%s\n", data)
+	return err
+}
+
+// runID prints the Tox ID
+func runID(fs *flag.FlagSet, args []string) int {
+	if !parseArgs(fs, args, 0, 0) {
+		return 2
+	}
+
+	cfg, err := commandConfig()
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+	info, _, err := selfStatus(cfg)
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+
+	fmt.Println(info.ToxID)
+	return 0
+}
+
+// runStatus prints whether ratox-go is running and the state of the profile
+func runStatus(fs *flag.FlagSet, args []string) int {
+	asJSON := fs.Bool("json", false, "Print JSON")
+	if !parseArgs(fs, args, 0, 0) {
+		return 2
+	}
+
+	cfg, err := commandConfig()
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+	info, running, err := selfStatus(cfg)
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+
+	if *asJSON {
+		status := struct {
+			Running bool `json:"running"`
+			client.SelfInfo
+		}{running, info}
+		if err := printJSON(status); err != nil {
+			return commandFailed(fs, err)
+		}
+		return 0
+	}
+
+	runningText := "no"
+	if running {
+		runningText = "yes"
+	}
+	fmt.Printf("running: %s\n", runningText)
+	fmt.Printf("tox_id: %s\n", info.ToxID)
+	fmt.Printf("name: %s\n", info.Name)
+	fmt.Printf("status_message: %s\n", info.StatusMessage)
+	fmt.Printf("connection: %s\n", info.Connection)
+	fmt.Printf("friends: %d\n", info.Friends)
+	fmt.Printf("friends_online: %d\n", info.FriendsOnline)
+	fmt.Printf("pending_requests: %d\n", info.PendingRequests)
+	fmt.Printf("transfers: %d\n", info.Transfers)
+	return 0
+}
+
+// runFriends lists the friends
+func runFriends(fs *flag.FlagSet, args []string) int {
+	asJSON := fs.Bool("json", false, "Print JSON as in client/friends.json")
+	if !parseArgs(fs, args, 0, 0) {
+		return 2
+	}
+
+	cfg, err := commandConfig()
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+
+	var list []client.FriendInfo
+	if cc := dialRunning(cfg); cc != nil {
+		defer cc.Close()
+		if err := cc.Call("list_friends", nil, &list); err != nil {
+			return commandFailed(fs, err)
+		}
+	} else {
+		c, err := openOffline(cfg)
+		if err != nil {
+			return commandFailed(fs, err)
+		}
+		defer c.Close()
+		list = c.FriendList()
+	}
+
+	if *asJSON {
+		if list == nil {
+			list = []client.FriendInfo{}
+		}
+		if err := printJSON(list); err != nil {
+			return commandFailed(fs, err)
+		}
+		return 0
+	}
+	fmt.Print(client.FriendsRosterText(list))
+	return 0
+}
+
+// messageArgs joins the message words, or reads the message from stdin if
+// there are none
+func messageArgs(words []string) (string, error) {
+	if len(words) > 0 {
+		return strings.Join(words, " "), nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read message: %w", err)
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// runSend sends a message through the running ratox-go
+func runSend(fs *flag.FlagSet, args []string) int {
+	action := fs.Bool("action", false, "Send an action (/me) message")
+	if !parseArgs(fs, args, 1, -1) {
+		return 2
+	}
+
+	message, err := messageArgs(fs.Args()[1:])
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+
+	cfg, err := commandConfig()
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+	cc := dialRunning(cfg)
+	if cc == nil {
+		return commandFailed(fs, errNotRunning)
+	}
+	defer cc.Close()
+
+	params := map[string]interface{}{"friend": fs.Arg(0), "message": message, "action": *action}
+	if err := cc.Call("send_message", params, nil); err != nil {
+		return commandFailed(fs, err)
+	}
+	return 0
+}
+
+// runAdd sends a friend request through the running ratox-go, or adds the
+// friend to the stopped profile, and prints the new friend's public key
+func runAdd(fs *flag.FlagSet, args []string) int {
+	if !parseArgs(fs, args, 1, -1) {
+		return 2
+	}
+
+	cfg, err := commandConfig()
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+	toxID, message := fs.Arg(0), strings.Join(fs.Args()[1:], " ")
+	cc := dialRunning(cfg)
+	if cc == nil {
+		publicKey, err := addFriendOffline(cfg, toxID, message)
+		if err != nil {
+			return commandFailed(fs, err)
+		}
+		fmt.Println(publicKey)
+		return 0
+	}
+	defer cc.Close()
+
+	var result struct {
+		PublicKey string `json:"public_key"`
+	}
+	params := map[string]string{"tox_id": toxID, "message": message}
+	if err := cc.Call("add_friend", params, &result); err != nil {
+		return commandFailed(fs, err)
+	}
+
+	fmt.Println(result.PublicKey)
+	return 0
+}
+
+// addFriendOffline adds a friend to the profile while ratox-go is not
+// running and returns its public key. The request is sent on the next start.
+func addFriendOffline(cfg *config.Config, toxID, message string) (string, error) {
+	lock, err := client.LockProfile(cfg.ConfigDir)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Release()
+
+	c, err := openOffline(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	if message == "" {
+		message = client.DefaultFriendRequestMessage
+	}
+	friendID, err := c.AddFriendOffline(toxID, message)
+	if err != nil {
+		return "", err
+	}
+	friend, _ := c.GetFriend(friendID)
+	return hex.EncodeToString(friend.PublicKey[:]), nil
+}
+
+// runExport writes the profile archive
+func runExport(fs *flag.FlagSet, args []string) int {
+	encrypt := fs.Bool("encrypt", false, fmt.Sprintf("Encrypt the archive with a passphrase from %s or a prompt", config.ExportPassphraseEnv))
+	if !parseArgs(fs, args, 1, 1) {
+		return 2
+	}
+	file := fs.Arg(0)
+
+	cfg, err := config.Read(determineConfigDir())
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+
+	var passphrase string
+	if *encrypt {
+		if passphrase, err = exportPassphrase(); err != nil {
+			return commandFailed(fs, err)
+		}
+	}
+
+	out := os.Stdout
+	if file != "-" {
+		// O_EXCL so an archive is never silently replaced
+		if out, err = os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600); err != nil {
+			return commandFailed(fs, err)
+		}
+	}
+
+	err = client.ExportProfile(cfg, out, passphrase)
+	if file != "-" {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(file)
+		}
+	}
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+
+	if file != "-" {
+		fmt.Fprintf(os.Stderr, "Exported %s to %s\n", cfg.ConfigDir, file)
+	}
+	return 0
+}
+
+// runImport restores a profile archive
+func runImport(fs *flag.FlagSet, args []string) int {
+	force := fs.Bool("force", false, "Replace an existing identity")
+	if !parseArgs(fs, args, 1, 1) {
+		return 2
+	}
+	file := fs.Arg(0)
+
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+
+	var passphrase string
+	if client.IsEncryptedProfile(data) {
+		var ok bool
+		if passphrase, ok = os.LookupEnv(config.ExportPassphraseEnv); !ok {
+			if passphrase, err = promptPassphrase("Archive passphrase: "); err != nil {
+				return commandFailed(fs, fmt.Errorf("archive is encrypted: %w", err))
+			}
+		}
+	}
+
+	configDir := determineConfigDir()
+	lock, err := client.LockProfile(configDir)
+	if err != nil {
+		return commandFailed(fs, err)
+	}
+	defer lock.Release()
+
+	if err := client.ImportProfile(data, configDir, passphrase, *force); err != nil {
+		if errors.Is(err, client.ErrProfileExists) {
+			err = fmt.Errorf("%w: use -force to replace it", err)
+		}
+		return commandFailed(fs, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %s into %s\n", file, configDir)
+	return 0
+}
+
+// exportPassphrase returns the archive passphrase from RATOX_EXPORT_PASSPHRASE
+// or typed twice at a prompt
+func exportPassphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv(config.ExportPassphraseEnv); ok && passphrase != "" {
+		return passphrase, nil
+	}
+	return promptNewPassphrase("Archive passphrase: ", "Repeat archive passphrase: ")
+}
@@ -17,6 +17,56 @@ const (
 	ConfigFileName = "config.json"
 	// SaveDataFileName is the name of the Tox save data file
 	SaveDataFileName = "ratox.tox"
+	// PendingRequestsFileName is the name of the pending friend request store
+	PendingRequestsFileName = "requests.json"
+	// OutgoingRequestsFileName is the name of the store of friend requests
+	// added while ratox-go was not running
+	OutgoingRequestsFileName = "outgoing_requests.json"
+	// AliasesFileName is the name of the friend alias store
+	AliasesFileName = "aliases.json"
+	// LastSeenFileName is the name of the friend last-seen store
+	LastSeenFileName = "last_seen.json"
+	// BootstrapStatsFileName is the name of the bootstrap node health store
+	BootstrapStatsFileName = "bootstrap_stats.json"
+	// AliasDirName is the directory holding by-name symlinks to friend directories
+	AliasDirName = "by-name"
+	// ControlSocketFileName is the name of the JSON-RPC control socket
+	ControlSocketFileName = "control.sock"
+	// HTTPTokenFileName is the name of the HTTP gateway access token file
+	HTTPTokenFileName = "http_token"
+	// NinePTokenFileName is the name of the 9P TCP listener access token file
+	NinePTokenFileName = "9p_token"
+	// UploadDirName is the directory holding files uploaded through the HTTP gateway
+	UploadDirName = "uploads"
+	// MountDirName is the default FUSE mount point inside the profile directory
+	MountDirName = "mnt"
+	// NinePSocketFileName is the name of the default 9P server socket
+	NinePSocketFileName = "9p.sock"
+	// LockFileName is the name of the lock file held by the process using
+	// the profile
+	LockFileName = "ratox.lock"
+	// CorruptSaveDataSuffix marks a save file set aside because it could not
+	// be loaded
+	CorruptSaveDataSuffix = ".corrupt"
+	// DefaultSaveDataBackups is the default number of save file backups
+	DefaultSaveDataBackups = 3
+	// PassphraseEnv names the environment variable holding the save file
+	// passphrase
+	PassphraseEnv = "RATOX_PASSPHRASE"
+	// NewPassphraseEnv names the environment variable holding the passphrase
+	// set by -change-passphrase
+	NewPassphraseEnv = "RATOX_NEW_PASSPHRASE"
+	// ExportPassphraseEnv names the environment variable holding the
+	// passphrase of an exported profile archive
+	ExportPassphraseEnv = "RATOX_EXPORT_PASSPHRASE"
+)
+
+// Frontends that can expose the profile tree
+const (
+	// FrontendFIFO creates named pipes in the profile directory
+	FrontendFIFO = "fifo"
+	// FrontendFUSE mounts a FUSE filesystem presenting the same tree
+	FrontendFUSE = "fuse"
 )
 
 // Config holds all configuration options for ratox-go
@@ -24,6 +74,10 @@ type Config struct {
 	// ConfigDir is the directory where configuration files are stored
 	ConfigDir string `json:"-"`
 
+	// Version is the schema version of the file. Files without it are
+	// version 0 and are migrated on load.
+	Version int `json:"version"`
+
 	// Debug enables debug logging
 	Debug bool `json:"debug"`
 
@@ -39,6 +93,45 @@ type Config struct {
 	// MaxFileSize is the maximum file size to accept (in bytes)
 	MaxFileSize int64 `json:"max_file_size"`
 
+	// NospamRotationHours rotates the nospam (and therefore the Tox ID) to a
+	// random value at this interval. Zero disables scheduled rotation.
+	NospamRotationHours int `json:"nospam_rotation_hours"`
+
+	// SaveDataBackups is how many previous versions of the Tox save file are
+	// kept as ratox.tox.1 (newest) to ratox.tox.N. Zero keeps none.
+	SaveDataBackups int `json:"savedata_backups"`
+
+	// PassphraseFile names a file whose first line is the passphrase of the
+	// save file. Relative paths are inside the profile directory.
+	PassphraseFile string `json:"passphrase_file"`
+
+	// Passphrase encrypts the save file. It is never written to config.json.
+	Passphrase string `json:"-"`
+
+	// ProfileName prefixes log lines when one process runs several profiles.
+	// It comes from the manifest and is never written to config.json.
+	ProfileName string `json:"-"`
+
+	// FriendsJSON writes client/friends.json alongside the client/friends roster
+	FriendsJSON bool `json:"friends_json"`
+
+	// ControlSocket serves the JSON-RPC control API on a Unix socket in the
+	// profile directory
+	ControlSocket bool `json:"control_socket"`
+
+	// HTTP configures the optional loopback HTTP/REST and WebSocket gateway
+	HTTP HTTPConfig `json:"http"`
+
+	// NineP configures the optional 9P2000 file server
+	NineP NinePConfig `json:"9p"`
+
+	// Frontend selects how the profile tree is exposed: "fifo" or "fuse"
+	Frontend string `json:"frontend"`
+
+	// MountPoint is where the FUSE frontend is mounted; empty means the mnt
+	// directory inside the profile
+	MountPoint string `json:"mount_point"`
+
 	// BootstrapNodes contains DHT bootstrap nodes
 	BootstrapNodes []BootstrapNode `json:"bootstrap_nodes"`
 
@@ -50,6 +143,16 @@ type Config struct {
 
 	// SaveFile is the path to the Tox save file
 	SaveFile string `json:"-"`
+
+	// sources records settings not at their default, by JSON path
+	sources map[string]Source
+	// overridden and applied record the environment and flag overrides
+	overridden map[string]override
+	applied    []Override
+
+	// created and migrated note that Load's file still has to be written
+	created  bool
+	migrated *migration
 }
 
 // TransportConfig holds transport layer configuration
@@ -62,6 +165,28 @@ type TransportConfig struct {
 	I2PSAMAddr   string `json:"i2p_sam_addr"`
 }
 
+// HTTPConfig holds configuration for the optional HTTP/REST and WebSocket
+// gateway. Requests must carry the token stored in the profile directory.
+type HTTPConfig struct {
+	// Enabled controls whether the gateway is started. Default: false.
+	Enabled bool `json:"enabled"`
+
+	// Address is the host:port to listen on. It must be a loopback address.
+	// Default: "127.0.0.1:8777".
+	Address string `json:"address"`
+}
+
+// NinePConfig holds configuration for the optional 9P2000 file server, which
+// exports the profile tree to standard 9P clients
+type NinePConfig struct {
+	// Enabled controls whether the server is started. Default: false.
+	Enabled bool `json:"enabled"`
+
+	// Address is a loopback host:port to listen on. Empty means the 9p.sock
+	// Unix socket in the profile directory. Default: "".
+	Address string `json:"address"`
+}
+
 // BootstrapNode represents a DHT bootstrap node
 type BootstrapNode struct {
 	Address   string `json:"address"`
@@ -85,10 +210,14 @@ type BootstrapServerConfig struct {
 	ClearnetPort uint16 `json:"clearnet_port"`
 
 	// OnionEnabled controls whether a Tor hidden-service endpoint is started.
-	// Requires a running Tor daemon. Configuration is read from the
-	// TOR_CONTROL_ADDR environment variable (default: 127.0.0.1:9051).
+	// Requires a running Tor daemon reachable on TorControlAddr.
 	OnionEnabled bool `json:"onion_enabled"`
 
+	// TorControlAddr is the control port of the Tor daemon used for the onion
+	// endpoint. Empty leaves the TOR_CONTROL_ADDR environment variable, or
+	// its default of 127.0.0.1:9051, in effect.
+	TorControlAddr string `json:"tor_control_addr"`
+
 	// I2PEnabled controls whether an I2P destination endpoint is started.
 	// Requires a running I2P router with the SAM bridge enabled.
 	I2PEnabled bool `json:"i2p_enabled"`
@@ -123,8 +252,11 @@ var DefaultBootstrapNodes = []BootstrapNode{
 	},
 }
 
-// Load loads configuration from the specified directory
-// If the configuration file doesn't exist, it creates a default one
+// Load loads configuration from the specified directory. Nothing is written:
+// a missing file or one from an older version is only noted, and Persist
+// writes it once the caller holds the profile lock. The result is not
+// validated, so that overrides can still fix a bad value; call Validate once
+// they are applied.
 func Load(configDir string) (*Config, error) {
 	pc, _, _, _ := runtime.Caller(0)
 	funcName := runtime.FuncForPC(pc).Name()
@@ -136,6 +268,81 @@ func Load(configDir string) (*Config, error) {
 		"operation":  "load_config",
 	}).Debug("Starting configuration load")
 
+	_, statErr := os.Stat(filepath.Join(configDir, ConfigFileName))
+
+	cfg, applied, err := read(configDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.migrated = applied
+	cfg.created = os.IsNotExist(statErr)
+
+	logrus.WithFields(logrus.Fields{
+		"caller":     caller,
+		"config_dir": configDir,
+		"debug":      cfg.Debug,
+		"name":       cfg.Name,
+	}).Info("Configuration load completed successfully")
+
+	return cfg, nil
+}
+
+// Persist writes the configuration file if Load found it missing or migrated
+// it, backing up the older version first. A current file is left alone. The
+// caller must hold the profile lock.
+func (c *Config) Persist() error {
+	pc, _, _, _ := runtime.Caller(0)
+	funcName := runtime.FuncForPC(pc).Name()
+	caller := funcName[strings.LastIndex(funcName, ".")+1:]
+
+	if c.migrated != nil {
+		backupFile, err := c.migrated.backup(c.FilePath())
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"caller": caller,
+				"error":  err,
+			}).Error("Failed to back up configuration before migration")
+			return err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"caller":       caller,
+			"from_version": c.migrated.from,
+			"to_version":   CurrentVersion,
+			"backup_file":  backupFile,
+		}).Info("Configuration migrated to the current version")
+	} else if !c.created {
+		return nil
+	}
+
+	if err := c.Save(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"caller": caller,
+			"error":  err,
+		}).Error("Failed to save configuration")
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	c.migrated = nil
+	c.created = false
+	return nil
+}
+
+// Read reads the configuration from the specified directory merged over the
+// defaults, without creating or rewriting the file. Older files are migrated
+// in memory.
+func Read(configDir string) (*Config, error) {
+	cfg, _, err := read(configDir)
+	return cfg, err
+}
+
+// read reads the configuration, also returning the migration applied to an
+// older file, or nil
+func read(configDir string) (*Config, *migration, error) {
+	pc, _, _, _ := runtime.Caller(0)
+	funcName := runtime.FuncForPC(pc).Name()
+	caller := funcName[strings.LastIndex(funcName, ".")+1:]
+
 	configFile := filepath.Join(configDir, ConfigFileName)
 	saveFile := filepath.Join(configDir, SaveDataFileName)
 
@@ -147,13 +354,24 @@ func Load(configDir string) (*Config, error) {
 
 	// Default configuration
 	cfg := &Config{
-		ConfigDir:       configDir,
-		Debug:           false,
-		Name:            "ratox-go user",
-		StatusMessage:   "Running ratox-go",
-		AutoAcceptFiles: false,
-		MaxFileSize:     100 * 1024 * 1024, // 100MB default
-		BootstrapNodes:  DefaultBootstrapNodes,
+		ConfigDir:           configDir,
+		Version:             CurrentVersion,
+		Debug:               false,
+		Name:                "ratox-go user",
+		StatusMessage:       "Running ratox-go",
+		AutoAcceptFiles:     false,
+		MaxFileSize:         100 * 1024 * 1024, // 100MB default
+		NospamRotationHours: 0,
+		SaveDataBackups:     DefaultSaveDataBackups,
+		FriendsJSON:         true,
+		ControlSocket:       true,
+		HTTP: HTTPConfig{
+			Enabled: false,
+			Address: "127.0.0.1:8777",
+		},
+		Frontend: FrontendFIFO,
+		// Copied, since parsing the file overwrites the elements in place
+		BootstrapNodes: append([]BootstrapNode(nil), DefaultBootstrapNodes...),
 		Transport: TransportConfig{
 			TCPEnabled:   false,
 			TCPPort:      33445,
@@ -188,19 +406,30 @@ func Load(configDir string) (*Config, error) {
 		"operation":   "read_existing_config",
 	}).Debug("Attempting to read existing configuration file")
 
+	var applied *migration
 	if data, err := os.ReadFile(configFile); err == nil {
 		logrus.WithFields(logrus.Fields{
 			"caller":    caller,
 			"file_size": len(data),
 		}).Debug("Configuration file read successfully, parsing JSON")
 
+		data, applied, err = migrate(data)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"caller": caller,
+				"error":  err,
+			}).Error("Failed to migrate configuration file")
+			return nil, nil, err
+		}
+
 		if err := json.Unmarshal(data, cfg); err != nil {
 			logrus.WithFields(logrus.Fields{
 				"caller": caller,
 				"error":  err,
 			}).Error("Failed to parse configuration file")
-			return nil, fmt.Errorf("failed to parse config file: %w", err)
+			return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
 		}
+		cfg.recordFileSources(data)
 
 		logrus.WithFields(logrus.Fields{
 			"caller":             caller,
@@ -225,24 +454,7 @@ func Load(configDir string) (*Config, error) {
 		"save_file":  cfg.SaveFile,
 	}).Debug("Configuration fields updated")
 
-	// Save the configuration to ensure it exists
-	logrus.WithField("caller", caller).Debug("Saving configuration to ensure it exists")
-	if err := cfg.Save(); err != nil {
-		logrus.WithFields(logrus.Fields{
-			"caller": caller,
-			"error":  err,
-		}).Error("Failed to save configuration")
-		return nil, fmt.Errorf("failed to save config: %w", err)
-	}
-
-	logrus.WithFields(logrus.Fields{
-		"caller":     caller,
-		"config_dir": configDir,
-		"debug":      cfg.Debug,
-		"name":       cfg.Name,
-	}).Info("Configuration load completed successfully")
-
-	return cfg, nil
+	return cfg, applied, nil
 }
 
 // Save saves the configuration to disk
@@ -251,7 +463,7 @@ func (c *Config) Save() error {
 	funcName := runtime.FuncForPC(pc).Name()
 	caller := funcName[strings.LastIndex(funcName, ".")+1:]
 
-	configFile := filepath.Join(c.ConfigDir, ConfigFileName)
+	configFile := c.FilePath()
 
 	logrus.WithFields(logrus.Fields{
 		"caller":      caller,
@@ -259,7 +471,7 @@ func (c *Config) Save() error {
 		"operation":   "save_config",
 	}).Debug("Starting configuration save")
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	data, err := json.MarshalIndent(c.fileView(), "", "  ")
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"caller": caller,
@@ -273,7 +485,7 @@ func (c *Config) Save() error {
 		"json_size": len(data),
 	}).Debug("Configuration marshaled to JSON successfully")
 
-	if err := os.WriteFile(configFile, data, 0o600); err != nil {
+	if err := WriteFileAtomic(configFile, data, 0o600); err != nil {
 		logrus.WithFields(logrus.Fields{
 			"caller":      caller,
 			"config_file": configFile,
@@ -291,6 +503,37 @@ func (c *Config) Save() error {
 	return nil
 }
 
+// FilePath returns the path of the configuration file
+func (c *Config) FilePath() string {
+	return filepath.Join(c.ConfigDir, ConfigFileName)
+}
+
+// SaveDataBackupPath returns the path of the nth newest save file backup,
+// counting from 1
+func (c *Config) SaveDataBackupPath(n int) string {
+	return fmt.Sprintf("%s.%d", c.SaveFile, n)
+}
+
+// ReadPassphraseFile returns the passphrase stored in PassphraseFile, or ""
+// if no file is configured
+func (c *Config) ReadPassphraseFile() (string, error) {
+	if c.PassphraseFile == "" {
+		return "", nil
+	}
+
+	path := c.PassphraseFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.ConfigDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
 // FriendDir returns the directory path for a specific friend
 func (c *Config) FriendDir(friendID string) string {
 	return filepath.Join(c.ConfigDir, friendID)
@@ -306,6 +549,75 @@ func (c *Config) FriendFIFOPath(friendID, fifoName string) string {
 	return filepath.Join(c.FriendDir(friendID), fifoName)
 }
 
+// PendingRequestsPath returns the path of the pending friend request store
+func (c *Config) PendingRequestsPath() string {
+	return filepath.Join(c.ConfigDir, PendingRequestsFileName)
+}
+
+// OutgoingRequestsPath returns the path of the store of friend requests
+// added while ratox-go was not running
+func (c *Config) OutgoingRequestsPath() string {
+	return filepath.Join(c.ConfigDir, OutgoingRequestsFileName)
+}
+
+// AliasesPath returns the path of the friend alias store
+func (c *Config) AliasesPath() string {
+	return filepath.Join(c.ConfigDir, AliasesFileName)
+}
+
+// LastSeenPath returns the path of the friend last-seen store
+func (c *Config) LastSeenPath() string {
+	return filepath.Join(c.ConfigDir, LastSeenFileName)
+}
+
+// BootstrapStatsPath returns the path of the bootstrap node health store
+func (c *Config) BootstrapStatsPath() string {
+	return filepath.Join(c.ConfigDir, BootstrapStatsFileName)
+}
+
+// AliasDir returns the directory holding by-name symlinks to friend directories
+func (c *Config) AliasDir() string {
+	return filepath.Join(c.ConfigDir, AliasDirName)
+}
+
+// AliasLinkPath returns the path of the symlink for a friend alias
+func (c *Config) AliasLinkPath(alias string) string {
+	return filepath.Join(c.AliasDir(), alias)
+}
+
+// ControlSocketPath returns the path of the JSON-RPC control socket
+func (c *Config) ControlSocketPath() string {
+	return filepath.Join(c.ConfigDir, ControlSocketFileName)
+}
+
+// HTTPTokenPath returns the path of the HTTP gateway access token file
+func (c *Config) HTTPTokenPath() string {
+	return filepath.Join(c.ConfigDir, HTTPTokenFileName)
+}
+
+// NinePTokenPath returns the path of the 9P TCP listener access token file
+func (c *Config) NinePTokenPath() string {
+	return filepath.Join(c.ConfigDir, NinePTokenFileName)
+}
+
+// UploadDir returns the directory holding files uploaded through the HTTP gateway
+func (c *Config) UploadDir() string {
+	return filepath.Join(c.ConfigDir, UploadDirName)
+}
+
+// NinePSocketPath returns the path of the default 9P server socket
+func (c *Config) NinePSocketPath() string {
+	return filepath.Join(c.ConfigDir, NinePSocketFileName)
+}
+
+// FUSEMountPoint returns the directory the FUSE frontend is mounted on
+func (c *Config) FUSEMountPoint() string {
+	if c.MountPoint != "" {
+		return c.MountPoint
+	}
+	return filepath.Join(c.ConfigDir, MountDirName)
+}
+
 // ConferenceDir returns the directory path for a specific conference
 func (c *Config) ConferenceDir(conferenceID string) string {
 	return filepath.Join(c.ConfigDir, "conferences", conferenceID)
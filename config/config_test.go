@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -37,10 +38,38 @@ func TestLoad(t *testing.T) {
 		t.Error("Expected default bootstrap nodes, got none")
 	}
 
-	// Verify config file was created
+	// Load writes nothing; Persist creates the missing file
 	configFile := filepath.Join(tempDir, ConfigFileName)
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
-		t.Error("Config file was not created")
+	if _, err := os.Stat(configFile); !os.IsNotExist(err) {
+		t.Errorf("Load wrote the config file, stat error = %v", err)
+	}
+	if err := cfg.Persist(); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	if _, err := os.Stat(configFile); err != nil {
+		t.Errorf("Config file was not created: %v", err)
+	}
+}
+
+// TestPersistLeavesCurrentFile tests that a current config file is not
+// rewritten on startup
+func TestPersistLeavesCurrentFile(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, ConfigFileName)
+	data := `{"version": 1, "name": "alice"}`
+	if err := os.WriteFile(configFile, []byte(data), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Load(tempDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := cfg.Persist(); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	if got, _ := os.ReadFile(configFile); string(got) != data {
+		t.Errorf("Config file = %q, want it unchanged", got)
 	}
 }
 
@@ -273,3 +302,155 @@ func TestBootstrapServerConfigPersistence(t *testing.T) {
 		t.Error("Expected bootstrap nodes to remain populated after persistence round-trip")
 	}
 }
+
+func TestReadDoesNotWrite(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg, err := Read(tempDir)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if cfg.Name != "ratox-go user" {
+		t.Errorf("Expected default name 'ratox-go user', got %s", cfg.Name)
+	}
+	if _, err := os.Stat(cfg.FilePath()); !os.IsNotExist(err) {
+		t.Errorf("Expected no config file after Read, stat error = %v", err)
+	}
+
+	if err := os.WriteFile(cfg.FilePath(), []byte("{"), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := Read(tempDir); err == nil {
+		t.Error("Expected an error for malformed JSON")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(cfg *Config)
+		expectError bool
+	}{
+		{"defaults", func(cfg *Config) {}, false},
+		{"negative max file size", func(cfg *Config) { cfg.MaxFileSize = -1 }, true},
+		{"unknown frontend", func(cfg *Config) { cfg.Frontend = "gui" }, true},
+		{"empty config dir", func(cfg *Config) { cfg.ConfigDir = "" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Read(t.TempDir())
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			tt.modify(cfg)
+			if err := cfg.Validate(); (err != nil) != tt.expectError {
+				t.Errorf("Validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllFields(t *testing.T) {
+	cfg, err := Read(t.TempDir())
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	cfg.MaxFileSize = -1
+	cfg.HTTP = HTTPConfig{Enabled: true, Address: "0.0.0.0:8777"}
+	cfg.BootstrapNodes = []BootstrapNode{
+		cfg.BootstrapNodes[0],
+		{Address: "", Port: 0, PublicKey: "abc"},
+	}
+	cfg.Transport.TorEnabled = true
+	cfg.Transport.TorSOCKSAddr = "localhost"
+	cfg.BootstrapServer = BootstrapServerConfig{Enabled: true}
+
+	err = cfg.Validate()
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+	}
+
+	var paths []string
+	for _, field := range validationErr.Fields {
+		paths = append(paths, field.Path)
+	}
+	want := []string{
+		"max_file_size",
+		"http.address",
+		"bootstrap_nodes[1].address",
+		"bootstrap_nodes[1].port",
+		"bootstrap_nodes[1].public_key",
+		"transport.tor_socks_addr",
+		"bootstrap_server",
+	}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("Reported fields = %v, want %v", paths, want)
+	}
+}
+
+func TestLoadLeavesValidationToOverrides(t *testing.T) {
+	tempDir := t.TempDir()
+	data := `{"bootstrap_nodes": [{"address": "node", "port": 33445, "public_key": "xyz"}]}`
+	if err := os.WriteFile(filepath.Join(tempDir, ConfigFileName), []byte(data), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Load(tempDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "bootstrap_nodes[0].public_key") {
+		t.Errorf("Validate() error = %v, want it to name bootstrap_nodes[0].public_key", err)
+	}
+
+	// An override can replace the bad value before validation
+	node := "node.example.org:33445:" + strings.Repeat("A", 64)
+	if err := cfg.Apply([]Override{{Path: "bootstrap_nodes", Value: node, Source: SourceFlag}}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() after the override error = %v", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFileAtomic(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFileAtomic failed: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if string(data) != content {
+			t.Errorf("Content = %q, want %q", data, content)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Directory has %d entries, want only the written file", len(entries))
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "data"), []byte("x"), 0o600); err == nil {
+		t.Error("WriteFileAtomic into a missing directory succeeded")
+	}
+}
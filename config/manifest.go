@@ -0,0 +1,164 @@
+// Package config implements the manifest of profiles run by one ratox-go process
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// profileNamePattern limits profile names to what reads well in log prefixes
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Manifest lists the profiles one ratox-go process runs, each with its own
+// directory, identity and tree
+type Manifest struct {
+	// Settings override config.json in every profile, such as shared
+	// bootstrap_nodes
+	Settings map[string]json.RawMessage `json:"settings"`
+
+	// Profiles are started in order
+	Profiles []ManifestProfile `json:"profiles"`
+}
+
+// ManifestProfile is one profile of a manifest
+type ManifestProfile struct {
+	// Name identifies the profile in logs
+	Name string `json:"name"`
+
+	// Dir is the profile directory. Relative paths are resolved against the
+	// directory of the manifest.
+	Dir string `json:"profile"`
+
+	// Disabled profiles are not started, and are stopped on reload
+	Disabled bool `json:"disabled"`
+
+	// Settings override config.json and the shared settings for this profile,
+	// such as a transport.tcp_port of its own
+	Settings map[string]json.RawMessage `json:"settings"`
+}
+
+// ReadManifest reads and checks the manifest at path
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var m Manifest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	base, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve manifest directory: %w", err)
+	}
+	for i := range m.Profiles {
+		if dir := m.Profiles[i].Dir; dir != "" && !filepath.IsAbs(dir) {
+			m.Profiles[i].Dir = filepath.Join(base, dir)
+		}
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// validate checks profile names, directories and settings
+func (m *Manifest) validate() error {
+	if len(m.Profiles) == 0 {
+		return fmt.Errorf("no profiles")
+	}
+	if _, err := manifestOverrides(m.Settings); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	dirs := make(map[string]string)
+	for _, p := range m.Profiles {
+		if !profileNamePattern.MatchString(p.Name) {
+			return fmt.Errorf("profile name %q must be 1-64 letters, digits, '.', '_' or '-'", p.Name)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate profile name %q", p.Name)
+		}
+		names[p.Name] = true
+
+		if p.Dir == "" {
+			return fmt.Errorf("profile %s: no profile directory", p.Name)
+		}
+		dir := filepath.Clean(p.Dir)
+		if other, ok := dirs[dir]; ok {
+			return fmt.Errorf("profiles %s and %s share the directory %s", other, p.Name, dir)
+		}
+		dirs[dir] = p.Name
+
+		if _, err := manifestOverrides(p.Settings); err != nil {
+			return fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// Overrides returns the shared settings followed by the settings of p, which
+// take precedence
+func (m *Manifest) Overrides(p ManifestProfile) []Override {
+	shared, _ := manifestOverrides(m.Settings)
+	own, _ := manifestOverrides(p.Settings)
+	return append(shared, own...)
+}
+
+// manifestOverrides converts manifest settings to overrides in config.json
+// order. Values are JSON; strings are unquoted and anything else, such as a
+// number or a bootstrap_nodes array, is passed on as written.
+func manifestOverrides(values map[string]json.RawMessage) ([]Override, error) {
+	for path := range values {
+		if _, ok := lookupSetting(path); !ok {
+			return nil, fmt.Errorf("unknown setting %q", path)
+		}
+	}
+
+	var overrides []Override
+	for _, s := range settings {
+		raw, ok := values[s.Path]
+		if !ok {
+			continue
+		}
+		value := string(raw)
+		var str string
+		if json.Unmarshal(raw, &str) == nil {
+			value = str
+		}
+		if _, err := s.parse(value); err != nil {
+			return nil, fmt.Errorf("invalid setting %s: %w", s.Path, err)
+		}
+		overrides = append(overrides, Override{Path: s.Path, Value: value, Source: SourceManifest})
+	}
+	return overrides, nil
+}
+
+// Listeners describes the ports and addresses the profile binds, which must
+// differ between the profiles of one process
+func (c *Config) Listeners() []string {
+	var list []string
+	if c.Transport.TCPPort > 0 {
+		list = append(list, fmt.Sprintf("transport.tcp_port %d", c.Transport.TCPPort))
+	}
+	if c.HTTP.Enabled {
+		list = append(list, "http.address "+c.HTTP.Address)
+	}
+	if c.NineP.Enabled && c.NineP.Address != "" {
+		list = append(list, "9p.address "+c.NineP.Address)
+	}
+	if c.BootstrapServer.Enabled && c.BootstrapServer.ClearnetEnabled && c.BootstrapServer.ClearnetPort > 0 {
+		list = append(list, fmt.Sprintf("bootstrap_server.clearnet_port %d", c.BootstrapServer.ClearnetPort))
+	}
+	return list
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "profiles.json")
+	data := `{
+  "settings": {"transport.tcp_port": 0, "bootstrap_nodes": "node.example:33445:` + strings.Repeat("AB", 32) + `"},
+  "profiles": [
+    {"name": "alpha", "profile": "alpha"},
+    {"name": "beta", "profile": "/srv/beta", "disabled": true, "settings": {"name": "Beta bot", "transport.tcp_port": 33446}}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	m, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if got := m.Profiles[0].Dir; got != filepath.Join(dir, "alpha") {
+		t.Errorf("Relative profile directory = %q, want it inside %s", got, dir)
+	}
+	if got := m.Profiles[1].Dir; got != "/srv/beta" || !m.Profiles[1].Disabled {
+		t.Errorf("Unexpected second profile: %+v", m.Profiles[1])
+	}
+
+	// Shared settings come first so the profile's own win
+	want := []Override{
+		{Path: "bootstrap_nodes", Value: "node.example:33445:" + strings.Repeat("AB", 32), Source: SourceManifest},
+		{Path: "transport.tcp_port", Value: "0", Source: SourceManifest},
+		{Path: "name", Value: "Beta bot", Source: SourceManifest},
+		{Path: "transport.tcp_port", Value: "33446", Source: SourceManifest},
+	}
+	if got := m.Overrides(m.Profiles[1]); !reflect.DeepEqual(got, want) {
+		t.Errorf("Overrides = %+v, want %+v", got, want)
+	}
+
+	cfg, err := Read(t.TempDir())
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if err := cfg.Apply(m.Overrides(m.Profiles[1])); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if cfg.Transport.TCPPort != 33446 || cfg.Name != "Beta bot" || cfg.Source("name") != SourceManifest {
+		t.Errorf("Unexpected configuration: tcp_port %d, name %q from %s", cfg.Transport.TCPPort, cfg.Name, cfg.Source("name"))
+	}
+}
+
+func TestReadManifestInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"no profiles", `{"profiles": []}`, "no profiles"},
+		{"unknown field", `{"profiles": [{"name": "a", "profile": "a", "enabled": true}]}`, "unknown field"},
+		{"bad name", `{"profiles": [{"name": "a/b", "profile": "a"}]}`, "profile name"},
+		{"duplicate name", `{"profiles": [{"name": "a", "profile": "a"}, {"name": "a", "profile": "b"}]}`, "duplicate profile name"},
+		{"missing directory", `{"profiles": [{"name": "a"}]}`, "no profile directory"},
+		{"shared directory", `{"profiles": [{"name": "a", "profile": "x"}, {"name": "b", "profile": "x/"}]}`, "share the directory"},
+		{"unknown setting", `{"profiles": [{"name": "a", "profile": "a", "settings": {"nope": 1}}]}`, "unknown setting"},
+		{"bad shared value", `{"settings": {"debug": "maybe"}, "profiles": [{"name": "a", "profile": "a"}]}`, "invalid setting debug"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "profiles.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			_, err := ReadManifest(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadManifest error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListeners(t *testing.T) {
+	cfg := &Config{
+		Transport:       TransportConfig{TCPPort: 33445},
+		HTTP:            HTTPConfig{Enabled: true, Address: "127.0.0.1:8777"},
+		NineP:           NinePConfig{Enabled: true},
+		BootstrapServer: BootstrapServerConfig{Enabled: true, ClearnetEnabled: true, ClearnetPort: 33450},
+	}
+	want := []string{"transport.tcp_port 33445", "http.address 127.0.0.1:8777", "bootstrap_server.clearnet_port 33450"}
+	if got := cfg.Listeners(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Listeners = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+// Package config implements configuration schema migration for ratox-go
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// CurrentVersion is the config.json schema version this build writes
+const CurrentVersion = 1
+
+// migrations[v] upgrades a version v file to version v+1. The file is
+// decoded as a generic JSON object so fields that no longer exist in Config
+// can still be read.
+var migrations = []func(raw map[string]interface{}) error{
+	migrateV0,
+}
+
+// migration describes the upgrade of an older file
+type migration struct {
+	from     int
+	original []byte
+}
+
+// migrate upgrades data to CurrentVersion, returning the migration that was
+// applied or nil if the file was current
+func migrate(data []byte) ([]byte, *migration, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	switch {
+	case header.Version < 0:
+		return nil, nil, fmt.Errorf("config file has invalid version %d", header.Version)
+	case header.Version > CurrentVersion:
+		return nil, nil, fmt.Errorf("config file is version %d, but this ratox-go only understands up to version %d: upgrade ratox-go or restore a backup of %s",
+			header.Version, CurrentVersion, ConfigFileName)
+	case header.Version == CurrentVersion:
+		return data, nil, nil
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	for version := header.Version; version < CurrentVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate config from version %d: %w", version, err)
+		}
+	}
+	raw["version"] = CurrentVersion
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode migrated config: %w", err)
+	}
+	return migrated, &migration{from: header.Version, original: data}, nil
+}
+
+// backup writes the original file next to configFile as
+// config.json.v<version>.bak. When a backup of the same version was left by
+// an earlier migration, the next free config.json.v<version>.bak.<n> is used.
+func (m *migration) backup(configFile string) (string, error) {
+	base := fmt.Sprintf("%s.v%d.bak", configFile, m.from)
+
+	backupFile := base
+	file, err := os.OpenFile(backupFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	for n := 1; errors.Is(err, os.ErrExist); n++ {
+		backupFile = fmt.Sprintf("%s.%d", base, n)
+		file, err = os.OpenFile(backupFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create config backup: %w", err)
+	}
+	if _, err := file.Write(m.original); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write config backup: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write config backup: %w", err)
+	}
+	return backupFile, nil
+}
+
+// migrateV0 upgrades files written before the version field existed. Those
+// could hold null for unset lists and sections, such as "bootstrap_nodes":
+// null, which left the client without bootstrap nodes. Dropping the nulls
+// lets the defaults apply instead.
+func migrateV0(raw map[string]interface{}) error {
+	for key, value := range raw {
+		if value == nil {
+			delete(raw, key)
+		}
+	}
+	return nil
+}
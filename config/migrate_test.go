@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsCoverEveryVersion(t *testing.T) {
+	if len(migrations) != CurrentVersion {
+		t.Errorf("Have %d migrations, want one per version below %d", len(migrations), CurrentVersion)
+	}
+}
+
+func TestLoadMigratesOldConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, ConfigFileName)
+	original := `{"name": "alice", "bootstrap_nodes": null}`
+	if err := os.WriteFile(configFile, []byte(original), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Load(tempDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Version = %d, want %d", cfg.Version, CurrentVersion)
+	}
+	if cfg.Name != "alice" {
+		t.Errorf("Name = %q, want %q", cfg.Name, "alice")
+	}
+	if len(cfg.BootstrapNodes) == 0 {
+		t.Error("Expected the default bootstrap nodes in place of null")
+	}
+
+	if _, err := os.Stat(configFile + ".v0.bak"); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup before Persist, stat error = %v", err)
+	}
+	if err := cfg.Persist(); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+
+	backup, err := os.ReadFile(configFile + ".v0.bak")
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if string(backup) != original {
+		t.Errorf("Backup = %q, want the original %q", backup, original)
+	}
+
+	saved, err := Read(tempDir)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if saved.Version != CurrentVersion {
+		t.Errorf("Saved version = %d, want %d", saved.Version, CurrentVersion)
+	}
+}
+
+func TestMigrationBackupKeepsEarlierBackups(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ConfigFileName)
+	if err := os.WriteFile(configFile+".v0.bak", []byte("first"), 0o600); err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
+	}
+
+	for _, want := range []string{".v0.bak.1", ".v0.bak.2"} {
+		m := &migration{from: 0, original: []byte("next")}
+		backupFile, err := m.backup(configFile)
+		if err != nil {
+			t.Fatalf("backup failed: %v", err)
+		}
+		if backupFile != configFile+want {
+			t.Errorf("Backup file = %s, want %s", backupFile, configFile+want)
+		}
+		if data, _ := os.ReadFile(backupFile); string(data) != "next" {
+			t.Errorf("Backup = %q, want %q", data, "next")
+		}
+	}
+
+	if data, _ := os.ReadFile(configFile + ".v0.bak"); string(data) != "first" {
+		t.Errorf("Earlier backup = %q, want it unchanged", data)
+	}
+}
+
+func TestReadRejectsNewerConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	data := `{"version": 99}`
+	if err := os.WriteFile(filepath.Join(tempDir, ConfigFileName), []byte(data), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, err := Load(tempDir)
+	if err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Errorf("Load() error = %v, want a version error", err)
+	}
+
+	// The newer file is left untouched
+	if got, _ := os.ReadFile(filepath.Join(tempDir, ConfigFileName)); string(got) != data {
+		t.Errorf("Config file = %q, want it unchanged", got)
+	}
+}
+
+func TestReadDoesNotBackUp(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, ConfigFileName)
+	if err := os.WriteFile(configFile, []byte(`{"name": "bob"}`), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := Read(tempDir)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Version = %d, want %d", cfg.Version, CurrentVersion)
+	}
+	if _, err := os.Stat(configFile + ".v0.bak"); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup from Read, stat error = %v", err)
+	}
+}
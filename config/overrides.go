@@ -0,0 +1,348 @@
+// Package config implements environment and command line overrides for ratox-go
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the name of every environment variable overriding a setting
+const EnvPrefix = "RATOX_"
+
+// Source says where the effective value of a setting came from. Later
+// sources take precedence: flags over the environment over the manifest over
+// the file over the defaults.
+type Source string
+
+// Setting sources, lowest precedence first
+const (
+	SourceDefault  Source = "default"
+	SourceFile     Source = "file"
+	SourceManifest Source = "manifest"
+	SourceEnv      Source = "env"
+	SourceFlag     Source = "flag"
+)
+
+// Setting is one overridable field of config.json
+type Setting struct {
+	// Path is the field's JSON path, e.g. "http.address"
+	Path string
+	// Env is the overriding environment variable, e.g. RATOX_HTTP_ADDRESS
+	Env string
+	// Flag is the overriding command line flag, e.g. http-address
+	Flag string
+
+	index []int
+	kind  reflect.Type
+}
+
+// Override sets a setting from the environment or the command line
+type Override struct {
+	Path   string
+	Value  string
+	Source Source
+}
+
+// override remembers the value an override replaced, so Save can write the
+// file's own value back
+type override struct {
+	value    interface{}
+	original interface{}
+}
+
+// settings lists every setting in config.json order
+var settings = collectSettings(reflect.TypeOf(Config{}), "", nil)
+
+// collectSettings walks the JSON fields of t. Nested sections become dotted
+// paths; the schema version is not a setting.
+func collectSettings(t reflect.Type, prefix string, index []int) []Setting {
+	var list []Setting
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || (prefix == "" && name == "version") {
+			continue
+		}
+
+		path := prefix + name
+		fieldIndex := append(append([]int(nil), index...), i)
+		if field.Type.Kind() == reflect.Struct {
+			list = append(list, collectSettings(field.Type, path+".", fieldIndex)...)
+			continue
+		}
+
+		list = append(list, Setting{
+			Path:  path,
+			Env:   EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_")),
+			Flag:  strings.NewReplacer(".", "-", "_", "-").Replace(path),
+			index: fieldIndex,
+			kind:  field.Type,
+		})
+	}
+	return list
+}
+
+// Settings returns every setting that can be overridden
+func Settings() []Setting {
+	return append([]Setting(nil), settings...)
+}
+
+// lookupSetting finds the setting at path
+func lookupSetting(path string) (Setting, bool) {
+	for _, s := range settings {
+		if s.Path == path {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// isBool reports whether the setting is a switch
+func (s Setting) isBool() bool {
+	return s.kind.Kind() == reflect.Bool
+}
+
+// parse converts value to the setting's type. Bootstrap nodes are written as
+// a JSON array or as comma separated address:port:public_key entries.
+func (s Setting) parse(value string) (reflect.Value, error) {
+	v := reflect.New(s.kind).Elem()
+
+	switch s.kind.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return v, fmt.Errorf("%q is not a boolean", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, s.kind.Bits())
+		if err != nil {
+			return v, fmt.Errorf("%q is not an integer", value)
+		}
+		v.SetInt(n)
+	case reflect.Uint16:
+		n, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return v, fmt.Errorf("%q is not a port number", value)
+		}
+		v.SetUint(n)
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Slice:
+		nodes, err := parseBootstrapNodes(value)
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.ValueOf(nodes))
+	default:
+		return v, fmt.Errorf("cannot override a %s", s.kind)
+	}
+	return v, nil
+}
+
+// parseBootstrapNodes parses a JSON array or address:port:public_key list
+func parseBootstrapNodes(value string) ([]BootstrapNode, error) {
+	var nodes []BootstrapNode
+	// A bracketed IPv6 address also starts with "[", so only valid JSON is
+	// taken as an array
+	if strings.HasPrefix(strings.TrimSpace(value), "[") && json.Valid([]byte(value)) {
+		if err := json.Unmarshal([]byte(value), &nodes); err != nil {
+			return nil, fmt.Errorf("invalid bootstrap node list: %w", err)
+		}
+		return nodes, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		// The address may be an IPv6 address, so split from the right
+		rest, key, ok1 := cutLast(strings.TrimSpace(entry), ":")
+		address, port, ok2 := cutLast(rest, ":")
+		n, err := strconv.ParseUint(port, 10, 16)
+		if !ok1 || !ok2 || err != nil {
+			return nil, fmt.Errorf("bootstrap node %q is not address:port:public_key", entry)
+		}
+		nodes = append(nodes, BootstrapNode{
+			Address:   strings.Trim(address, "[]"),
+			Port:      uint16(n),
+			PublicKey: key,
+		})
+	}
+	return nodes, nil
+}
+
+// cutLast slices s around the last sep
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// field returns the setting's field in c
+func (c *Config) field(s Setting) reflect.Value {
+	return reflect.ValueOf(c).Elem().FieldByIndex(s.index)
+}
+
+// Value returns the effective value of the setting at path as JSON
+func (c *Config) Value(path string) string {
+	s, ok := lookupSetting(path)
+	if !ok {
+		return ""
+	}
+	data, err := json.Marshal(c.field(s).Interface())
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Source returns where the effective value of the setting at path came from
+func (c *Config) Source(path string) Source {
+	if source, ok := c.sources[path]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// setSource records where the value of the setting at path came from
+func (c *Config) setSource(path string, source Source) {
+	if c.sources == nil {
+		c.sources = make(map[string]Source)
+	}
+	c.sources[path] = source
+}
+
+// recordFileSources marks the settings present in the config file data
+func (c *Config) recordFileSources(data []byte) {
+	for _, s := range settings {
+		if jsonHasPath(data, strings.Split(s.Path, ".")) {
+			c.setSource(s.Path, SourceFile)
+		}
+	}
+}
+
+// jsonHasPath reports whether the JSON object in data has the nested key path
+func jsonHasPath(data []byte, path []string) bool {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return false
+	}
+	value, ok := object[path[0]]
+	if !ok {
+		return false
+	}
+	return len(path) == 1 || jsonHasPath(value, path[1:])
+}
+
+// Apply sets the overridden settings in order, so later overrides win
+func (c *Config) Apply(overrides []Override) error {
+	for _, o := range overrides {
+		s, ok := lookupSetting(o.Path)
+		if !ok {
+			return fmt.Errorf("unknown setting %q", o.Path)
+		}
+		value, err := s.parse(o.Value)
+		if err != nil {
+			return fmt.Errorf("invalid %s override of %s: %w", o.Source, o.Path, err)
+		}
+
+		field := c.field(s)
+		if c.overridden == nil {
+			c.overridden = make(map[string]override)
+		}
+		previous, ok := c.overridden[o.Path]
+		if !ok {
+			previous.original = field.Interface()
+		}
+		field.Set(value)
+		c.overridden[o.Path] = override{value: value.Interface(), original: previous.original}
+
+		c.setSource(o.Path, o.Source)
+		c.applied = append(c.applied, o)
+	}
+	return nil
+}
+
+// Overrides returns the overrides applied to c, for applying to a reloaded
+// configuration
+func (c *Config) Overrides() []Override {
+	return append([]Override(nil), c.applied...)
+}
+
+// fileView returns the configuration as it should be saved: settings still
+// holding their override value are written with the value they replaced
+func (c *Config) fileView() *Config {
+	if len(c.overridden) == 0 {
+		return c
+	}
+
+	view := *c
+	for path, o := range c.overridden {
+		s, _ := lookupSetting(path)
+		if reflect.DeepEqual(view.field(s).Interface(), o.value) {
+			view.field(s).Set(reflect.ValueOf(o.original))
+		}
+	}
+	return &view
+}
+
+// EnvOverrides returns the overrides set by RATOX_* environment variables,
+// looked up with lookup such as os.LookupEnv
+func EnvOverrides(lookup func(string) (string, bool)) []Override {
+	var overrides []Override
+	for _, s := range settings {
+		if value, ok := lookup(s.Env); ok {
+			overrides = append(overrides, Override{Path: s.Path, Value: value, Source: SourceEnv})
+		}
+	}
+	return overrides
+}
+
+// FlagOverrides collects the overrides given as command line flags
+type FlagOverrides struct {
+	overrides []Override
+}
+
+// RegisterFlags adds a flag for every setting to fs
+func RegisterFlags(fs *flag.FlagSet) *FlagOverrides {
+	f := &FlagOverrides{}
+	for _, s := range settings {
+		fs.Var(&settingFlag{setting: s, overrides: f}, s.Flag, fmt.Sprintf("Override %s (env %s)", s.Path, s.Env))
+	}
+	return f
+}
+
+// Overrides returns the flags given, in command line order
+func (f *FlagOverrides) Overrides() []Override {
+	return append([]Override(nil), f.overrides...)
+}
+
+// settingFlag is the flag.Value of one setting
+type settingFlag struct {
+	setting   Setting
+	overrides *FlagOverrides
+	value     string
+}
+
+func (sf *settingFlag) String() string {
+	return sf.value
+}
+
+// Set checks the value and records the override
+func (sf *settingFlag) Set(value string) error {
+	if _, err := sf.setting.parse(value); err != nil {
+		return err
+	}
+	sf.value = value
+	sf.overrides.overrides = append(sf.overrides.overrides, Override{Path: sf.setting.Path, Value: value, Source: SourceFlag})
+	return nil
+}
+
+// IsBoolFlag lets switches be given without a value
+func (sf *settingFlag) IsBoolFlag() bool {
+	return sf.setting.isBool()
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSettingNames(t *testing.T) {
+	tests := []struct {
+		path, env, flag string
+	}{
+		{"debug", "RATOX_DEBUG", "debug"},
+		{"max_file_size", "RATOX_MAX_FILE_SIZE", "max-file-size"},
+		{"http.address", "RATOX_HTTP_ADDRESS", "http-address"},
+		{"9p.enabled", "RATOX_9P_ENABLED", "9p-enabled"},
+		{"bootstrap_server.tor_control_addr", "RATOX_BOOTSTRAP_SERVER_TOR_CONTROL_ADDR", "bootstrap-server-tor-control-addr"},
+	}
+
+	for _, tt := range tests {
+		s, ok := lookupSetting(tt.path)
+		if !ok {
+			t.Errorf("No setting %q", tt.path)
+			continue
+		}
+		if s.Env != tt.env || s.Flag != tt.flag {
+			t.Errorf("Setting %q env, flag = %q, %q, want %q, %q", tt.path, s.Env, s.Flag, tt.env, tt.flag)
+		}
+	}
+
+	if _, ok := lookupSetting("version"); ok {
+		t.Error("The schema version should not be a setting")
+	}
+}
+
+func TestOverridePrecedence(t *testing.T) {
+	tempDir := t.TempDir()
+	data := `{"name": "file", "max_file_size": 10, "http": {"address": "127.0.0.1:1"}}`
+	if err := os.WriteFile(filepath.Join(tempDir, ConfigFileName), []byte(data), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	env := map[string]string{
+		"RATOX_NAME":          "env",
+		"RATOX_MAX_FILE_SIZE": "20",
+		"RATOX_UNRELATED":     "x",
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	if err := fs.Parse([]string{"-name", "flag", "-auto-accept-files"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	cfg, err := Load(tempDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	if err := cfg.Apply(append(EnvOverrides(lookup), flags.Overrides()...)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		value  string
+		source Source
+	}{
+		{"name", `"flag"`, SourceFlag},
+		{"auto_accept_files", "true", SourceFlag},
+		{"max_file_size", "20", SourceEnv},
+		{"http.address", `"127.0.0.1:1"`, SourceFile},
+		{"status_message", `"Running ratox-go"`, SourceDefault},
+	}
+	for _, tt := range tests {
+		if got := cfg.Value(tt.path); got != tt.value {
+			t.Errorf("Value(%q) = %s, want %s", tt.path, got, tt.value)
+		}
+		if got := cfg.Source(tt.path); got != tt.source {
+			t.Errorf("Source(%q) = %s, want %s", tt.path, got, tt.source)
+		}
+	}
+
+	// Saving keeps the file's own values for overridden settings, but not
+	// for settings changed since
+	cfg.MaxFileSize = 30
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	saved, err := Read(tempDir)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if saved.Name != "file" || saved.AutoAcceptFiles || saved.MaxFileSize != 30 {
+		t.Errorf("Saved name, auto_accept_files, max_file_size = %q, %v, %d, want \"file\", false, 30",
+			saved.Name, saved.AutoAcceptFiles, saved.MaxFileSize)
+	}
+}
+
+func TestApplyRejectsInvalidValues(t *testing.T) {
+	cfg, err := Read(t.TempDir())
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	for _, o := range []Override{
+		{Path: "debug", Value: "maybe", Source: SourceEnv},
+		{Path: "transport.tcp_port", Value: "70000", Source: SourceEnv},
+		{Path: "bootstrap_nodes", Value: "no-port", Source: SourceFlag},
+		{Path: "missing", Value: "1", Source: SourceFlag},
+	} {
+		if err := cfg.Apply([]Override{o}); err == nil {
+			t.Errorf("Apply(%s=%q) succeeded, want an error", o.Path, o.Value)
+		}
+	}
+}
+
+func TestParseBootstrapNodes(t *testing.T) {
+	key := "6FC41E2BD381D37E9748FC0E0328CE086AF9598BECC8FEB7DDF2E440475F300E"
+
+	tests := []struct {
+		name  string
+		value string
+		want  []BootstrapNode
+	}{
+		{"one node", "nodes.tox.chat:33445:" + key, []BootstrapNode{{"nodes.tox.chat", 33445, key}}},
+		{"IPv6 and spaces", "[::1]:1:" + key + ", 10.0.0.1:2:" + key, []BootstrapNode{{"::1", 1, key}, {"10.0.0.1", 2, key}}},
+		{"JSON", `[{"address": "a", "port": 3, "public_key": "` + key + `"}]`, []BootstrapNode{{"a", 3, key}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBootstrapNodes(tt.value)
+			if err != nil {
+				t.Fatalf("parseBootstrapNodes failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBootstrapNodes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
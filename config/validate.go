@@ -0,0 +1,164 @@
+// Package config implements configuration validation for ratox-go
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Limits on the user's own profile, as enforced by Tox
+const (
+	// MaxNameLength is the maximum length of the display name in bytes
+	MaxNameLength = 128
+	// MaxStatusMessageLength is the maximum length of the status message in bytes
+	MaxStatusMessageLength = 1007
+)
+
+// FieldError is a problem with one configuration field
+type FieldError struct {
+	// Path locates the field in config.json, e.g. "bootstrap_nodes[0].port"
+	Path    string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = field.Error()
+	}
+	return "invalid configuration: " + strings.Join(problems, "; ")
+}
+
+// validator collects field errors
+type validator struct {
+	fields []*FieldError
+}
+
+// fail records a problem with the field at path
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.fields = append(v.fields, &FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// address checks that addr is a host:port with a non-zero port, and a
+// loopback host if loopback is set
+func (v *validator) address(path, addr string, loopback bool) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		v.fail(path, "%q is not a host:port address", addr)
+		return
+	}
+	if host == "" {
+		v.fail(path, "%q has no host", addr)
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		v.fail(path, "%q has an invalid port", addr)
+	}
+	if loopback && host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			v.fail(path, "%q is not a loopback address", addr)
+		}
+	}
+}
+
+// Validate checks every setting and reports all problems together as a
+// *ValidationError
+func (c *Config) Validate() error {
+	if err := c.ValidateTransport(); err != nil {
+		return err
+	}
+
+	v := &validator{}
+
+	if len(c.Name) > MaxNameLength {
+		v.fail("name", "must be at most %d bytes, got %d", MaxNameLength, len(c.Name))
+	}
+	if len(c.StatusMessage) > MaxStatusMessageLength {
+		v.fail("status_message", "must be at most %d bytes, got %d", MaxStatusMessageLength, len(c.StatusMessage))
+	}
+	if c.MaxFileSize < 0 {
+		v.fail("max_file_size", "must not be negative, got %d", c.MaxFileSize)
+	}
+	if c.NospamRotationHours < 0 {
+		v.fail("nospam_rotation_hours", "must not be negative, got %d", c.NospamRotationHours)
+	}
+	if c.SaveDataBackups < 0 {
+		v.fail("savedata_backups", "must not be negative, got %d", c.SaveDataBackups)
+	}
+
+	if c.HTTP.Enabled {
+		v.address("http.address", c.HTTP.Address, true)
+	}
+	if c.NineP.Address != "" {
+		v.address("9p.address", c.NineP.Address, true)
+	}
+
+	switch c.Frontend {
+	case "", FrontendFIFO, FrontendFUSE:
+	default:
+		v.fail("frontend", "must be %q or %q, got %q", FrontendFIFO, FrontendFUSE, c.Frontend)
+	}
+
+	for i, node := range c.BootstrapNodes {
+		path := fmt.Sprintf("bootstrap_nodes[%d]", i)
+		if strings.TrimSpace(node.Address) == "" {
+			v.fail(path+".address", "must not be empty")
+		}
+		if node.Port == 0 {
+			v.fail(path+".port", "must not be zero")
+		}
+		if key, err := hex.DecodeString(node.PublicKey); err != nil || len(key) != 32 {
+			v.fail(path+".public_key", "must be 64 hex characters, got %q", node.PublicKey)
+		}
+	}
+
+	c.validateTransportFields(v)
+	c.validateBootstrapServer(v)
+
+	if len(v.fields) > 0 {
+		return &ValidationError{Fields: v.fields}
+	}
+	return nil
+}
+
+// validateTransportFields checks the transport section
+func (c *Config) validateTransportFields(v *validator) {
+	t := c.Transport
+	if t.TCPEnabled && t.TCPPort == 0 {
+		v.fail("transport.tcp_port", "must not be zero when tcp_enabled is set")
+	}
+	if t.TorEnabled {
+		v.address("transport.tor_socks_addr", t.TorSOCKSAddr, false)
+	}
+	if t.I2PEnabled {
+		v.address("transport.i2p_sam_addr", t.I2PSAMAddr, false)
+	}
+}
+
+// validateBootstrapServer checks the bootstrap_server section
+func (c *Config) validateBootstrapServer(v *validator) {
+	bs := c.BootstrapServer
+	if !bs.Enabled {
+		return
+	}
+	if !bs.ClearnetEnabled && !bs.OnionEnabled && !bs.I2PEnabled {
+		v.fail("bootstrap_server", "is enabled but clearnet_enabled, onion_enabled and i2p_enabled are all false")
+	}
+	if bs.TorControlAddr != "" {
+		v.address("bootstrap_server.tor_control_addr", bs.TorControlAddr, false)
+	}
+	if bs.I2PEnabled && bs.I2PSAMAddr != "" {
+		v.address("bootstrap_server.i2p_sam_addr", bs.I2PSAMAddr, false)
+	}
+}
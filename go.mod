@@ -5,8 +5,11 @@ go 1.24.0
 toolchain go1.24.13
 
 require (
+	github.com/hanwen/go-fuse/v2 v2.9.0
 	github.com/opd-ai/toxcore v0.0.0-20260306021244-2e7de0320709
 	github.com/sirupsen/logrus v1.9.4
+	golang.org/x/crypto v0.48.0
+	golang.org/x/net v0.50.0
 )
 
 require (
@@ -18,7 +21,5 @@ require (
 	github.com/pion/opus v0.0.0-20250902022847-c2c56b95f05c // indirect
 	github.com/pion/randutil v0.1.0 // indirect
 	github.com/pion/rtp v1.8.22 // indirect
-	golang.org/x/crypto v0.48.0 // indirect
-	golang.org/x/net v0.50.0 // indirect
 	golang.org/x/sys v0.41.0 // indirect
 )
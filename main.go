@@ -3,6 +3,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -28,18 +29,47 @@ var (
 	configPath = flag.String("profile", "", "Path to configuration directory")
 	showHelp   = flag.Bool("help", false, "Show help message")
 	showVer    = flag.Bool("version", false, "Show version")
-	debug      = flag.Bool("debug", false, "Enable debug logging")
+	checkCfg   = flag.Bool("check-config", false, "Validate the configuration file and exit")
+	printCfg   = flag.Bool("print-config", false, "Print the effective configuration with the source of each value and exit")
+	changePass = flag.Bool("change-passphrase", false, "Encrypt the save file with a new passphrase and exit")
+	removePass = flag.Bool("remove-passphrase", false, "Store the save file unencrypted and exit")
+	manifest   = flag.String("manifest", "", "Run every profile listed in a manifest file instead of -profile")
+
+	// settingFlags holds a flag overriding each config.json setting, such as
+	// -debug or -http-address
+	settingFlags = config.RegisterFlags(flag.CommandLine)
 )
 
 func main() {
 	setupLogging()
+	if status, ok := runCommand(os.Args[1:]); ok {
+		os.Exit(status)
+	}
 	logrus.WithField("caller", "main").Info("Starting ratox-go application")
 
 	if parseAndValidateFlags() {
 		return
 	}
 
+	if *manifest != "" {
+		if *configPath != "" {
+			fmt.Fprintln(os.Stderr, "-manifest and -profile cannot be combined")
+			os.Exit(2)
+		}
+		os.Exit(runManifest(*manifest))
+	}
+
 	configDir := determineConfigDir()
+	if *checkCfg {
+		os.Exit(checkConfig(configDir))
+	}
+	if *printCfg {
+		os.Exit(printConfig(configDir))
+	}
+	if *changePass || *removePass {
+		os.Exit(changePassphrase(configDir, *removePass))
+	}
+
 	cfg := loadOrCreateConfig(configDir)
 	toxClient := createToxClient(cfg)
 	runClientWithGracefulShutdown(toxClient)
@@ -140,6 +170,21 @@ func loadOrCreateConfig(configDir string) *config.Config {
 		}).Fatal("Failed to load configuration")
 	}
 
+	if err := applyOverrides(cfg); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"caller":     "main",
+			"config_dir": configDir,
+			"error":      err,
+		}).Fatal("Invalid configuration")
+	}
+	for _, o := range cfg.Overrides() {
+		logrus.WithFields(logrus.Fields{
+			"caller":  "main",
+			"setting": o.Path,
+			"source":  o.Source,
+		}).Info("Configuration setting overridden")
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"caller":            "main",
 		"debug":             cfg.Debug,
@@ -147,18 +192,100 @@ func loadOrCreateConfig(configDir string) *config.Config {
 		"auto_accept_files": cfg.AutoAcceptFiles,
 	}).Info("Configuration loaded successfully")
 
-	if *debug {
-		logrus.WithField("caller", "main").Info("Debug logging enabled via command line")
-		cfg.Debug = true
-		logrus.SetLevel(logrus.DebugLevel)
-	} else if cfg.Debug {
-		logrus.WithField("caller", "main").Info("Debug logging enabled via configuration")
+	if cfg.Debug {
+		logrus.WithFields(logrus.Fields{
+			"caller": "main",
+			"source": cfg.Source("debug"),
+		}).Info("Debug logging enabled")
 		logrus.SetLevel(logrus.DebugLevel)
 	}
 
+	passphrase, err := profilePassphrase(cfg)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"caller": "main",
+			"error":  err,
+		}).Fatal("Failed to read passphrase")
+	}
+	cfg.Passphrase = passphrase
+
 	return cfg
 }
 
+// overrides returns the RATOX_* environment overrides followed by the
+// command line ones, which take precedence
+func overrides() []config.Override {
+	return append(config.EnvOverrides(os.LookupEnv), settingFlags.Overrides()...)
+}
+
+// applyOverrides applies the environment and command line overrides to cfg
+// and validates the result. Startup and -check-config both go through it, so
+// a configuration that checks out also starts.
+func applyOverrides(cfg *config.Config) error {
+	if err := cfg.Apply(overrides()); err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
+
+// readEffectiveConfig reads the configuration with the overrides applied,
+// without changing the file
+func readEffectiveConfig(configDir string) (*config.Config, error) {
+	cfg, err := config.Read(configDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Apply(overrides()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// printConfig prints every setting of the effective configuration with the
+// source of its value, and returns the process exit status
+func printConfig(configDir string) int {
+	cfg, err := readEffectiveConfig(configDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Join(configDir, config.ConfigFileName), err)
+		return 1
+	}
+
+	for _, s := range config.Settings() {
+		fmt.Printf("%s = %s (%s)\n", s.Path, cfg.Value(s.Path), cfg.Source(s.Path))
+	}
+	return 0
+}
+
+// checkConfig validates the configuration file without changing it, printing
+// every problem, and returns the process exit status
+func checkConfig(configDir string) int {
+	logrus.WithFields(logrus.Fields{
+		"caller":     "main",
+		"config_dir": configDir,
+	}).Debug("Checking configuration")
+
+	cfg, err := config.Read(configDir)
+	if err == nil {
+		err = applyOverrides(cfg)
+	}
+
+	configFile := filepath.Join(configDir, config.ConfigFileName)
+	if err == nil {
+		fmt.Printf("%s: ok\n", configFile)
+		return 0
+	}
+
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, field := range validationErr.Fields {
+			fmt.Printf("%s: %s\n", configFile, field)
+		}
+	} else {
+		fmt.Printf("%s: %v\n", configFile, err)
+	}
+	return 1
+}
+
 func createToxClient(cfg *config.Config) *client.Client {
 	logrus.WithFields(logrus.Fields{
 		"caller":    "main",
@@ -180,7 +307,7 @@ func createToxClient(cfg *config.Config) *client.Client {
 func runClientWithGracefulShutdown(toxClient *client.Client) {
 	logrus.WithField("caller", "main").Debug("Setting up signal handlers")
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	logrus.WithField("caller", "main").Info("Starting Tox client in background goroutine")
 	errChan := make(chan error, 1)
@@ -191,25 +318,48 @@ func runClientWithGracefulShutdown(toxClient *client.Client) {
 	}()
 
 	logrus.WithField("caller", "main").Info("Waiting for shutdown signal or client error")
-	select {
-	case err := <-errChan:
-		if err != nil {
+	for {
+		select {
+		case err := <-errChan:
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"caller": "main",
+					"error":  err,
+				}).Fatal("Client error occurred")
+			}
+			logrus.WithField("caller", "main").Info("Client completed without error")
+			return
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				reloadConfig(toxClient)
+				continue
+			}
+
 			logrus.WithFields(logrus.Fields{
 				"caller": "main",
-				"error":  err,
-			}).Fatal("Client error occurred")
+				"signal": sig,
+			}).Info("Received shutdown signal")
+
+			logrus.WithField("caller", "main").Info("Initiating client shutdown")
+			toxClient.Shutdown()
+			logrus.WithField("caller", "main").Info("Client shutdown completed")
+			return
 		}
-		logrus.WithField("caller", "main").Info("Client completed without error")
-	case sig := <-sigChan:
+	}
+}
+
+func reloadConfig(toxClient *client.Client) {
+	logrus.WithField("caller", "main").Info("Received SIGHUP, reloading configuration")
+
+	if err := toxClient.ReloadConfig(); err != nil {
 		logrus.WithFields(logrus.Fields{
 			"caller": "main",
-			"signal": sig,
-		}).Info("Received shutdown signal")
-
-		logrus.WithField("caller", "main").Info("Initiating client shutdown")
-		toxClient.Shutdown()
-		logrus.WithField("caller", "main").Info("Client shutdown completed")
+			"error":  err,
+		}).Error("Failed to reload configuration")
+		return
 	}
+
+	logrus.WithField("caller", "main").Info("Configuration reloaded")
 }
 
 func printUsage() {
@@ -217,12 +367,25 @@ func printUsage() {
 
 	fmt.Printf("ratox-go %s - FIFO-based Tox client\n\n", Version)
 	fmt.Println("Usage:")
-	fmt.Printf("  %s [options]\n\n", os.Args[0])
-	fmt.Println("Options:")
+	fmt.Printf("  %s [options]\n", os.Args[0])
+	fmt.Printf("  %s <command> [options] [arguments]\n\n", os.Args[0])
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-40s %s\n", cmd.usage, cmd.summary)
+	}
+	fmt.Println("\nOptions:")
 	flag.PrintDefaults()
 	fmt.Println("\nExamples:")
 	fmt.Printf("  %s -p ~/.config/ratox-go\n", os.Args[0])
 	fmt.Printf("  %s -d  # Enable debug logging\n", os.Args[0])
+	fmt.Printf("  %s -frontend fuse  # Mount the tree as a FUSE filesystem\n", os.Args[0])
+	fmt.Printf("  %s -check-config  # Validate config.json and exit\n", os.Args[0])
+	fmt.Printf("  %s -http-enabled -http-address 127.0.0.1:9000  # Override config.json\n", os.Args[0])
+	fmt.Printf("  RATOX_AUTO_ACCEPT_FILES=true %s  # Override config.json from the environment\n", os.Args[0])
+	fmt.Printf("  %s -print-config  # Show the effective settings and their sources\n", os.Args[0])
+	fmt.Printf("  %s -change-passphrase  # Encrypt ratox.tox with a passphrase\n", os.Args[0])
+	fmt.Printf("  %s export -encrypt profile.tar.gz  # Back up the profile to move it elsewhere\n", os.Args[0])
+	fmt.Printf("  %s -manifest /etc/ratox-go/profiles.json  # Run several profiles in one process\n", os.Args[0])
 	fmt.Println("\nFileSystem Interface:")
 	fmt.Println("  ~/.config/ratox-go/")
 	fmt.Println("  ├── <friend_id>/")
@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/opd-ai/go-ratox/config"
 )
 
 // TestPrintUsage tests the printUsage function output
@@ -368,3 +370,142 @@ func BenchmarkPrintUsage(b *testing.B) {
 		printUsage()
 	}
 }
+
+// TestCheckConfig tests the exit status of -check-config
+func TestCheckConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		env    string
+		want   int
+	}{
+		{"no config file", "", "", 0},
+		{"valid", `{"name": "alice"}`, "", 0},
+		{"malformed", `{"name": `, "", 1},
+		{"invalid", `{"max_file_size": -1}`, "", 1},
+		{"invalid value overridden", `{"max_file_size": -1}`, "1048576", 0},
+		{"invalid override", `{"name": "alice"}`, "-1", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("RATOX_MAX_FILE_SIZE", tt.env)
+			}
+			dir := t.TempDir()
+			if tt.config != "" {
+				if err := os.WriteFile(filepath.Join(dir, config.ConfigFileName), []byte(tt.config), 0o600); err != nil {
+					t.Fatalf("Failed to write config: %v", err)
+				}
+			}
+
+			if got := checkConfig(dir); got != tt.want {
+				t.Errorf("checkConfig() = %d, want %d", got, tt.want)
+			}
+			if tt.config == "" {
+				if _, err := os.Stat(filepath.Join(dir, config.ConfigFileName)); !os.IsNotExist(err) {
+					t.Error("checkConfig created a config file")
+				}
+			}
+		})
+	}
+}
+
+func TestProfilePassphrase(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		setEnv   bool
+		file     string
+		contents string
+		want     string
+	}{
+		{"none", "", false, "", "", ""},
+		{"file", "", false, "passphrase", "from file\nignored\n", "from file"},
+		{"environment wins", "from env", true, "passphrase", "from file\n", "from env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(config.PassphraseEnv, tt.env)
+			if !tt.setEnv {
+				os.Unsetenv(config.PassphraseEnv)
+			}
+
+			dir := t.TempDir()
+			cfg, err := config.Read(dir)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if tt.file != "" {
+				cfg.PassphraseFile = tt.file
+				if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.contents), 0o600); err != nil {
+					t.Fatalf("Failed to write passphrase file: %v", err)
+				}
+			}
+
+			got, err := profilePassphrase(cfg)
+			if err != nil {
+				t.Fatalf("profilePassphrase failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("profilePassphrase() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		want   int
+		wantOK bool
+	}{
+		{"no arguments", nil, 0, false},
+		{"flag", []string{"-debug"}, 0, false},
+		{"unknown word", []string{"frobnicate"}, 0, false},
+		{"export without file", []string{"export"}, 2, true},
+		{"import missing file", []string{"import", filepath.Join(t.TempDir(), "missing.tar.gz")}, 1, true},
+		{"id with argument", []string{"id", "extra"}, 2, true},
+		{"send without friend", []string{"send"}, 2, true},
+		{"id without identity", []string{"id", "-profile", t.TempDir()}, 1, true},
+		{"send while not running", []string{"send", "-profile", t.TempDir(), "alice", "hi"}, 1, true},
+		{"add while not running", []string{"add", "-profile", t.TempDir(), "ABCD"}, 1, true},
+	}
+
+	// -profile sets the global configuration path
+	savedPath := *configPath
+	t.Cleanup(func() { *configPath = savedPath })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := runCommand(tt.args)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("runCommand(%v) = %d, %v, want %d, %v", tt.args, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTorControlAddr(t *testing.T) {
+	tests := []struct {
+		name   string
+		server config.BootstrapServerConfig
+		want   string
+	}{
+		{"no bootstrap server", config.BootstrapServerConfig{OnionEnabled: true, TorControlAddr: "127.0.0.1:9151"}, ""},
+		{"no onion endpoint", config.BootstrapServerConfig{Enabled: true, TorControlAddr: "127.0.0.1:9151"}, ""},
+		{"configured", config.BootstrapServerConfig{Enabled: true, OnionEnabled: true, TorControlAddr: "127.0.0.1:9151"}, "127.0.0.1:9151"},
+		{"default", config.BootstrapServerConfig{Enabled: true, OnionEnabled: true}, defaultTorControlAddr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{BootstrapServer: tt.server}
+			if got := torControlAddr(cfg); got != tt.want {
+				t.Errorf("torControlAddr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+// Package main implements running several profiles in one ratox-go process
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/opd-ai/go-ratox/client"
+	"github.com/opd-ai/go-ratox/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// profileRestartDelay is how long a failed profile waits before its first
+	// restart. The delay doubles with each failure in a row.
+	profileRestartDelay = 5 * time.Second
+	// maxProfileRestartDelay bounds the restart delay; a profile that ran
+	// this long starts over from profileRestartDelay
+	maxProfileRestartDelay = 5 * time.Minute
+)
+
+// defaultTorControlAddr is the Tor control port an onion bootstrap endpoint
+// uses when its profile sets none, as found before any profile started
+var defaultTorControlAddr = cmp.Or(os.Getenv("TOR_CONTROL_ADDR"), "127.0.0.1:9051")
+
+// profileRunner runs one profile of a manifest until stopped, restarting it
+// after a failure
+type profileRunner struct {
+	profile   config.ManifestProfile
+	overrides []config.Override
+	listeners []string
+	// torControl is the Tor control port of the onion bootstrap endpoint,
+	// or "" if the profile runs none
+	torControl string
+
+	// passphrase is resolved once, when the profile is first started
+	passphrase string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	client *client.Client
+}
+
+// supervisor runs the profiles of a manifest, each independently of the others
+type supervisor struct {
+	path    string
+	runners map[string]*profileRunner
+}
+
+// runManifest runs every profile of the manifest at path until SIGINT or
+// SIGTERM, re-reading it on SIGHUP, and returns the process exit status
+func runManifest(path string) int {
+	m, err := config.ReadManifest(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	s := &supervisor{path: path, runners: make(map[string]*profileRunner)}
+	s.apply(m)
+	if len(s.runners) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"caller":   "main",
+			"manifest": path,
+		}).Error("No profile could be started")
+		return 1
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			s.reload()
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"caller": "main",
+			"signal": sig,
+		}).Info("Received shutdown signal, stopping every profile")
+		s.stopAll()
+		break
+	}
+	return 0
+}
+
+// reload re-reads the manifest, keeping the running profiles if it is invalid
+func (s *supervisor) reload() {
+	logrus.WithFields(logrus.Fields{
+		"caller":   "main",
+		"manifest": s.path,
+	}).Info("Received SIGHUP, reloading manifest")
+
+	m, err := config.ReadManifest(s.path)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"caller": "main",
+			"error":  err,
+		}).Error("Manifest reload failed, keeping the running profiles")
+		return
+	}
+	s.apply(m)
+}
+
+// apply stops the profiles that were removed, disabled or changed, starts
+// the new ones and reloads config.json of the others
+func (s *supervisor) apply(m *config.Manifest) {
+	wanted := make(map[string]config.ManifestProfile)
+	for _, p := range m.Profiles {
+		if !p.Disabled {
+			wanted[p.Name] = p
+		}
+	}
+
+	for name, r := range s.runners {
+		p, ok := wanted[name]
+		if ok && p.Dir == r.profile.Dir && reflect.DeepEqual(profileOverrides(m, p), r.overrides) {
+			continue
+		}
+		r.stop()
+		delete(s.runners, name)
+	}
+
+	for _, p := range m.Profiles {
+		if p.Disabled {
+			continue
+		}
+		if r, ok := s.runners[p.Name]; ok {
+			r.reloadConfig()
+			continue
+		}
+		s.start(p, profileOverrides(m, p))
+	}
+}
+
+// profileOverrides returns the manifest settings of p followed by the
+// environment and command line overrides, which take precedence
+func profileOverrides(m *config.Manifest, p config.ManifestProfile) []config.Override {
+	return append(m.Overrides(p), overrides()...)
+}
+
+// start loads the configuration of p and runs it in the background. Profiles
+// that cannot be loaded, or that would bind a port or address another
+// profile uses, are left stopped.
+func (s *supervisor) start(p config.ManifestProfile, list []config.Override) {
+	log := logrus.WithFields(logrus.Fields{
+		"caller":  "main",
+		"profile": p.Name,
+		"dir":     p.Dir,
+	})
+
+	r := &profileRunner{profile: p, overrides: list, done: make(chan struct{})}
+	cfg, err := r.loadConfig(true)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to start profile")
+		return
+	}
+
+	r.listeners = cfg.Listeners()
+	for _, other := range s.runners {
+		for _, listener := range r.listeners {
+			for _, used := range other.listeners {
+				if listener == used {
+					log.WithFields(logrus.Fields{
+						"listener":   listener,
+						"used_by":    other.profile.Name,
+						"resolution": "set a different value in the profile's manifest settings",
+					}).Error("Failed to start profile")
+					return
+				}
+			}
+		}
+	}
+
+	// toxcore only reads the Tor control port from the process environment,
+	// so every profile in the process has to use the same one
+	r.torControl = torControlAddr(cfg)
+	for _, other := range s.runners {
+		if r.torControl != "" && other.torControl != "" && r.torControl != other.torControl {
+			log.WithFields(logrus.Fields{
+				"tor_control_addr": r.torControl,
+				"used_by":          other.profile.Name,
+				"in_use":           other.torControl,
+				"resolution":       "use the same bootstrap_server.tor_control_addr in every profile",
+			}).Error("Failed to start profile")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	s.runners[p.Name] = r
+	go r.run(ctx, cfg)
+	log.Info("Started profile")
+}
+
+// torControlAddr returns the Tor control port the onion endpoint of cfg's
+// bootstrap server uses, or "" if it runs none
+func torControlAddr(cfg *config.Config) string {
+	bs := cfg.BootstrapServer
+	if !bs.Enabled || !bs.OnionEnabled {
+		return ""
+	}
+	return cmp.Or(bs.TorControlAddr, defaultTorControlAddr)
+}
+
+// stopAll stops every profile at once
+func (s *supervisor) stopAll() {
+	for _, r := range s.runners {
+		r.cancel()
+	}
+	for name, r := range s.runners {
+		<-r.done
+		delete(s.runners, name)
+	}
+}
+
+// loadConfig reads config.json of the profile with the overrides applied.
+// The passphrase is only looked up, and possibly prompted for, on the first
+// load.
+func (r *profileRunner) loadConfig(first bool) (*config.Config, error) {
+	if err := os.MkdirAll(r.profile.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory: %w", err)
+	}
+
+	cfg, err := config.Load(r.profile.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Apply(r.overrides); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.ProfileName = r.profile.Name
+
+	if first {
+		if r.passphrase, err = profilePassphrase(cfg); err != nil {
+			return nil, err
+		}
+	}
+	cfg.Passphrase = r.passphrase
+	return cfg, nil
+}
+
+// run runs the profile with cfg until ctx is cancelled, restarting it with a
+// freshly loaded configuration after each failure
+func (r *profileRunner) run(ctx context.Context, cfg *config.Config) {
+	defer close(r.done)
+	log := logrus.WithFields(logrus.Fields{
+		"caller":  "main",
+		"profile": r.profile.Name,
+	})
+
+	delay := profileRestartDelay
+	for {
+		started := time.Now()
+		err := r.runOnce(ctx, cfg)
+		if ctx.Err() != nil {
+			log.Info("Stopped profile")
+			return
+		}
+
+		if time.Since(started) > maxProfileRestartDelay {
+			delay = profileRestartDelay
+		}
+		log.WithFields(logrus.Fields{
+			"error":      err,
+			"restart_in": delay,
+		}).Error("Profile failed")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxProfileRestartDelay)
+		cfg = nil
+	}
+}
+
+// runOnce creates the client and runs it until it fails or ctx is cancelled.
+// A nil cfg is loaded afresh.
+func (r *profileRunner) runOnce(ctx context.Context, cfg *config.Config) error {
+	if cfg == nil {
+		var err error
+		if cfg, err = r.loadConfig(false); err != nil {
+			return err
+		}
+	}
+
+	c, err := client.New(cfg)
+	if err != nil {
+		return err
+	}
+	r.setClient(c)
+	defer r.setClient(nil)
+	defer c.Shutdown()
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- c.Run() }()
+
+	select {
+	case <-ctx.Done():
+		c.Shutdown()
+		return <-errChan
+	case err := <-errChan:
+		if err == nil {
+			err = fmt.Errorf("client stopped")
+		}
+		return err
+	}
+}
+
+// setClient records the running client, or nil
+func (r *profileRunner) setClient(c *client.Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.client = c
+}
+
+// reloadConfig re-reads config.json of the running client, as SIGHUP does
+// for a single profile
+func (r *profileRunner) reloadConfig() {
+	r.mu.Lock()
+	c := r.client
+	r.mu.Unlock()
+	if c == nil {
+		return
+	}
+
+	if err := c.ReloadConfig(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"caller":  "main",
+			"profile": r.profile.Name,
+			"error":   err,
+		}).Error("Configuration reload failed")
+	}
+}
+
+// stop stops the profile and waits for it to save and release its directory
+func (r *profileRunner) stop() {
+	r.cancel()
+	<-r.done
+}
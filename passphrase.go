@@ -0,0 +1,167 @@
+// Package main implements passphrase handling for encrypted profiles
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/opd-ai/go-ratox/client"
+	"github.com/opd-ai/go-ratox/config"
+	"github.com/sirupsen/logrus"
+)
+
+// errNoTerminal is returned when a passphrase is needed but cannot be asked for
+var errNoTerminal = errors.New("no terminal to prompt for a passphrase")
+
+// saveFileEncrypted reports whether the save file of cfg is encrypted
+func saveFileEncrypted(cfg *config.Config) bool {
+	data, err := os.ReadFile(cfg.SaveFile)
+	return err == nil && client.IsEncryptedSaveData(data)
+}
+
+// profilePassphrase returns the save file passphrase from RATOX_PASSPHRASE,
+// the passphrase_file setting or, if the save file is encrypted, a prompt. It
+// returns "" when there is none, leaving the client to report an encrypted
+// save file.
+func profilePassphrase(cfg *config.Config) (string, error) {
+	if passphrase, ok := os.LookupEnv(config.PassphraseEnv); ok {
+		return passphrase, nil
+	}
+
+	passphrase, err := cfg.ReadPassphraseFile()
+	if err != nil || passphrase != "" {
+		return passphrase, err
+	}
+
+	if !saveFileEncrypted(cfg) {
+		return "", nil
+	}
+	passphrase, err = promptPassphrase(fmt.Sprintf("Passphrase for %s: ", cfg.SaveFile))
+	if errors.Is(err, errNoTerminal) {
+		return "", nil
+	}
+	return passphrase, err
+}
+
+// newPassphrase returns the passphrase -change-passphrase sets, from
+// RATOX_NEW_PASSPHRASE or typed twice at a prompt
+func newPassphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv(config.NewPassphraseEnv); ok {
+		if passphrase == "" {
+			return "", fmt.Errorf("%s is empty: use -remove-passphrase to store the profile unencrypted", config.NewPassphraseEnv)
+		}
+		return passphrase, nil
+	}
+
+	return promptNewPassphrase("New passphrase: ", "Repeat new passphrase: ")
+}
+
+// promptNewPassphrase asks for a non-empty passphrase twice
+func promptNewPassphrase(prompt, repeat string) (string, error) {
+	passphrase, err := promptPassphrase(prompt)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("the passphrase must not be empty")
+	}
+	again, err := promptPassphrase(repeat)
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// promptPassphrase reads a line from the controlling terminal with echo
+// turned off
+func promptPassphrase(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", errNoTerminal
+	}
+	defer tty.Close()
+
+	if err := stty(tty, "-echo"); err != nil {
+		return "", fmt.Errorf("failed to turn off terminal echo: %w", err)
+	}
+	defer func() {
+		stty(tty, "echo")
+		fmt.Fprintln(tty)
+	}()
+
+	fmt.Fprint(tty, prompt)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// stty changes the settings of the terminal tty
+func stty(tty *os.File, setting string) error {
+	cmd := exec.Command("stty", setting)
+	cmd.Stdin = tty
+	return cmd.Run()
+}
+
+// changePassphrase sets, changes or, with remove, removes the passphrase of
+// the save file and its backups, and returns the process exit status
+func changePassphrase(configDir string, remove bool) int {
+	logrus.WithFields(logrus.Fields{
+		"caller":     "main",
+		"config_dir": configDir,
+		"remove":     remove,
+	}).Debug("Changing passphrase")
+
+	cfg, err := readEffectiveConfig(configDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", configDir, err)
+		return 1
+	}
+
+	// A running ratox-go would overwrite the re-encrypted save file
+	lock, err := client.LockProfile(configDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer lock.Release()
+
+	var oldPassphrase string
+	if saveFileEncrypted(cfg) {
+		if oldPassphrase, err = profilePassphrase(cfg); err == nil && oldPassphrase == "" {
+			err = client.ErrPassphraseRequired
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cfg.SaveFile, err)
+			return 1
+		}
+	}
+
+	var passphrase string
+	if !remove {
+		if passphrase, err = newPassphrase(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cfg.SaveFile, err)
+			return 1
+		}
+	}
+
+	if err := client.ChangePassphrase(cfg, oldPassphrase, passphrase); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if remove {
+		fmt.Printf("%s: stored unencrypted\n", cfg.SaveFile)
+	} else {
+		fmt.Printf("%s: encrypted\n", cfg.SaveFile)
+	}
+	return 0
+}
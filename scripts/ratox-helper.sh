@@ -33,13 +33,9 @@ print_error() {
     echo -e "${RED}[ERROR]${NC} $1"
 }
 
-# Check if ratox-go is running
+# Check if ratox-go is running on this profile
 check_ratox_running() {
-    if pgrep -f "ratox-go" > /dev/null; then
-        return 0
-    else
-        return 1
-    fi
+    ratox-go status -profile "${RATOX_DIR}" 2>/dev/null | grep -q '^running: yes$'
 }
 
 # Wait for ratox to be ready
@@ -101,12 +97,12 @@ stop_ratox() {
 
 # Show Tox ID
 show_tox_id() {
-    if [ -f "${RATOX_DIR}/ratox.json" ]; then
-        # Try to extract Tox ID from logs or use ratox-go command
-        print_info "Your Tox ID will be displayed in the ratox-go output"
-        print_info "Check the logs or run: ratox-go -v"
+    local tox_id
+    if tox_id=$(ratox-go id -profile "${RATOX_DIR}"); then
+        print_info "Your Tox ID: $tox_id"
     else
         print_warning "ratox-go not initialized yet"
+        return 1
     fi
 }
 
@@ -147,23 +143,11 @@ send_message() {
     local message="$2"
     
     if [ -z "$friend_id" ] || [ -z "$message" ]; then
-        print_error "Usage: $0 send <friend_id> <message>"
-        return 1
-    fi
-    
-    local friend_dir="${RATOX_DIR}/${friend_id}"
-    
-    if [ ! -d "$friend_dir" ]; then
-        print_error "Friend $friend_id not found"
-        return 1
-    fi
-    
-    if [ ! -p "${friend_dir}/text_in" ]; then
-        print_error "text_in FIFO not found for friend $friend_id"
+        print_error "Usage: $0 send <friend> <message>"
         return 1
     fi
     
-    echo "$message" > "${friend_dir}/text_in"
+    ratox-go send -profile "${RATOX_DIR}" "$friend_id" "$message"
     print_success "Message sent to $friend_id: $message"
 }
 
@@ -172,21 +156,12 @@ add_friend() {
     local tox_id="$1"
     
     if [ -z "$tox_id" ]; then
-        print_error "Usage: $0 add <tox_id>"
+        print_error "Usage: $0 add <tox_id> [message]"
         return 1
     fi
+    shift
     
-    if [ ${#tox_id} -ne 76 ]; then
-        print_error "Invalid Tox ID length. Should be 76 characters."
-        return 1
-    fi
-    
-    if [ ! -p "${RATOX_DIR}/request_in" ]; then
-        print_error "request_in FIFO not found. Is ratox-go running?"
-        return 1
-    fi
-    
-    echo "$tox_id" > "${RATOX_DIR}/request_in"
+    ratox-go add -profile "${RATOX_DIR}" "$tox_id" "$@" > /dev/null
     print_success "Friend request sent to $tox_id"
 }
 
@@ -213,7 +188,7 @@ set_status() {
     local status="$1"
     
     if [ -z "$status" ]; then
-        print_error "Usage: $0 status <status_message>"
+        print_error "Usage: $0 status_message <status_message>"
         return 1
     fi
     
@@ -226,30 +201,46 @@ set_status() {
     print_success "Status message set to: $status"
 }
 
+# Turn the quoted name of a roster line back into text. Control characters
+# stay escaped so each friend is printed on one line.
+unquote_name() {
+    local quoted="${1#\"}" out="" c
+    quoted="${quoted%\"}"
+    while [ -n "$quoted" ]; do
+        c="${quoted:0:1}"
+        if [ "$c" = "\\" ] && { [ "${quoted:1:1}" = "\\" ] || [ "${quoted:1:1}" = "\"" ]; }; then
+            c="${quoted:1:1}"
+            quoted="${quoted:2}"
+        else
+            quoted="${quoted:1}"
+        fi
+        out+="$c"
+    done
+    printf '%s' "$out"
+}
+
 # List friends
 list_friends() {
     print_info "Friends:"
     
-    if [ ! -d "$RATOX_DIR" ]; then
+    local roster
+    if ! roster=$(ratox-go friends -profile "${RATOX_DIR}"); then
         print_warning "ratox-go not initialized"
         return 1
     fi
     
+    # Each line: <public_key> <alias> <user_status> <connection> <last_seen> "<name>"
     local count=0
-    for friend_dir in "${RATOX_DIR}"/*/; do
-        if [ -d "$friend_dir" ]; then
-            friend_id=$(basename "$friend_dir")
-            status="offline"
-            
-            # Try to read status
-            if [ -p "${friend_dir}/status" ]; then
-                status=$(timeout 1s cat "${friend_dir}/status" 2>/dev/null || echo "unknown")
-            fi
-            
-            echo -e "  ${GREEN}$friend_id${NC} - $status"
-            count=$((count + 1))
+    while read -r friend_id alias user_status connection last_seen name; do
+        [ -n "$friend_id" ] || continue
+        name=$(unquote_name "$name")
+        if [ "$alias" != "-" ]; then
+            printf "  ${GREEN}%s${NC} (%s) - %s - %s, %s, last seen %s\n" "$alias" "$friend_id" "$name" "$user_status" "$connection" "$last_seen"
+        else
+            printf "  ${GREEN}%s${NC} - %s - %s, %s, last seen %s\n" "$friend_id" "$name" "$user_status" "$connection" "$last_seen"
         fi
-    done
+        count=$((count + 1))
+    done <<< "$roster"
     
     if [ $count -eq 0 ]; then
         print_info "No friends added yet"
@@ -270,10 +261,10 @@ show_help() {
     echo "  status                - Show ratox-go status"
     echo "  monitor               - Monitor all messages (real-time)"
     echo "  send <id> <message>   - Send message to friend"
-    echo "  add <tox_id>          - Add friend by Tox ID"
+    echo "  add <tox_id> [msg]    - Add friend by Tox ID"
     echo "  friends               - List all friends"
     echo "  name <name>           - Set your display name"
-    echo "  status <message>      - Set your status message"
+    echo "  status_message <msg>  - Set your status message"
     echo "  toxid                 - Show your Tox ID"
     echo "  help                  - Show this help"
     echo ""
@@ -282,7 +273,7 @@ show_help() {
     echo "  $0 add 1234567890ABCDEF1234567890ABCDEF1234567890ABCDEF1234567890ABCDEF1234"
     echo "  $0 send ABCDEF1234567890 \"Hello, friend!\""
     echo "  $0 name \"My Name\""
-    echo "  $0 status \"Available\""
+    echo "  $0 status_message \"Available\""
     echo ""
     echo "Config directory: $RATOX_DIR"
 }
@@ -296,11 +287,7 @@ case "$1" in
         stop_ratox
         ;;
     status)
-        if check_ratox_running; then
-            print_success "ratox-go is running"
-        else
-            print_warning "ratox-go is not running"
-        fi
+        ratox-go status -profile "${RATOX_DIR}"
         ;;
     monitor)
         monitor_messages
@@ -309,7 +296,8 @@ case "$1" in
         send_message "$2" "$3"
         ;;
     add)
-        add_friend "$2"
+        shift
+        add_friend "$@"
         ;;
     friends)
         list_friends
@@ -317,7 +305,7 @@ case "$1" in
     name)
         set_name "$2"
         ;;
-    status)
+    status_message)
         set_status "$2"
         ;;
     toxid)